@@ -0,0 +1,587 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	dayFormat = "2006-01-02"
+	// icalHorizonYears bounds the expansion of recurring iCalendar holidays.
+	icalHorizonYears = 30
+)
+
+var (
+	//ErrBadCalendar malformed calendar file or body
+	ErrBadCalendar = errors.New("bad calendar")
+	//ErrUnknownCalendar calendar name not registered
+	ErrUnknownCalendar = errors.New("unknown calendar")
+	//ErrCalendarInUse calendar still referenced by a task
+	ErrCalendarInUse = errors.New("calendar in use")
+
+	defaultWeekend = []time.Weekday{time.Saturday, time.Sunday}
+
+	calendars = struct {
+		sync.RWMutex
+		byName map[string]*Calendar
+	}{byName: map[string]*Calendar{}}
+)
+
+// Calendar lists the non-working days of a named business calendar: its
+// weekend days and its holidays, the latter as dates in whatever location a
+// schedule is evaluated in.
+type Calendar struct {
+	Name     string
+	Weekend  []time.Weekday
+	Holidays []string
+
+	holidays map[string]bool
+}
+
+type calendarJSON struct {
+	Name     string   `json:"name"`
+	Weekend  []string `json:"weekend"`
+	Holidays []string `json:"holidays"`
+}
+
+// NewCalendar validates the holidays, written as YYYY-MM-DD, and the weekend,
+// Saturday and Sunday when nil. At least one day of the week must be a
+// working day.
+func NewCalendar(name string, weekend []time.Weekday, holidays []string) (*Calendar, error) {
+	if name == "" || len(name) > 64 {
+		return nil, ErrBadCalendar
+	}
+
+	if weekend == nil {
+		weekend = defaultWeekend
+	}
+
+	seen := map[time.Weekday]bool{}
+
+	for _, weekday := range weekend {
+		if weekday < time.Sunday || weekday > time.Saturday {
+			return nil, ErrBadCalendar
+		}
+
+		seen[weekday] = true
+	}
+
+	if len(seen) == 7 {
+		return nil, ErrBadCalendar
+	}
+
+	c := &Calendar{Name: name, Weekend: weekend, holidays: map[string]bool{}}
+
+	for _, holiday := range holidays {
+		if _, err := time.Parse(dayFormat, holiday); err != nil {
+			return nil, ErrBadCalendar
+		}
+
+		if !c.holidays[holiday] {
+			c.holidays[holiday] = true
+			c.Holidays = append(c.Holidays, holiday)
+		}
+	}
+
+	sort.Strings(c.Holidays)
+
+	return c, nil
+}
+
+// IsWorkingDay tells whether the date of t, in the location of t, is neither
+// a weekend day nor a holiday.
+func (c *Calendar) IsWorkingDay(t time.Time) bool {
+	for _, weekday := range c.Weekend {
+		if t.Weekday() == weekday {
+			return false
+		}
+	}
+
+	return !c.holidays[t.Format(dayFormat)]
+}
+
+// MarshalJSON writes the calendar with weekday names, e.g.
+// {"name": "target", "weekend": ["Saturday", "Sunday"], "holidays": ["2017-12-25"]}.
+func (c *Calendar) MarshalJSON() ([]byte, error) {
+	weekend := make([]string, len(c.Weekend))
+
+	for i, weekday := range c.Weekend {
+		weekend[i] = weekday.String()
+	}
+
+	return json.Marshal(calendarJSON{Name: c.Name, Weekend: weekend, Holidays: c.Holidays})
+}
+
+// UnmarshalJSON reads a calendar written by MarshalJSON, a missing weekend
+// meaning Saturday and Sunday.
+func (c *Calendar) UnmarshalJSON(data []byte) error {
+	var value calendarJSON
+
+	if err := json.Unmarshal(data, &value); err != nil {
+		return ErrBadCalendar
+	}
+
+	calendar, err := value.calendar()
+
+	if err != nil {
+		return err
+	}
+
+	*c = *calendar
+
+	return nil
+}
+
+func (value calendarJSON) calendar() (*Calendar, error) {
+	var weekend []time.Weekday
+
+	if value.Weekend != nil {
+		weekend = []time.Weekday{}
+	}
+
+	for _, name := range value.Weekend {
+		weekday, ok := weekdayFromString(name)
+
+		if !ok {
+			return nil, ErrBadCalendar
+		}
+
+		weekend = append(weekend, weekday)
+	}
+
+	return NewCalendar(value.Name, weekend, value.Holidays)
+}
+
+func weekdayFromString(name string) (time.Weekday, bool) {
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		if strings.EqualFold(name, weekday.String()) {
+			return weekday, true
+		}
+	}
+
+	return time.Sunday, false
+}
+
+// CalendarsFromJSON reads a single calendar object or an array of them.
+func CalendarsFromJSON(r io.Reader) ([]*Calendar, error) {
+	data, err := ioutil.ReadAll(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] != '[' {
+		data = append(append([]byte("["), trimmed...), ']')
+	}
+
+	var list []*Calendar
+
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, ErrBadCalendar
+	}
+
+	return list, nil
+}
+
+// calendarFromBody reads a calendar sent to the API under the given name, as
+// iCalendar for a text/calendar content type and as JSON otherwise.
+func calendarFromBody(name string, contentType string, body []byte) (*Calendar, error) {
+	if strings.HasPrefix(contentType, "text/calendar") {
+		return CalendarFromICal(name, bytes.NewReader(body))
+	}
+
+	var value calendarJSON
+
+	if err := json.Unmarshal(body, &value); err != nil {
+		return nil, ErrBadCalendar
+	}
+
+	value.Name = name
+
+	return value.calendar()
+}
+
+// loadCalendarFile stores the calendars of a .ics file, named after the
+// file, or of a .json file.
+func loadCalendarFile(db sql.DB, path string) error {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	var list []*Calendar
+
+	switch ext := filepath.Ext(path); ext {
+	case ".ics":
+		calendar, err := CalendarFromICal(strings.TrimSuffix(filepath.Base(path), ext), file)
+
+		if err != nil {
+			return err
+		}
+
+		list = append(list, calendar)
+	case ".json":
+		if list, err = CalendarsFromJSON(file); err != nil {
+			return err
+		}
+	default:
+		return ErrBadCalendar
+	}
+
+	for _, calendar := range list {
+		if err := saveCalendar(db, calendar); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CalendarFromICal reads the all-day events of an iCalendar file as holidays
+// of a calendar with a Saturday and Sunday weekend. An event covers the days
+// from DTSTART up to DTEND, excluded, and an RRULE repeats it for
+// icalHorizonYears years.
+func CalendarFromICal(name string, r io.Reader) (*Calendar, error) {
+	var (
+		holidays []string
+		event    map[string]string
+		lines    []string
+	)
+
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		// folded lines continue with a space or a tab
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+		} else {
+			lines = append(lines, line)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, line := range lines {
+		colon := strings.Index(line, ":")
+
+		if colon < 0 {
+			continue
+		}
+
+		property := strings.ToUpper(strings.SplitN(line[:colon], ";", 2)[0])
+		value := line[colon+1:]
+
+		switch {
+		case property == "BEGIN" && strings.EqualFold(value, "VEVENT"):
+			event = map[string]string{}
+		case property == "END" && strings.EqualFold(value, "VEVENT") && event != nil:
+			days, err := icalEventDays(event)
+
+			if err != nil {
+				return nil, err
+			}
+
+			holidays, event = append(holidays, days...), nil
+		case event != nil:
+			event[property] = value
+		}
+	}
+
+	return NewCalendar(name, nil, holidays)
+}
+
+// icalEventDays lists the days an event covers, taking the date part of date
+// times as they are written.
+func icalEventDays(event map[string]string) ([]string, error) {
+	start, err := icalDate(event["DTSTART"])
+
+	if err != nil {
+		return nil, err
+	}
+
+	length := 1
+
+	if value, ok := event["DTEND"]; ok {
+		end, err := icalDate(value)
+
+		if err != nil || end.Before(start) {
+			return nil, ErrBadCalendar
+		}
+
+		// a timed event ending the day it starts still covers that day
+		if days := daysBetween(start, end); days > 1 {
+			length = days
+		}
+	}
+
+	starts := []time.Time{start}
+
+	if rule, ok := event["RRULE"]; ok {
+		rrule, err := RRuleFromString("DTSTART:"+start.Format("20060102T150405Z")+" RRULE:"+rule, time.UTC)
+
+		if err != nil {
+			return nil, ErrBadCalendar
+		}
+
+		horizon := start.AddDate(icalHorizonYears, 0, 0)
+
+		for next, ok := rrule.Next(start); ok && next.Before(horizon); next, ok = rrule.Next(next) {
+			starts = append(starts, next)
+		}
+	}
+
+	var days []string
+
+	for _, first := range starts {
+		for i := 0; i < length; i++ {
+			days = append(days, first.AddDate(0, 0, i).Format(dayFormat))
+		}
+	}
+
+	return days, nil
+}
+
+func icalDate(value string) (time.Time, error) {
+	if len(value) < 8 {
+		return time.Time{}, ErrBadCalendar
+	}
+
+	date, err := time.Parse("20060102", value[:8])
+
+	if err != nil {
+		return time.Time{}, ErrBadCalendar
+	}
+
+	return date, nil
+}
+
+// RegisterCalendar makes the calendar available to tasks by its name,
+// replacing one of the same name.
+func RegisterCalendar(c *Calendar) {
+	calendars.Lock()
+	defer calendars.Unlock()
+
+	calendars.byName[c.Name] = c
+	forgetCalendarSchedules(c.Name)
+}
+
+func unregisterCalendar(name string) {
+	calendars.Lock()
+	defer calendars.Unlock()
+
+	delete(calendars.byName, name)
+	forgetCalendarSchedules(name)
+}
+
+// CalendarByName returns the registered calendar with the given name.
+func CalendarByName(name string) (*Calendar, error) {
+	calendars.RLock()
+	defer calendars.RUnlock()
+
+	c, ok := calendars.byName[name]
+
+	if !ok {
+		return nil, ErrUnknownCalendar
+	}
+
+	return c, nil
+}
+
+// Calendars returns the registered calendars sorted by name.
+func Calendars() []*Calendar {
+	calendars.RLock()
+	defer calendars.RUnlock()
+
+	list := make([]*Calendar, 0, len(calendars.byName))
+
+	for _, c := range calendars.byName {
+		list = append(list, c)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+
+	return list
+}
+
+// loadCalendars registers the calendars stored in the database.
+func loadCalendars(db sql.DB) error {
+	rows, err := db.Query("select name, weekend from calendars")
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	weekends := map[string][]time.Weekday{}
+
+	for rows.Next() {
+		var name, weekend string
+
+		if err := rows.Scan(&name, &weekend); err != nil {
+			return err
+		}
+
+		weekends[name] = []time.Weekday{}
+
+		for _, day := range strings.Split(weekend, ",") {
+			if n, err := strconv.Atoi(day); err == nil {
+				weekends[name] = append(weekends[name], time.Weekday(n))
+			}
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for name, weekend := range weekends {
+		holidays, err := getCalendarHolidays(db, name)
+
+		if err != nil {
+			return err
+		}
+
+		calendar, err := NewCalendar(name, weekend, holidays)
+
+		if err != nil {
+			return err
+		}
+
+		RegisterCalendar(calendar)
+	}
+
+	return nil
+}
+
+func getCalendarHolidays(db sql.DB, name string) ([]string, error) {
+	rows, err := db.Query("select day from calendarsHolidays where calendar = ?", name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var holidays []string
+
+	for rows.Next() {
+		var day string
+
+		if err := rows.Scan(&day); err != nil {
+			return nil, err
+		}
+
+		holidays = append(holidays, day)
+	}
+
+	return holidays, rows.Err()
+}
+
+// saveCalendar stores the calendar, replacing one of the same name, and
+// registers it.
+func saveCalendar(db sql.DB, c *Calendar) error {
+	weekend := make([]string, len(c.Weekend))
+
+	for i, weekday := range c.Weekend {
+		weekend[i] = strconv.Itoa(int(weekday))
+	}
+
+	tx, err := db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec("delete from calendarsHolidays where calendar = ?", c.Name); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if _, err = tx.Exec("insert or replace into calendars(name, weekend) values(?, ?)", c.Name, strings.Join(weekend, ",")); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	stmt, err := tx.Prepare("insert into calendarsHolidays(calendar, day) values(?, ?)")
+
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	defer stmt.Close()
+
+	for _, day := range c.Holidays {
+		if _, err = stmt.Exec(c.Name, day); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	RegisterCalendar(c)
+
+	return nil
+}
+
+// deleteCalendar removes the calendar unless a task refers to it.
+func deleteCalendar(db sql.DB, name string) error {
+	var tasks int
+
+	if err := db.QueryRow("select count(*) from tasks where calendar = ?", name).Scan(&tasks); err != nil {
+		return err
+	}
+
+	if tasks > 0 {
+		return ErrCalendarInUse
+	}
+
+	tx, err := db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec("delete from calendarsHolidays where calendar = ?", name); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if _, err = tx.Exec("delete from calendars where name = ?", name); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	unregisterCalendar(name)
+
+	return nil
+}
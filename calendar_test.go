@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewCalendar(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		Name     string
+		Weekend  []time.Weekday
+		Holidays []string
+		Error    error
+	}{
+		{"target", nil, []string{"2017-12-25", "2017-01-01", "2017-12-25"}, nil},
+		{"gulf", []time.Weekday{time.Friday, time.Saturday}, nil, nil},
+		{"always", []time.Weekday{}, nil, nil},
+		{"", nil, nil, ErrBadCalendar},
+		{strings.Repeat("x", 65), nil, nil, ErrBadCalendar},
+		{"never", []time.Weekday{0, 1, 2, 3, 4, 5, 6}, nil, ErrBadCalendar},
+		{"bad-weekday", []time.Weekday{7}, nil, ErrBadCalendar},
+		{"bad-holiday", nil, []string{"2017-02-30"}, ErrBadCalendar},
+		{"bad-format", nil, []string{"20171225"}, ErrBadCalendar},
+	}
+
+	for index, test := range tests {
+		if _, err := NewCalendar(test.Name, test.Weekend, test.Holidays); err != test.Error {
+			t.Errorf("Test %d expected %v but got %v", index+1, test.Error, err)
+		}
+	}
+
+	calendar, _ := NewCalendar("target", nil, []string{"2017-12-25", "2017-01-01", "2017-12-25"})
+
+	if strings.Join(calendar.Holidays, " ") != "2017-01-01 2017-12-25" {
+		t.Errorf("Expected sorted unique holidays but got %v", calendar.Holidays)
+	}
+}
+
+func TestCalendarIsWorkingDay(t *testing.T) {
+	t.Parallel()
+
+	calendar, _ := NewCalendar("target", nil, []string{"2017-12-25"})
+	gulf, _ := NewCalendar("gulf", []time.Weekday{time.Friday, time.Saturday}, nil)
+	tokyo, _ := time.LoadLocation("Asia/Tokyo")
+
+	var tests = []struct {
+		Calendar *Calendar
+		Time     time.Time
+		Expected bool
+	}{
+		{calendar, time.Date(2017, 12, 22, 9, 0, 0, 0, time.UTC), true},
+		{calendar, time.Date(2017, 12, 23, 9, 0, 0, 0, time.UTC), false},
+		{calendar, time.Date(2017, 12, 25, 9, 0, 0, 0, time.UTC), false},
+		// already 25 December in Tokyo
+		{calendar, time.Date(2017, 12, 24, 16, 0, 0, 0, time.UTC).In(tokyo), false},
+		{calendar, time.Date(2017, 12, 26, 0, 0, 0, 0, time.UTC), true},
+		{gulf, time.Date(2017, 12, 22, 9, 0, 0, 0, time.UTC), false},
+		{gulf, time.Date(2017, 12, 24, 9, 0, 0, 0, time.UTC), true},
+	}
+
+	for index, test := range tests {
+		if result := test.Calendar.IsWorkingDay(test.Time); result != test.Expected {
+			t.Errorf("Test %d expected %v but got %v", index+1, test.Expected, result)
+		}
+	}
+}
+
+func TestCalendarJSON(t *testing.T) {
+	t.Parallel()
+
+	calendar, _ := NewCalendar("gulf", []time.Weekday{time.Friday, time.Saturday}, []string{"2017-12-02"})
+	data, err := json.Marshal(calendar)
+
+	if err != nil {
+		t.Fatalf("Expected calendar to marshal but got error %s", err)
+	}
+
+	expected := `{"name":"gulf","weekend":["Friday","Saturday"],"holidays":["2017-12-02"]}`
+
+	if string(data) != expected {
+		t.Errorf("Expected %s but got %s", expected, data)
+	}
+
+	var result Calendar
+
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatalf("Expected calendar to unmarshal but got error %s", err)
+	}
+
+	if result.Name != "gulf" || len(result.Weekend) != 2 || result.IsWorkingDay(time.Date(2017, 12, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected %v but got %v", calendar, result)
+	}
+
+	var tests = []struct {
+		Body     string
+		Expected []string
+		Error    error
+	}{
+		{`{"name": "target", "holidays": ["2017-12-25"]}`, []string{"target"}, nil},
+		{`[{"name": "target"}, {"name": "gulf", "weekend": ["friday", "SATURDAY"]}]`, []string{"target", "gulf"}, nil},
+		{`{"name": "target", "weekend": ["Caturday"]}`, nil, ErrBadCalendar},
+		{`{"name": "target", "holidays": ["25/12/2017"]}`, nil, ErrBadCalendar},
+		{`{"name": "target"`, nil, ErrBadCalendar},
+	}
+
+	for index, test := range tests {
+		list, err := CalendarsFromJSON(strings.NewReader(test.Body))
+
+		if err != test.Error {
+			t.Errorf("Test %d expected %v but got %v", index+1, test.Error, err)
+			continue
+		}
+
+		var names []string
+
+		for _, calendar := range list {
+			names = append(names, calendar.Name)
+		}
+
+		if strings.Join(names, " ") != strings.Join(test.Expected, " ") {
+			t.Errorf("Test %d expected %v but got %v", index+1, test.Expected, names)
+		}
+	}
+}
+
+func TestCalendarFromICal(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		Body     string
+		Expected []string
+		Error    error
+	}{
+		{"BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nSUMMARY:Christmas\r\nDTSTART;VALUE=DATE:20171225\r\nDTEND;VALUE=DATE:20171227\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", []string{"2017-12-25", "2017-12-26"}, nil},
+		{"BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART;VALUE=DATE:20170501\nEND:VEVENT\nBEGIN:VEVENT\nDTSTART:20170814T090000Z\nDTEND:20170814T120000Z\nEND:VEVENT\nEND:VCALENDAR\n", []string{"2017-05-01", "2017-08-14"}, nil},
+		// folded description and a yearly rule limited by COUNT
+		{"BEGIN:VCALENDAR\nBEGIN:VEVENT\nDESCRIPTION:New Year,\n  observed\nDTSTART;VALUE=DATE:20170101\nRRULE:FREQ=YEARLY;\n COUNT=3\nEND:VEVENT\nEND:VCALENDAR\n", []string{"2017-01-01", "2018-01-01", "2019-01-01"}, nil},
+		{"BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART;VALUE=DATE:2017\nEND:VEVENT\nEND:VCALENDAR\n", nil, ErrBadCalendar},
+		{"BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART;VALUE=DATE:20171225\nDTEND;VALUE=DATE:20171224\nEND:VEVENT\nEND:VCALENDAR\n", nil, ErrBadCalendar},
+		{"BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART;VALUE=DATE:20171225\nRRULE:FREQ=SOMETIMES\nEND:VEVENT\nEND:VCALENDAR\n", nil, ErrBadCalendar},
+	}
+
+	for index, test := range tests {
+		calendar, err := CalendarFromICal("holidays", strings.NewReader(test.Body))
+
+		if err != test.Error {
+			t.Errorf("Test %d expected %v but got %v", index+1, test.Error, err)
+			continue
+		}
+
+		if err == nil && strings.Join(calendar.Holidays, " ") != strings.Join(test.Expected, " ") {
+			t.Errorf("Test %d expected %v but got %v", index+1, test.Expected, calendar.Holidays)
+		}
+	}
+
+	calendar, err := calendarFromBody("uk", "text/calendar; charset=utf-8", []byte(tests[0].Body))
+
+	if err != nil || calendar.Name != "uk" || len(calendar.Holidays) != 2 {
+		t.Errorf("Expected the iCalendar body to be read but got %v %v", calendar, err)
+	}
+
+	calendar, err = calendarFromBody("uk", "application/json", []byte(`{"name": "ignored", "holidays": ["2017-12-25"]}`))
+
+	if err != nil || calendar.Name != "uk" || len(calendar.Holidays) != 1 {
+		t.Errorf("Expected the JSON body to be read but got %v %v", calendar, err)
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// SetOperator combines the occurrences of two schedules.
+type SetOperator int
+
+const (
+	// Union fires at the occurrences of either schedule.
+	Union SetOperator = iota
+	// Intersection fires at the occurrences common to both schedules.
+	Intersection
+	// Exclusion fires at the occurrences of the first schedule that are not
+	// occurrences of the second.
+	Exclusion
+)
+
+var setOperators = map[string]SetOperator{
+	"UNION":     Union,
+	"INTERSECT": Intersection,
+	"EXCEPT":    Exclusion,
+}
+
+func (o SetOperator) String() string {
+	for name, operator := range setOperators {
+		if operator == o {
+			return name
+		}
+	}
+
+	return ""
+}
+
+// CompositeSchedule combines the occurrences of two schedules with a set
+// operator. Occurrences are compared as instants, so an intersection needs
+// rules firing at the very same times, e.g. every 15 minutes and every minute
+// from 9 to 17 on weekdays.
+type CompositeSchedule struct {
+	Operator SetOperator
+	Left     Schedule
+	Right    Schedule
+}
+
+type operand struct {
+	operator SetOperator
+	at       int
+	rule     string
+}
+
+// isComposite tells whether the rule has a set operator between two rules.
+func isComposite(rule string) bool {
+	return len(compositeOperands(rule)) > 1
+}
+
+// compositeOperands splits the rule at the UNION, INTERSECT and EXCEPT words,
+// which no rule syntax uses on its own. They are matched in upper case only,
+// so an English phrase such as "every day except sunday" stays whole.
+func compositeOperands(rule string) []operand {
+	operands := []operand{{operator: Union}}
+	at := 0
+
+	for at < len(rule) {
+		start := at + strings.IndexFunc(rule[at:], func(r rune) bool { return !unicode.IsSpace(r) })
+
+		if start < at {
+			break
+		}
+
+		end := len(rule)
+
+		if n := strings.IndexFunc(rule[start:], unicode.IsSpace); n >= 0 {
+			end = start + n
+		}
+
+		if operator, ok := setOperators[rule[start:end]]; ok {
+			operands = append(operands, operand{operator: operator, at: end})
+		} else if last := &operands[len(operands)-1]; last.rule == "" {
+			last.at, last.rule = start, rule[start:end]
+		} else {
+			last.rule = rule[last.at:end]
+		}
+
+		at = end
+	}
+
+	return operands
+}
+
+// CompositeFromString parses rules in any supported syntax joined by set
+// operators, which apply from left to right:
+//
+//	*/15 * * * *
+//	INTERSECT * 9-16 * * MON-FRI
+//	EXCEPT DTSTART:20170101T000000Z RRULE:FREQ=YEARLY;BYMONTH=12;BYMONTHDAY=24,25,26
+//
+// The rules may also share a line and each must have a start or an end.
+func CompositeFromString(rule string, options ScheduleOptions) (Schedule, error) {
+	var schedule Schedule
+
+	for i, operand := range compositeOperands(rule) {
+		if operand.rule == "" {
+			return nil, newParseError(operand.at, "", ReasonComponentCount)
+		}
+
+		next, err := scheduleFromString(operand.rule, options)
+
+		if err != nil {
+			var parseErr *ParseError
+
+			if errors.As(err, &parseErr) {
+				return nil, parseErr.shifted(operand.at)
+			}
+
+			return nil, err
+		}
+
+		if recurrence, ok := next.(Recurrence); ok && recurrence.Start == nil && recurrence.End == nil {
+			return nil, newParseError(operand.at, operand.rule, ReasonUnanchoredOperand)
+		}
+
+		if i == 0 {
+			schedule = next
+		} else {
+			schedule = CompositeSchedule{Operator: operand.operator, Left: schedule, Right: next}
+		}
+	}
+
+	return schedule, nil
+}
+
+// Next returns the first occurrence of the combination strictly after the
+// given time, or false when there is none within horizonYears years.
+func (c CompositeSchedule) Next(after time.Time) (time.Time, bool) {
+	horizon := after.AddDate(horizonYears, 0, 0)
+
+	switch c.Operator {
+	case Intersection:
+		left, ok := c.Left.Next(after)
+
+		for ok && !left.After(horizon) {
+			right, rightOk := c.Right.Next(left.Add(-time.Nanosecond))
+
+			if !rightOk {
+				break
+			}
+
+			if right.Equal(left) {
+				return left, true
+			}
+
+			// leapfrog to the first left occurrence not before the right one
+			left, ok = c.Left.Next(right.Add(-time.Nanosecond))
+		}
+
+		return time.Time{}, false
+	case Exclusion:
+		left, ok := c.Left.Next(after)
+
+		for ok && !left.After(horizon) {
+			if right, ok := c.Right.Next(left.Add(-time.Nanosecond)); !ok || !right.Equal(left) {
+				return left, true
+			}
+
+			left, ok = c.Left.Next(left)
+		}
+
+		return time.Time{}, false
+	}
+
+	left, leftOk := c.Left.Next(after)
+	right, rightOk := c.Right.Next(after)
+
+	if !leftOk || rightOk && right.Before(left) {
+		return right, rightOk
+	}
+
+	return left, true
+}
+
+// String joins the canonical rules with the operator on a line of its own.
+func (c CompositeSchedule) String() string {
+	return c.Left.String() + "\n" + c.Operator.String() + " " + c.Right.String()
+}
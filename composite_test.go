@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompositeNext(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		Rule     string
+		After    time.Time
+		Expected []string
+	}{
+		{"*/15 * * * * INTERSECT * 9-16 * * MON-FRI", now, []string{"2017-01-02T09:00:00Z", "2017-01-02T09:15:00Z", "2017-01-02T09:30:00Z"}},
+		{"*/15 * * * *\nINTERSECT * 9-16 * * MON-FRI", time.Date(2017, 1, 2, 16, 50, 0, 0, time.UTC), []string{"2017-01-03T09:00:00Z", "2017-01-03T09:15:00Z", "2017-01-03T09:30:00Z"}},
+		{"0 12 * * * EXCEPT 0 12 * * SAT,SUN", now, []string{"2017-01-02T12:00:00Z", "2017-01-03T12:00:00Z", "2017-01-04T12:00:00Z"}},
+		{"0 9 * * MON UNION 0 17 * * FRI", now, []string{"2017-01-02T09:00:00Z", "2017-01-06T17:00:00Z", "2017-01-09T09:00:00Z"}},
+		{"R/2017-01-01T00:00:00Z/PT6H\nEXCEPT DTSTART:20170101T000000Z\nRRULE:FREQ=DAILY;BYHOUR=0", now, []string{"2017-01-01T06:00:00Z", "2017-01-01T12:00:00Z", "2017-01-01T18:00:00Z"}},
+		// occurrences of both rules fire once
+		{"0 9 * * * UNION FREQ=DAILY;BYHOUR=9", now, []string{"2017-01-01T09:00:00Z", "2017-01-02T09:00:00Z", "2017-01-03T09:00:00Z"}},
+		// operators apply from left to right
+		{"0 9 * * * EXCEPT 0 9 * * SUN UNION 0 9 1 * *", now, []string{"2017-01-01T09:00:00Z", "2017-01-02T09:00:00Z", "2017-01-03T09:00:00Z"}},
+		{"R3/2017-01-01T09:00:00Z/P1D INTERSECT R/2017-01-01T10:00:00Z/P1D", now, nil},
+		{"R2/2017-01-01T09:00:00Z/P1D UNION R1/2017-01-05T09:00:00Z/P1D", now, []string{"2017-01-01T09:00:00Z", "2017-01-02T09:00:00Z", "2017-01-05T09:00:00Z"}},
+	}
+
+	for index, test := range tests {
+		schedule, err := Task{Rule: test.Rule}.Schedule()
+
+		if err != nil {
+			t.Fatalf("Test %d expected correct rule but got error %s", index+1, err)
+		}
+
+		result := nextOccurrences(schedule, test.After, 3)
+
+		if strings.Join(result, " ") != strings.Join(test.Expected, " ") {
+			t.Errorf("Test %d expected %v but got %v", index+1, test.Expected, result)
+		}
+
+		again, err := Task{Rule: schedule.String()}.Schedule()
+
+		if err != nil || again.String() != schedule.String() {
+			t.Errorf("Test %d expected %q to parse back but got %v", index+1, schedule.String(), err)
+		}
+	}
+}
+
+func TestCompositeOperands(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		Rule     string
+		Expected []string
+	}{
+		{"0 9 * * MON UNION 0 17 * * FRI", []string{"0 9 * * MON", "0 17 * * FRI"}},
+		{"*/15 * * * *\nINTERSECT * 9-16 * * MON-FRI", []string{"*/15 * * * *", "* 9-16 * * MON-FRI"}},
+		// only the upper-case words are operators
+		{"every weekday at 9am except holidays", []string{"every weekday at 9am except holidays"}},
+		{"0 9 * * MON union 0 17 * * FRI", []string{"0 9 * * MON union 0 17 * * FRI"}},
+	}
+
+	for index, test := range tests {
+		var result []string
+
+		for _, operand := range compositeOperands(test.Rule) {
+			result = append(result, operand.rule)
+		}
+
+		if strings.Join(result, "|") != strings.Join(test.Expected, "|") {
+			t.Errorf("Test %d expected %q but got %q", index+1, test.Expected, result)
+		}
+
+		if composite := isComposite(test.Rule); composite != (len(test.Expected) > 1) {
+			t.Errorf("Test %d expected composite %t but got %t", index+1, len(test.Expected) > 1, composite)
+		}
+	}
+}
+
+func TestCompositeParseError(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		Rule   string
+		Offset int
+		Reason ParseReason
+	}{
+		{"0 9 * * * EXCEPT", 16, ReasonComponentCount},
+		{"0 9 * * * INTERSECT UNION 0 10 * * *", 19, ReasonComponentCount},
+		{"R/P1D UNION 0 9 * * *", 0, ReasonUnanchoredOperand},
+		{"0 9 * * * EXCEPT R/2017-13-01T00:00:00Z/P1D", 19, ReasonOutOfRange},
+	}
+
+	for index, test := range tests {
+		_, err := Task{Rule: test.Rule}.Schedule()
+		var parseErr *ParseError
+
+		if !errors.As(err, &parseErr) {
+			t.Errorf("Test %d expected a parse error but got %v", index+1, err)
+			continue
+		}
+
+		if parseErr.Offset != test.Offset || parseErr.Reason != test.Reason {
+			t.Errorf("Test %d expected %s at %d but got %s at %d", index+1, test.Reason, test.Offset, parseErr.Reason, parseErr.Offset)
+		}
+	}
+}
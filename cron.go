@@ -0,0 +1,540 @@
+package main
+
+import (
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	cronDescriptors = map[string]string{
+		"@yearly":   "0 0 1 1 *",
+		"@annually": "0 0 1 1 *",
+		"@monthly":  "0 0 1 * *",
+		"@weekly":   "0 0 * * 0",
+		"@daily":    "0 0 * * *",
+		"@midnight": "0 0 * * *",
+		"@hourly":   "0 * * * *",
+	}
+
+	cronMonths = map[string]int{
+		"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6,
+		"JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12,
+	}
+
+	cronWeekdays = map[string]int{
+		"SUN": 0, "MON": 1, "TUE": 2, "WED": 3, "THU": 4, "FRI": 5, "SAT": 6,
+	}
+)
+
+// cronBounds are the value ranges of a cron field.
+type cronBounds struct {
+	min, max int
+	names    map[string]int
+}
+
+var (
+	secondBounds     = cronBounds{0, 59, nil}
+	minuteBounds     = cronBounds{0, 59, nil}
+	hourBounds       = cronBounds{0, 23, nil}
+	dayOfMonthBounds = cronBounds{1, 31, nil}
+	monthBounds      = cronBounds{1, 12, cronMonths}
+	dayOfWeekBounds  = cronBounds{0, 7, cronWeekdays}
+)
+
+// nthWeekday is a day of week entry such as 5#3, the third Friday.
+type nthWeekday struct {
+	weekday time.Weekday
+	n       int
+}
+
+// CronSchedule is a 5 field (minute first) or 6 field (second first) cron
+// expression. Fields are bit sets of the allowed values. Every is set for
+// @every descriptors, which fire at multiples of the duration since the Unix
+// epoch so they stay put across restarts.
+type CronSchedule struct {
+	Second     uint64
+	Minute     uint64
+	Hour       uint64
+	DayOfMonth uint64
+	Month      uint64
+	DayOfWeek  uint64
+	Every      time.Duration
+	Location   *time.Location
+	DSTGap     GapPolicy
+	DSTOverlap OverlapPolicy
+
+	expression     string
+	domStar        bool
+	dowStar        bool
+	lastDay        bool
+	lastDayOffset  int
+	lastWeekday    bool
+	nearestWeekday []int
+	lastOfWeek     uint64
+	nthOfWeek      []nthWeekday
+}
+
+// isCron tells whether the rule looks like a cron expression or descriptor.
+func isCron(rule string) bool {
+	rule = strings.TrimSpace(rule)
+
+	if strings.HasPrefix(rule, "CRON_TZ=") || strings.HasPrefix(rule, "TZ=") || strings.HasPrefix(rule, "@") {
+		return true
+	}
+
+	fields := len(strings.Fields(rule))
+
+	return fields == 5 || fields == 6
+}
+
+// CronFromString parses a cron expression evaluated in the given location,
+// which a leading CRON_TZ=<zone> overrides. Besides lists, ranges, steps and
+// names it accepts L, L-n, LW and nW in the day of month and L, nL and n#k in
+// the day of week. When both day fields are restricted a day matching either
+// of them fires, as in Vixie cron.
+func CronFromString(expression string, loc *time.Location) (CronSchedule, error) {
+	fields := strings.Fields(expression)
+
+	if len(fields) > 0 && (strings.HasPrefix(fields[0], "CRON_TZ=") || strings.HasPrefix(fields[0], "TZ=")) {
+		tz, err := LocationFromString(fields[0][strings.Index(fields[0], "=")+1:])
+
+		if err != nil {
+			return CronSchedule{}, err
+		}
+
+		loc, fields = tz, fields[1:]
+	}
+
+	c := CronSchedule{Location: loc, expression: strings.Join(strings.Fields(expression), " ")}
+
+	if len(fields) > 0 && strings.HasPrefix(fields[0], "@") {
+		if fields[0] == "@every" && len(fields) == 2 {
+			every, err := time.ParseDuration(fields[1])
+
+			if err != nil || every < time.Second {
+				return CronSchedule{}, ErrBadFormat
+			}
+
+			c.Every = every
+
+			return c, nil
+		}
+
+		descriptor, ok := cronDescriptors[fields[0]]
+
+		if !ok || len(fields) != 1 {
+			return CronSchedule{}, ErrBadFormat
+		}
+
+		fields = strings.Fields(descriptor)
+	}
+
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return CronSchedule{}, ErrBadFormat
+	}
+
+	var err error
+
+	if c.Second, err = cronFieldFromString(fields[0], secondBounds); err != nil {
+		return CronSchedule{}, err
+	}
+
+	if c.Minute, err = cronFieldFromString(fields[1], minuteBounds); err != nil {
+		return CronSchedule{}, err
+	}
+
+	if c.Hour, err = cronFieldFromString(fields[2], hourBounds); err != nil {
+		return CronSchedule{}, err
+	}
+
+	if err = c.parseDayOfMonth(fields[3]); err != nil {
+		return CronSchedule{}, err
+	}
+
+	if c.Month, err = cronFieldFromString(strings.ToUpper(fields[4]), monthBounds); err != nil {
+		return CronSchedule{}, err
+	}
+
+	if err = c.parseDayOfWeek(strings.ToUpper(fields[5])); err != nil {
+		return CronSchedule{}, err
+	}
+
+	return c, nil
+}
+
+func (c *CronSchedule) parseDayOfMonth(field string) error {
+	if field == "*" || field == "?" {
+		c.domStar = true
+		c.DayOfMonth = cronRange(1, 31)
+
+		return nil
+	}
+
+	var items []string
+
+	for _, item := range strings.Split(field, ",") {
+		switch {
+		case item == "L":
+			c.lastDay = true
+		case strings.HasPrefix(item, "L-"):
+			offset, err := strconv.Atoi(item[2:])
+
+			if err != nil || offset < 1 || offset > 30 || c.lastDay {
+				return ErrBadFormat
+			}
+
+			c.lastDay, c.lastDayOffset = true, offset
+		case item == "LW":
+			c.lastWeekday = true
+		case strings.HasSuffix(item, "W"):
+			day, err := strconv.Atoi(strings.TrimSuffix(item, "W"))
+
+			if err != nil || day < 1 || day > 31 {
+				return ErrBadFormat
+			}
+
+			c.nearestWeekday = append(c.nearestWeekday, day)
+		default:
+			items = append(items, item)
+		}
+	}
+
+	if len(items) == 0 {
+		return nil
+	}
+
+	var err error
+
+	c.DayOfMonth, err = cronFieldFromString(strings.Join(items, ","), dayOfMonthBounds)
+
+	return err
+}
+
+func (c *CronSchedule) parseDayOfWeek(field string) error {
+	if field == "*" || field == "?" {
+		c.dowStar = true
+		c.DayOfWeek = cronRange(0, 6)
+
+		return nil
+	}
+
+	var items []string
+
+	for _, item := range strings.Split(field, ",") {
+		switch {
+		case item == "L":
+			c.DayOfWeek |= 1 << uint(time.Saturday)
+		case strings.HasSuffix(item, "L"):
+			weekday, err := cronValueFromString(strings.TrimSuffix(item, "L"), dayOfWeekBounds)
+
+			if err != nil {
+				return err
+			}
+
+			c.lastOfWeek |= 1 << uint(weekday%7)
+		case strings.Contains(item, "#"):
+			pair := strings.SplitN(item, "#", 2)
+			weekday, err := cronValueFromString(pair[0], dayOfWeekBounds)
+
+			if err != nil {
+				return err
+			}
+
+			n, err := strconv.Atoi(pair[1])
+
+			if err != nil || n < 1 || n > 5 {
+				return ErrBadFormat
+			}
+
+			c.nthOfWeek = append(c.nthOfWeek, nthWeekday{time.Weekday(weekday % 7), n})
+		default:
+			items = append(items, item)
+		}
+	}
+
+	if len(items) == 0 {
+		return nil
+	}
+
+	bits, err := cronFieldFromString(strings.Join(items, ","), dayOfWeekBounds)
+
+	if err != nil {
+		return err
+	}
+
+	// 7 is Sunday as well
+	if bits&(1<<7) != 0 {
+		bits = bits&^(1<<7) | 1
+	}
+
+	c.DayOfWeek |= bits
+
+	return nil
+}
+
+// cronFieldFromString parses a list of values, ranges and steps into a bit
+// set.
+func cronFieldFromString(field string, bounds cronBounds) (uint64, error) {
+	var bits uint64
+
+	for _, item := range strings.Split(field, ",") {
+		rangePart, step := item, 1
+
+		if slash := strings.Index(item, "/"); slash >= 0 {
+			var err error
+
+			rangePart = item[:slash]
+			step, err = strconv.Atoi(item[slash+1:])
+
+			if err != nil || step < 1 {
+				return 0, ErrBadFormat
+			}
+		}
+
+		var (
+			from, to int
+			err      error
+		)
+
+		switch {
+		case rangePart == "*":
+			from, to = bounds.min, bounds.max
+		case strings.Contains(rangePart, "-"):
+			pair := strings.SplitN(rangePart, "-", 2)
+
+			if from, err = cronValueFromString(pair[0], bounds); err != nil {
+				return 0, err
+			}
+
+			if to, err = cronValueFromString(pair[1], bounds); err != nil {
+				return 0, err
+			}
+
+			if to < from {
+				return 0, ErrBadFormat
+			}
+		default:
+			if from, err = cronValueFromString(rangePart, bounds); err != nil {
+				return 0, err
+			}
+
+			to = from
+
+			if step > 1 {
+				to = bounds.max
+			}
+		}
+
+		for v := from; v <= to; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, nil
+}
+
+func cronValueFromString(value string, bounds cronBounds) (int, error) {
+	if v, ok := bounds.names[value]; ok {
+		return v, nil
+	}
+
+	v, err := strconv.Atoi(value)
+
+	if err != nil || v < bounds.min || v > bounds.max {
+		return 0, ErrBadFormat
+	}
+
+	return v, nil
+}
+
+func cronRange(from, to int) uint64 {
+	var bits uint64
+
+	for v := from; v <= to; v++ {
+		bits |= 1 << uint(v)
+	}
+
+	return bits
+}
+
+// String returns the expression as given, with runs of white space collapsed.
+func (c CronSchedule) String() string {
+	return c.expression
+}
+
+func (c CronSchedule) location() *time.Location {
+	if c.Location == nil {
+		return time.UTC
+	}
+
+	return c.Location
+}
+
+// Next returns the earliest time strictly after the given one matching the
+// expression, or false when none does within the next horizonYears years.
+func (c CronSchedule) Next(after time.Time) (time.Time, bool) {
+	if c.Every > 0 {
+		return time.Unix(0, 0).Add((after.Sub(time.Unix(0, 0))/c.Every + 1) * c.Every).UTC(), true
+	}
+
+	loc := c.location()
+	// earlier wall times may happen again after a clock set back or be
+	// shifted past after out of a gap
+	t := floating(after.In(loc)).Add(-dstShift(after, loc)).Truncate(time.Second)
+
+	horizon := t.AddDate(horizonYears, 0, 0)
+
+	var (
+		best  time.Time
+		found bool
+	)
+
+	for t.Before(horizon) {
+		if found && t.After(floating(best.In(loc)).Add(dstShift(best, loc))) {
+			// wall times this far on cannot happen before the one found
+			return best, true
+		}
+
+		if c.Month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+
+		if !c.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+
+		if c.Hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), nextBit(c.Hour, t.Hour(), 24), 0, 0, 0, time.UTC)
+			continue
+		}
+
+		if c.Minute&(1<<uint(t.Minute())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), nextBit(c.Minute, t.Minute(), 60), 0, 0, time.UTC)
+			continue
+		}
+
+		if c.Second&(1<<uint(t.Second())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), nextBit(c.Second, t.Second(), 60), 0, time.UTC)
+			continue
+		}
+
+		// around a transition a later wall time may happen first, such as
+		// 2:15 of the first pass through a repeated hour after 2:00 of the
+		// second
+		for _, instant := range resolveWallTime(t, loc, c.DSTGap, c.DSTOverlap) {
+			if instant.After(after) && (!found || instant.Before(best)) {
+				best, found = instant, true
+			}
+		}
+
+		t = t.Add(time.Second)
+	}
+
+	return best, found
+}
+
+// matchesDay applies the day of month and day of week fields to the date.
+func (c CronSchedule) matchesDay(t time.Time) bool {
+	dom, dow := c.matchesDayOfMonth(t), c.matchesDayOfWeek(t)
+
+	switch {
+	case c.domStar && c.dowStar:
+		return true
+	case c.domStar:
+		return dow
+	case c.dowStar:
+		return dom
+	default:
+		return dom || dow
+	}
+}
+
+func (c CronSchedule) matchesDayOfMonth(t time.Time) bool {
+	day := t.Day()
+	last := time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+
+	if c.DayOfMonth&(1<<uint(day)) != 0 {
+		return true
+	}
+
+	if c.lastDay && day == last-c.lastDayOffset {
+		return true
+	}
+
+	if c.lastWeekday && day == nearestWeekday(t.Year(), t.Month(), last) {
+		return true
+	}
+
+	for _, target := range c.nearestWeekday {
+		if target <= last && day == nearestWeekday(t.Year(), t.Month(), target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c CronSchedule) matchesDayOfWeek(t time.Time) bool {
+	weekday := t.Weekday()
+
+	if c.DayOfWeek&(1<<uint(weekday)) != 0 {
+		return true
+	}
+
+	last := time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+
+	if c.lastOfWeek&(1<<uint(weekday)) != 0 && t.Day()+7 > last {
+		return true
+	}
+
+	for _, nth := range c.nthOfWeek {
+		if nth.weekday == weekday && (t.Day()-1)/7+1 == nth.n {
+			return true
+		}
+	}
+
+	return false
+}
+
+// nextBit returns the lowest set bit above from, or limit when there is none
+// so the time rolls over into the next unit.
+func nextBit(set uint64, from, limit int) int {
+	if above := set >> uint(from+1) << uint(from+1); above != 0 {
+		if v := bits.TrailingZeros64(above); v < limit {
+			return v
+		}
+	}
+
+	return limit
+}
+
+// nearestWeekday returns the Monday to Friday day of the month closest to
+// the given one without leaving the month.
+func nearestWeekday(year int, month time.Month, day int) int {
+	last := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+
+	switch time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Weekday() {
+	case time.Saturday:
+		if day == 1 {
+			return day + 2
+		}
+
+		return day - 1
+	case time.Sunday:
+		if day == last {
+			return day - 2
+		}
+
+		return day + 1
+	}
+
+	return day
+}
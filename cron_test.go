@@ -0,0 +1,167 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronFromString(t *testing.T) {
+	t.Parallel()
+
+	var formatTests = []struct {
+		Expression    string
+		FormatCorrect bool
+	}{
+		/* correct formats */
+		{"* * * * *", true},
+		{"*/15 9-17 * * MON-FRI", true},
+		{"0 30 8 * * 1-5", true},
+		{"0 0 L * ?", true},
+		{"0 0 L-3,15W * *", true},
+		{"0 12 ? * 5L", true},
+		{"0 12 ? JAN,JUL 1#1", true},
+		{"@daily", true},
+		{"@every 90s", true},
+		{"CRON_TZ=Europe/Berlin 0 9 * * *", true},
+		/* incorrect formats */
+		{"* * * *", false},                   //too few fields
+		{"60 * * * *", false},                //minute out of range
+		{"* 24 * * *", false},                //hour out of range
+		{"* * 0 * *", false},                 //day of month out of range
+		{"* * * 13 *", false},                //month out of range
+		{"* * * * 8", false},                 //day of week out of range
+		{"5-1 * * * *", false},               //reversed range
+		{"*/0 * * * *", false},               //zero step
+		{"0 0 32W * *", false},               //nearest weekday out of range
+		{"0 0 * * 1#6", false},               //no sixth weekday
+		{"@fortnightly", false},              //unknown descriptor
+		{"@every 500ms", false},              //too short
+		{"@every soon", false},               //bad duration
+		{"CRON_TZ=Nowhere 0 9 * * *", false}, //unknown zone
+	}
+
+	for index, test := range formatTests {
+		_, err := CronFromString(test.Expression, time.UTC)
+
+		if test.FormatCorrect && err != nil {
+			t.Errorf("Test %d expected correct format but got error %#v", index+1, err)
+		} else if !test.FormatCorrect && err == nil {
+			t.Errorf("Test %d expected incorrect format", index+1)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		Expression string
+		After      time.Time
+		Expected   []string
+	}{
+		{"*/15 * * * *", now.Add(time.Minute), []string{"2017-01-01T00:15:00Z", "2017-01-01T00:30:00Z", "2017-01-01T00:45:00Z"}},
+		{"30 8 * * MON-FRI", now, []string{"2017-01-02T08:30:00Z", "2017-01-03T08:30:00Z", "2017-01-04T08:30:00Z"}},
+		{"15 30 8 * * 1-5", now, []string{"2017-01-02T08:30:15Z", "2017-01-03T08:30:15Z", "2017-01-04T08:30:15Z"}},
+		{"@hourly", now, []string{"2017-01-01T01:00:00Z", "2017-01-01T02:00:00Z", "2017-01-01T03:00:00Z"}},
+		{"@monthly", now, []string{"2017-02-01T00:00:00Z", "2017-03-01T00:00:00Z", "2017-04-01T00:00:00Z"}},
+		{"@every 90s", now, []string{"2017-01-01T00:01:30Z", "2017-01-01T00:03:00Z", "2017-01-01T00:04:30Z"}},
+		{"* * * * * *", now.Add(500 * time.Millisecond), []string{"2017-01-01T00:00:01Z", "2017-01-01T00:00:02Z", "2017-01-01T00:00:03Z"}},
+		// last day of month, and three days before it
+		{"0 0 L * *", now, []string{"2017-01-31T00:00:00Z", "2017-02-28T00:00:00Z", "2017-03-31T00:00:00Z"}},
+		{"0 0 L-3 * *", now, []string{"2017-01-28T00:00:00Z", "2017-02-25T00:00:00Z", "2017-03-28T00:00:00Z"}},
+		// last weekday of month and weekday nearest to the 1st
+		{"0 0 LW * *", now, []string{"2017-01-31T00:00:00Z", "2017-02-28T00:00:00Z", "2017-03-31T00:00:00Z"}},
+		{"0 0 1W * *", now, []string{"2017-01-02T00:00:00Z", "2017-02-01T00:00:00Z", "2017-03-01T00:00:00Z"}},
+		// last Friday and third Friday of the month
+		{"0 12 ? * 5L", now, []string{"2017-01-27T12:00:00Z", "2017-02-24T12:00:00Z", "2017-03-31T12:00:00Z"}},
+		{"0 12 ? * FRI#3", now, []string{"2017-01-20T12:00:00Z", "2017-02-17T12:00:00Z", "2017-03-17T12:00:00Z"}},
+		// both day fields restricted fire on either
+		{"0 0 13 * 5", now, []string{"2017-01-06T00:00:00Z", "2017-01-13T00:00:00Z", "2017-01-20T00:00:00Z"}},
+		// leap day
+		{"0 0 29 2 *", now, []string{"2020-02-29T00:00:00Z", "2024-02-29T00:00:00Z", "2028-02-29T00:00:00Z"}},
+		// never
+		{"0 0 30 2 *", now, nil},
+	}
+
+	for index, test := range tests {
+		cron, err := CronFromString(test.Expression, time.UTC)
+
+		if err != nil {
+			t.Fatalf("Test %d expected correct expression but got error %#v", index+1, err)
+		}
+
+		result := nextOccurrences(cron, test.After, 3)
+
+		if len(result) != len(test.Expected) {
+			t.Errorf("Test %d expected %v but got %v", index+1, test.Expected, result)
+			continue
+		}
+
+		for i := range result {
+			if result[i] != test.Expected[i] {
+				t.Errorf("Test %d expected %v but got %v", index+1, test.Expected, result)
+				break
+			}
+		}
+	}
+}
+
+func TestCronInLocation(t *testing.T) {
+	t.Parallel()
+
+	if _, err := time.LoadLocation("Europe/Berlin"); err != nil {
+		t.Skipf("No time zone database: %s", err)
+	}
+
+	var tests = []struct {
+		Expression string
+		Overlap    OverlapPolicy
+		After      time.Time
+		Expected   []string
+	}{
+		{"CRON_TZ=Europe/Berlin 0 9 * * *", OverlapOnce, time.Date(2017, 3, 25, 0, 0, 0, 0, time.UTC), []string{"2017-03-25T08:00:00Z", "2017-03-26T07:00:00Z", "2017-03-27T07:00:00Z"}},
+		{"CRON_TZ=Europe/Berlin 30 2 * * *", OverlapOnce, time.Date(2017, 10, 28, 12, 0, 0, 0, time.UTC), []string{"2017-10-29T00:30:00Z", "2017-10-30T01:30:00Z", "2017-10-31T01:30:00Z"}},
+		{"CRON_TZ=Europe/Berlin 30 2 * * *", OverlapTwice, time.Date(2017, 10, 28, 12, 0, 0, 0, time.UTC), []string{"2017-10-29T00:30:00Z", "2017-10-29T01:30:00Z", "2017-10-30T01:30:00Z"}},
+		{"CRON_TZ=Europe/Berlin 30 2 * * *", OverlapOnce, time.Date(2017, 3, 25, 12, 0, 0, 0, time.UTC), []string{"2017-03-26T01:30:00Z", "2017-03-27T00:30:00Z", "2017-03-28T00:30:00Z"}},
+		// after 2:30 of the first pass through the repeated hour, 2:15
+		// comes again
+		{"CRON_TZ=Europe/Berlin 15 2 * * *", OverlapTwice, time.Date(2017, 10, 29, 0, 30, 0, 0, time.UTC), []string{"2017-10-29T01:15:00Z", "2017-10-30T01:15:00Z"}},
+		// both passes through the repeated hour, every quarter
+		{"CRON_TZ=Europe/Berlin */15 2 * * *", OverlapTwice, time.Date(2017, 10, 28, 12, 0, 0, 0, time.UTC), []string{"2017-10-29T00:00:00Z", "2017-10-29T00:15:00Z", "2017-10-29T00:30:00Z", "2017-10-29T00:45:00Z", "2017-10-29T01:00:00Z", "2017-10-29T01:15:00Z", "2017-10-29T01:30:00Z", "2017-10-29T01:45:00Z", "2017-10-30T01:00:00Z"}},
+		{"CRON_TZ=Europe/Berlin */30 * * * *", OverlapTwice, time.Date(2017, 10, 28, 23, 45, 0, 0, time.UTC), []string{"2017-10-29T00:00:00Z", "2017-10-29T00:30:00Z", "2017-10-29T01:00:00Z", "2017-10-29T01:30:00Z", "2017-10-29T02:00:00Z"}},
+		// after 3:10, 2:30 shifted out of the gap to 3:30
+		{"CRON_TZ=Europe/Berlin 30 2 * * *", OverlapOnce, time.Date(2017, 3, 26, 1, 10, 0, 0, time.UTC), []string{"2017-03-26T01:30:00Z", "2017-03-27T00:30:00Z"}},
+	}
+
+	for index, test := range tests {
+		cron, err := CronFromString(test.Expression, time.UTC)
+
+		if err != nil {
+			t.Fatalf("Test %d expected correct expression but got error %#v", index+1, err)
+		}
+
+		cron.DSTOverlap = test.Overlap
+		result := nextOccurrences(cron, test.After, len(test.Expected))
+
+		for i := range test.Expected {
+			if i >= len(result) || result[i] != test.Expected[i] {
+				t.Errorf("Test %d expected %v but got %v", index+1, test.Expected, result)
+				break
+			}
+		}
+	}
+}
+
+func BenchmarkCronNext(b *testing.B) {
+	cron, err := CronFromString("* * * * * *", time.UTC)
+
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		cron.Next(now)
+	}
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// DateFromString parses ISO 8601 date or date and time in UTC.
+func DateFromString(dateString string) (time.Time, error) {
+	return DateFromStringIn(dateString, time.UTC)
+}
+
+// DateFromStringIn parses ISO 8601 date or date and time, as a wall time in
+// the given location unless it carries Z or an offset. Both basic and
+// extended formats are accepted for
+//
+//	calendar dates  2017-02-01, 20170201, 2017-02 and 2017
+//	ordinal dates   2017-032, 2017032
+//	week dates      2017-W05-3, 2017W053, 2017-W05
+//
+// optionally followed by T and hh, hh:mm or hh:mm:ss (hhmm, hhmmss) with a
+// decimal fraction on the last component, then Z, ±hh, ±hh:mm or ±hhmm.
+func DateFromStringIn(dateString string, loc *time.Location) (time.Time, error) {
+	datePart, timePart := dateString, ""
+	hasTime := false
+
+	if t := strings.IndexByte(dateString, 'T'); t >= 0 {
+		datePart, timePart, hasTime = dateString[:t], dateString[t+1:], true
+	}
+
+	year, month, day, err := datePartFromString(datePart)
+
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if !hasTime {
+		return time.Date(year, month, day, 0, 0, 0, 0, loc), nil
+	}
+
+	if timePart == "" {
+		return time.Time{}, newParseError(len(datePart), "T", ReasonBadDate)
+	}
+
+	clock, err := timePartFromString(timePart)
+
+	if err != nil {
+		return time.Time{}, err.inComponent(0, len(datePart)+1)
+	}
+
+	if clock.zoned {
+		utc := time.Date(year, month, day, clock.hour, clock.minute, clock.second, clock.nanosecond, time.UTC)
+
+		return utc.Add(-time.Duration(clock.offset) * time.Second).In(loc), nil
+	}
+
+	return time.Date(year, month, day, clock.hour, clock.minute, clock.second, clock.nanosecond, loc), nil
+}
+
+// datePartFromString parses the calendar, ordinal or week date before T.
+func datePartFromString(date string) (int, time.Month, int, *ParseError) {
+	year, ok := fixedDigits(date, 0, 4)
+
+	if !ok {
+		return 0, 0, 0, newParseError(0, date, ReasonBadDate)
+	}
+
+	pos := 4
+	extended := len(date) > pos && date[pos] == '-'
+
+	if extended {
+		pos++
+	}
+
+	rest := date[pos:]
+
+	switch {
+	case rest == "" && !extended:
+		return year, time.January, 1, nil
+	case strings.HasPrefix(rest, "W"):
+		week, ok := fixedDigits(date, pos+1, 2)
+
+		if !ok {
+			return 0, 0, 0, newParseError(pos, rest, ReasonBadDate)
+		}
+
+		pos += 3
+		weekday := 1
+
+		if len(date) > pos {
+			if extended && date[pos] != '-' {
+				return 0, 0, 0, newParseError(pos, date[pos:], ReasonBadDate)
+			} else if extended {
+				pos++
+			}
+
+			if weekday, ok = fixedDigits(date, pos, 1); !ok || len(date) != pos+1 {
+				return 0, 0, 0, newParseError(pos, date[pos:], ReasonBadDate)
+			}
+		}
+
+		if _, weeks := time.Date(year, 12, 28, 0, 0, 0, 0, time.UTC).ISOWeek(); week < 1 || week > weeks || weekday < 1 || weekday > 7 {
+			return 0, 0, 0, newParseError(0, date, ReasonOutOfRange)
+		}
+
+		// week 1 is the one with 4 January in it
+		jan4 := time.Date(year, 1, 4, 0, 0, 0, 0, time.UTC)
+		monday := jan4.AddDate(0, 0, -((int(jan4.Weekday()) + 6) % 7))
+		t := monday.AddDate(0, 0, (week-1)*7+weekday-1)
+
+		return t.Year(), t.Month(), t.Day(), nil
+	case len(rest) == 3:
+		ordinal, ok := fixedDigits(date, pos, 3)
+
+		if !ok {
+			return 0, 0, 0, newParseError(pos, rest, ReasonBadDate)
+		}
+
+		if ordinal < 1 || ordinal > time.Date(year, 12, 31, 0, 0, 0, 0, time.UTC).YearDay() {
+			return 0, 0, 0, newParseError(pos, rest, ReasonOutOfRange)
+		}
+
+		t := time.Date(year, 1, ordinal, 0, 0, 0, 0, time.UTC)
+
+		return t.Year(), t.Month(), t.Day(), nil
+	}
+
+	month, ok := fixedDigits(date, pos, 2)
+
+	if !ok {
+		return 0, 0, 0, newParseError(pos, rest, ReasonBadDate)
+	}
+
+	pos += 2
+	day := 1
+
+	switch {
+	case extended && len(date) == pos:
+		// reduced to year and month
+	case extended && date[pos] == '-' && len(date) == pos+3:
+		day, ok = fixedDigits(date, pos+1, 2)
+	case !extended && len(date) == pos+2:
+		day, ok = fixedDigits(date, pos, 2)
+	default:
+		ok = false
+	}
+
+	if !ok {
+		return 0, 0, 0, newParseError(pos, date[pos:], ReasonBadDate)
+	}
+
+	if month < 1 || month > 12 || day < 1 || day > time.Date(year, time.Month(month)+1, 0, 0, 0, 0, 0, time.UTC).Day() {
+		return 0, 0, 0, newParseError(0, date, ReasonOutOfRange)
+	}
+
+	return year, time.Month(month), day, nil
+}
+
+// wallClock is a time of day as written, with the offset from UTC in seconds
+// of its zone when it has one.
+type wallClock struct {
+	hour, minute, second, nanosecond int
+	offset                           int
+	zoned                            bool
+}
+
+// newWallClock splits the elapsed time since midnight into a time of day.
+func newWallClock(elapsed time.Duration, offset int, zoned bool) wallClock {
+	return wallClock{
+		hour:       int(elapsed / time.Hour),
+		minute:     int(elapsed % time.Hour / time.Minute),
+		second:     int(elapsed % time.Minute / time.Second),
+		nanosecond: int(elapsed % time.Second),
+		offset:     offset,
+		zoned:      zoned,
+	}
+}
+
+// timePartFromString parses the time after T into a time of day, read on the
+// wall clock rather than as time elapsed since midnight, which differs on DST
+// transition days.
+func timePartFromString(clock string) (wallClock, *ParseError) {
+	zoneAt := strings.IndexAny(clock, "Z+-")
+	offset := 0
+
+	if zoneAt >= 0 {
+		var err *ParseError
+
+		if offset, err = zoneFromString(clock[zoneAt:]); err != nil {
+			return wallClock{}, err.inComponent(0, zoneAt)
+		}
+
+		clock = clock[:zoneAt]
+	}
+
+	units := []time.Duration{time.Hour, time.Minute, time.Second}
+	limits := []int{23, 59, 59}
+	extended := len(clock) > 2 && clock[2] == ':'
+	elapsed := time.Duration(0)
+	pos := 0
+
+	for i, unit := range units {
+		if i > 0 {
+			if pos == len(clock) || clock[pos] == '.' || clock[pos] == ',' {
+				break
+			}
+
+			if extended && clock[pos] != ':' {
+				return wallClock{}, newParseError(pos, clock[pos:], ReasonBadDate)
+			} else if extended {
+				pos++
+			}
+		}
+
+		val, ok := fixedDigits(clock, pos, 2)
+
+		if !ok {
+			return wallClock{}, newParseError(pos, clock[pos:], ReasonBadDate)
+		}
+
+		if val > limits[i] {
+			return wallClock{}, newParseError(pos, clock[pos:pos+2], ReasonOutOfRange)
+		}
+
+		elapsed += time.Duration(val) * unit
+		pos += 2
+
+		if pos < len(clock) && (clock[pos] == '.' || clock[pos] == ',') {
+			fraction, ok := fractionOf(clock[pos+1:], unit)
+
+			if !ok {
+				return wallClock{}, newParseError(pos, clock[pos:], ReasonBadDate)
+			}
+
+			return newWallClock(elapsed+fraction, offset, zoneAt >= 0), nil
+		}
+	}
+
+	if pos != len(clock) {
+		return wallClock{}, newParseError(pos, clock[pos:], ReasonBadDate)
+	}
+
+	return newWallClock(elapsed, offset, zoneAt >= 0), nil
+}
+
+// zoneFromString parses Z, ±hh, ±hh:mm or ±hhmm into an offset from UTC in
+// seconds.
+func zoneFromString(zone string) (int, *ParseError) {
+	if zone == "Z" {
+		return 0, nil
+	}
+
+	hours, ok := fixedDigits(zone, 1, 2)
+	minutes := 0
+
+	switch {
+	case !ok || zone[0] == 'Z':
+	case len(zone) == 3:
+	case len(zone) == 6 && zone[3] == ':':
+		minutes, ok = fixedDigits(zone, 4, 2)
+	case len(zone) == 5:
+		minutes, ok = fixedDigits(zone, 3, 2)
+	default:
+		ok = false
+	}
+
+	if !ok || zone[0] == 'Z' {
+		return 0, newParseError(0, zone, ReasonBadDate)
+	}
+
+	if hours > 23 || minutes > 59 {
+		return 0, newParseError(0, zone, ReasonOutOfRange)
+	}
+
+	offset := hours*3600 + minutes*60
+
+	if zone[0] == '-' {
+		offset = -offset
+	}
+
+	return offset, nil
+}
+
+// fixedDigits reads exactly n decimal digits at the given position.
+func fixedDigits(s string, at, n int) (int, bool) {
+	if at+n > len(s) {
+		return 0, false
+	}
+
+	val := 0
+
+	for _, c := range []byte(s[at : at+n]) {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+
+		val = val*10 + int(c-'0')
+	}
+
+	return val, true
+}
+
+// fractionOf returns the decimal fraction given by the digits of the unit,
+// truncated to nanoseconds.
+func fractionOf(digits string, unit time.Duration) (time.Duration, bool) {
+	if digits == "" {
+		return 0, false
+	}
+
+	fraction, scale := time.Duration(0), unit
+
+	for _, c := range []byte(digits) {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+
+		scale /= 10
+		fraction += time.Duration(c-'0') * scale
+	}
+
+	return fraction, true
+}
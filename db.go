@@ -2,35 +2,157 @@ package main
 
 import (
 	"database/sql"
+	"fmt"
 	_ "github.com/mattn/go-sqlite3"
 	"io/ioutil"
 	"os"
 )
 
+// migrations bring a database created from an earlier db.sql up to date, the
+// n-th one moving it from user_version n to n+1. A new database is created
+// from db.sql at the latest version. Widening the rule column to TEXT needs
+// none, as SQLite does not enforce VARCHAR lengths.
+var migrations = []string{
+	// repetitions left
+	`alter table tasks add column remaining INT;`,
+	// DST policies
+	`alter table tasks add column dstGap VARCHAR(8) default 'shift' not null;
+	alter table tasks add column dstOverlap VARCHAR(8) default 'once' not null;`,
+	// month end policy
+	`alter table tasks add column monthEnd VARCHAR(8) default 'clamp' not null;`,
+	// excluded and extra dates
+	`create table tasksExDates
+	(
+	  taskId VARCHAR(64) not null
+	    constraint tasksExDates_tasks_id_fk
+	    references tasks (id)
+	      on delete cascade,
+	  fireAt DATETIME not null
+	);
+	create unique index tasksExDates_taskId_fireAt_uindex
+	  on tasksExDates (taskId, fireAt);
+	create table tasksRDates
+	(
+	  taskId VARCHAR(64) not null
+	    constraint tasksRDates_tasks_id_fk
+	    references tasks (id)
+	      on delete cascade,
+	  fireAt DATETIME not null
+	);
+	create unique index tasksRDates_taskId_fireAt_uindex
+	  on tasksRDates (taskId, fireAt);`,
+	// holiday calendars
+	`alter table tasks add column calendar VARCHAR(64) default '' not null;
+	alter table tasks add column roll VARCHAR(20) default 'following' not null;
+	create table calendars
+	(
+	  name VARCHAR(64) not null
+	    primary key,
+	  weekend VARCHAR(16) default '6,0' not null
+	);
+	create table calendarsHolidays
+	(
+	  calendar VARCHAR(64) not null
+	    constraint calendarsHolidays_calendars_name_fk
+	    references calendars (name)
+	      on delete cascade,
+	  day VARCHAR(10) not null
+	);
+	create unique index calendarsHolidays_calendar_day_uindex
+	  on calendarsHolidays (calendar, day);`,
+	// jitter
+	`alter table tasks add column jitter VARCHAR(32) default '' not null;`,
+	// compiled schedule version
+	`alter table tasks add column version INT default 1 not null;`,
+	// misfire policies, with run ids assigned by SQLite and task ids as text
+	`alter table tasks add column misfire VARCHAR(10) default 'fire-once' not null;
+	alter table tasks add column maxCatchUp INT default 10 not null;
+	create table tasksRunsMigrated
+	(
+	  id INTEGER not null
+	    primary key,
+	  taskId VARCHAR(64) not null
+	    constraint tasksRuns_tasks_id_fk
+	    references tasks (id)
+	      on delete cascade,
+	  status INT not null,
+	  runAt DATETIME not null,
+	  finishedAt DATETIME
+	);
+	insert into tasksRunsMigrated (id, taskId, status, runAt, finishedAt)
+	  select id, taskId, status, runAt, finishedAt from tasksRuns;
+	drop table tasksRuns;
+	alter table tasksRunsMigrated rename to tasksRuns;
+	create unique index tasksRuns_id_uindex
+	  on tasksRuns (id);`,
+}
+
 func initDb(filename string) (*sql.DB, error) {
-	if _, err := os.Stat(filename); os.IsNotExist(err) {
-		bytes, err := ioutil.ReadFile("db.sql")
+	_, err := os.Stat(filename)
+	create := os.IsNotExist(err)
 
-		if err != nil {
-			return nil, err
-		}
+	db, err := sql.Open("sqlite3", filename)
 
-		dbInitStatement := string(bytes[:])
+	if err != nil {
+		return nil, err
+	}
+
+	if !create {
+		return db, migrate(db)
+	}
 
-		db, err := sql.Open("sqlite3", filename)
+	bytes, err := ioutil.ReadFile("db.sql")
+
+	if err != nil {
+		return nil, err
+	}
+
+	dbInitStatement := string(bytes[:])
+
+	_, err = db.Exec(dbInitStatement)
+
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec(fmt.Sprintf("pragma user_version = %d", len(migrations)))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// migrate runs the migrations past the user_version of the database, each in
+// a transaction of its own together with the version it moves to.
+func migrate(db *sql.DB) error {
+	var version int
+
+	if err := db.QueryRow("pragma user_version").Scan(&version); err != nil {
+		return err
+	}
+
+	for ; version < len(migrations); version++ {
+		tx, err := db.Begin()
 
 		if err != nil {
-			return nil, err
+			return err
 		}
 
-		_, err = db.Exec(dbInitStatement)
+		if _, err = tx.Exec(migrations[version]); err == nil {
+			_, err = tx.Exec(fmt.Sprintf("pragma user_version = %d", version+1))
+		}
 
 		if err != nil {
-			return nil, err
+			tx.Rollback()
+			return err
 		}
 
-		return db, nil
-	} else {
-		return sql.Open("sqlite3", filename)
+		if err = tx.Commit(); err != nil {
+			return err
+		}
 	}
+
+	return nil
 }
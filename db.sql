@@ -2,11 +2,21 @@ create table tasks
 (
   id VARCHAR(64) not null
     primary key,
-  rule VARCHAR(64) not null,
+  rule TEXT not null,
   timeZone VARCHAR(32) not null,
+  dstGap VARCHAR(8) default 'shift' not null,
+  dstOverlap VARCHAR(8) default 'once' not null,
+  monthEnd VARCHAR(8) default 'clamp' not null,
+  calendar VARCHAR(64) default '' not null,
+  roll VARCHAR(20) default 'following' not null,
+  jitter VARCHAR(32) default '' not null,
+  version INT default 1 not null,
   epsilon INT default 60 not null,
+  misfire VARCHAR(10) default 'fire-once' not null,
+  maxCatchUp INT default 10 not null,
   nextFireAt DATETIME,
   nextRetryAt DATETIME,
+  remaining INT,
   maxRetries INT default 3 not null,
   completed BOOLEAN default FALSE not null
 )
@@ -16,10 +26,61 @@ create unique index task_id_uindex
   on tasks (id)
 ;
 
+create table tasksExDates
+(
+  taskId VARCHAR(64) not null
+    constraint tasksExDates_tasks_id_fk
+    references tasks (id)
+      on delete cascade,
+  fireAt DATETIME not null
+)
+;
+
+create unique index tasksExDates_taskId_fireAt_uindex
+  on tasksExDates (taskId, fireAt)
+;
+
+create table tasksRDates
+(
+  taskId VARCHAR(64) not null
+    constraint tasksRDates_tasks_id_fk
+    references tasks (id)
+      on delete cascade,
+  fireAt DATETIME not null
+)
+;
+
+create unique index tasksRDates_taskId_fireAt_uindex
+  on tasksRDates (taskId, fireAt)
+;
+
+create table calendars
+(
+  name VARCHAR(64) not null
+    primary key,
+  weekend VARCHAR(16) default '6,0' not null
+)
+;
+
+create table calendarsHolidays
+(
+  calendar VARCHAR(64) not null
+    constraint calendarsHolidays_calendars_name_fk
+    references calendars (name)
+      on delete cascade,
+  day VARCHAR(10) not null
+)
+;
+
+create unique index calendarsHolidays_calendar_day_uindex
+  on calendarsHolidays (calendar, day)
+;
+
 create table tasksRuns
 (
-  id INTEGER not null,
-  taskId INT not null
+  id INTEGER not null
+    primary key,
+  taskId VARCHAR(64) not null
     constraint tasksRuns_tasks_id_fk
     references tasks (id)
       on delete cascade,
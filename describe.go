@@ -0,0 +1,82 @@
+package main
+
+import "strings"
+
+// DefaultLocale is the locale descriptions fall back to.
+const DefaultLocale = "en"
+
+// Locale renders schedules as text in one language.
+type Locale interface {
+	Recurrence(r Recurrence) string
+	RRule(r RRule) string
+	Cron(c CronSchedule) string
+	// Offset describes an offset schedule given the description of its base.
+	Offset(o OffsetSchedule, base string) string
+	// Roll describes a schedule rolled off the non-working days of a
+	// calendar given the description of the schedule.
+	Roll(s RolledSchedule, base string) string
+	// Composite describes a combination of two schedules given their
+	// descriptions.
+	Composite(c CompositeSchedule, left, right string) string
+	// Jitter describes a schedule delayed by a jitter offset given the
+	// description of the schedule.
+	Jitter(s JitteredSchedule, base string) string
+	// Set describes a schedule with excluded and extra dates given the
+	// description of the schedule.
+	Set(s RecurrenceSet, base string) string
+}
+
+var locales = map[string]Locale{
+	DefaultLocale: English{},
+}
+
+// RegisterLocale makes the locale available under the given language tag, e.g.
+// "de" or "pt-BR". It is meant to be called from init functions.
+func RegisterLocale(tag string, locale Locale) {
+	locales[strings.ToLower(tag)] = locale
+}
+
+// LocaleFor returns the locale registered for the tag, falling back to its
+// base language and then to DefaultLocale.
+func LocaleFor(tag string) Locale {
+	tag = strings.ToLower(tag)
+
+	if locale, ok := locales[tag]; ok {
+		return locale
+	}
+
+	if dash := strings.IndexAny(tag, "-_"); dash > 0 {
+		if locale, ok := locales[tag[:dash]]; ok {
+			return locale
+		}
+	}
+
+	return locales[DefaultLocale]
+}
+
+// Describe returns a human readable description of the schedule in the locale
+// with the given tag, and the canonical rule for schedules no locale knows.
+func Describe(schedule Schedule, tag string) string {
+	locale := LocaleFor(tag)
+
+	switch s := schedule.(type) {
+	case Recurrence:
+		return locale.Recurrence(s)
+	case RRule:
+		return locale.RRule(s)
+	case CronSchedule:
+		return locale.Cron(s)
+	case OffsetSchedule:
+		return locale.Offset(s, Describe(s.Base, tag))
+	case RolledSchedule:
+		return locale.Roll(s, Describe(s.Base, tag))
+	case CompositeSchedule:
+		return locale.Composite(s, Describe(s.Left, tag), Describe(s.Right, tag))
+	case JitteredSchedule:
+		return locale.Jitter(s, Describe(s.Base, tag))
+	case RecurrenceSet:
+		return locale.Set(s, Describe(s.Schedule, tag))
+	}
+
+	return schedule.String()
+}
@@ -0,0 +1,569 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// English describes schedules in English, e.g. "every 2 weeks, 10 times,
+// starting 1 Jan 2017 09:00 Europe/Berlin".
+type English struct{}
+
+var rruleUnits = map[Frequency]string{
+	Yearly:   "year",
+	Monthly:  "month",
+	Weekly:   "week",
+	Daily:    "day",
+	Hourly:   "hour",
+	Minutely: "minute",
+}
+
+// Recurrence describes an ISO 8601 repeating interval.
+func (English) Recurrence(r Recurrence) string {
+	var parts []string
+
+	switch {
+	case r.Duration != nil:
+		parts = append(parts, englishEvery(*r.Duration))
+	case r.Start != nil && r.End != nil:
+		step := r.End.Sub(*r.Start)
+		day := 24 * time.Hour
+
+		parts = append(parts, englishEvery(RecurrenceInterval{Days: int(step / day), Nanoseconds: int(step % day)}))
+	}
+
+	if r.Repetitions >= 0 {
+		parts = append(parts, englishTimes(r.Repetitions))
+	}
+
+	if r.Start != nil {
+		parts = append(parts, "starting "+englishDate(*r.Start, r.location()))
+	} else if r.End != nil {
+		parts = append(parts, "ending "+englishDate(*r.End, r.location()))
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// RRule describes an RFC 5545 recurrence rule.
+func (English) RRule(r RRule) string {
+	loc := r.location()
+	start := r.Start.In(loc)
+	phrase := []string{englishEvery(rruleInterval(r))}
+
+	if len(r.ByMonth) > 0 {
+		phrase = append(phrase, "in "+englishRanges(r.ByMonth, englishMonth))
+	}
+
+	if len(r.ByMonthDay) > 0 {
+		phrase = append(phrase, "on the "+englishList(mapInts(r.ByMonthDay, englishOrdinal))+" day of the month")
+	}
+
+	if len(r.ByDay) > 0 {
+		phrase = append(phrase, "on "+englishByDay(r))
+	}
+
+	hours, minutes := r.ByHour, r.ByMinute
+
+	if len(hours) == 0 && r.Freq <= Daily {
+		hours = []int{start.Hour()}
+	}
+
+	if len(minutes) == 0 && r.Freq <= Hourly {
+		minutes = []int{start.Minute()}
+	}
+
+	phrase = append(phrase, englishClock(nil, minutes, hours)...)
+
+	if len(r.BySetPos) > 0 {
+		phrase = append(phrase, "keeping the "+englishList(mapInts(r.BySetPos, englishOrdinal))+" of each "+rruleUnits[r.Freq])
+	}
+
+	parts := []string{strings.Join(phrase, " ")}
+
+	if r.Count > 0 {
+		parts = append(parts, englishTimes(r.Count))
+	}
+
+	if r.Until != nil {
+		parts = append(parts, "until "+englishDate(*r.Until, loc))
+	}
+
+	if r.hasDefaultStart() {
+		parts = append(parts, loc.String()+" time")
+	} else {
+		parts = append(parts, "starting "+englishDate(start, loc))
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// Cron describes a cron expression.
+func (English) Cron(c CronSchedule) string {
+	if c.Every > 0 {
+		return englishEvery(RecurrenceInterval{Nanoseconds: int(c.Every)})
+	}
+
+	phrase := englishClock(
+		cronValues(c.Second, secondBounds),
+		cronValues(c.Minute, minuteBounds),
+		cronValues(c.Hour, hourBounds),
+	)
+
+	var days []string
+
+	if !c.domStar {
+		days = append(days, englishDaysOfMonth(c))
+	}
+
+	if !c.dowStar {
+		days = append(days, englishDaysOfWeek(c))
+	}
+
+	if len(days) > 0 {
+		phrase = append(phrase, "on "+strings.Join(days, " or "))
+	}
+
+	if months := cronValues(c.Month, monthBounds); len(months) < 12 {
+		phrase = append(phrase, "in "+englishRanges(months, englishMonth))
+	}
+
+	return strings.Join(phrase, " ") + ", " + c.location().String() + " time"
+}
+
+// Offset describes an offset schedule, e.g. "2 business days before each
+// occurrence of: ...".
+func (English) Offset(o OffsetSchedule, base string) string {
+	d, direction := o.Offset, "after"
+
+	if d.negative() {
+		d, direction = d.Negate(), "before"
+	}
+
+	day := "day"
+
+	if o.BusinessDays {
+		day = "business day"
+	}
+
+	return englishAmount(d, day) + " " + direction + " each occurrence of: " + base
+}
+
+// Roll describes a rolled schedule, e.g. "..., moved to the following working
+// day on target holidays".
+func (English) Roll(s RolledSchedule, base string) string {
+	switch s.Roll {
+	case RollSkip:
+		return base + ", skipped on " + s.Calendar.Name + " holidays"
+	case RollPreceding:
+		return base + ", moved to the preceding working day on " + s.Calendar.Name + " holidays"
+	case RollModifiedFollowing:
+		return base + ", moved to the following working day within the month on " + s.Calendar.Name + " holidays"
+	}
+
+	return base + ", moved to the following working day on " + s.Calendar.Name + " holidays"
+}
+
+// Composite describes a combination of schedules, e.g. "every 15 minutes,
+// UTC time, except at 12:00, UTC time".
+func (English) Composite(c CompositeSchedule, left, right string) string {
+	switch c.Operator {
+	case Intersection:
+		return left + ", whenever also " + right
+	case Exclusion:
+		return left + ", except " + right
+	}
+
+	return left + ", as well as " + right
+}
+
+// Jitter describes a delayed schedule, e.g. "every hour, delayed by 2 minutes
+// and 13 seconds".
+func (English) Jitter(s JitteredSchedule, base string) string {
+	if s.Offset == 0 {
+		return base
+	}
+
+	return base + ", delayed by " + englishAmount(s.JitterInterval(), "day")
+}
+
+// Set describes a schedule with excluded and extra dates, e.g. "every day,
+// except on 1 date, plus 2 extra dates".
+func (English) Set(s RecurrenceSet, base string) string {
+	if len(s.ExDates) > 0 {
+		base += ", except on " + englishCount(len(s.ExDates), "date")
+	}
+
+	if len(s.RDates) > 0 {
+		base += ", plus " + englishCount(len(s.RDates), "extra date")
+	}
+
+	return base
+}
+
+// rruleInterval returns the step of the rule as an interval.
+func rruleInterval(r RRule) RecurrenceInterval {
+	switch r.Freq {
+	case Yearly:
+		return RecurrenceInterval{Years: r.Interval}
+	case Monthly:
+		return RecurrenceInterval{Months: r.Interval}
+	case Weekly:
+		return RecurrenceInterval{Weeks: r.Interval}
+	case Daily:
+		return RecurrenceInterval{Days: r.Interval}
+	case Hourly:
+		return RecurrenceInterval{Hours: r.Interval}
+	}
+
+	return RecurrenceInterval{Minutes: r.Interval}
+}
+
+// englishEvery writes an interval as "every week" or "every 1 hour and 30
+// minutes".
+func englishEvery(d RecurrenceInterval) string {
+	amount := englishAmount(d, "day")
+
+	if strings.HasPrefix(amount, "1 ") && !strings.Contains(amount, " and ") {
+		return "every " + amount[2:]
+	}
+
+	return "every " + amount
+}
+
+// englishAmount writes an interval as "1 hour and 30 minutes", naming days
+// with the given unit.
+func englishAmount(d RecurrenceInterval, day string) string {
+	d = d.Normalize()
+
+	if day != "day" && d.Weeks != 0 {
+		d.Days, d.Weeks = d.Weeks*7, 0
+	}
+
+	var parts []string
+
+	for _, part := range []struct {
+		value int
+		unit  string
+	}{{d.Years, "year"}, {d.Months, "month"}, {d.Weeks, "week"}, {d.Days, day}, {d.Hours, "hour"}, {d.Minutes, "minute"}} {
+		if part.value != 0 {
+			parts = append(parts, englishCount(part.value, part.unit))
+		}
+	}
+
+	if d.Seconds != 0 || d.Nanoseconds != 0 || len(parts) == 0 {
+		seconds := strconv.Itoa(d.Seconds)
+
+		if d.Nanoseconds != 0 {
+			seconds += "." + strings.TrimRight(strconv.Itoa(int(time.Second) + d.Nanoseconds)[1:], "0")
+		}
+
+		if seconds == "1" {
+			parts = append(parts, "1 second")
+		} else {
+			parts = append(parts, seconds+" seconds")
+		}
+	}
+
+	return englishList(parts)
+}
+
+// englishClock writes the seconds, minutes and hours a schedule fires at,
+// as clock times when there are only a few of them. Nil seconds stand for
+// zero seconds, nil minutes and hours are left out.
+func englishClock(seconds, minutes, hours []int) []string {
+	if len(seconds) == 0 {
+		seconds = []int{0}
+	}
+
+	if len(hours) > 0 && len(hours) < 24 && len(minutes) > 0 && len(minutes) < 60 && len(hours)*len(minutes)*len(seconds) <= 6 {
+		var times []string
+
+		for _, hour := range hours {
+			for _, minute := range minutes {
+				for _, second := range seconds {
+					times = append(times, englishTime(hour, minute, second))
+				}
+			}
+		}
+
+		return []string{"at " + englishList(times)}
+	}
+
+	var phrase []string
+
+	switch {
+	case len(seconds) == 60:
+		phrase = append(phrase, "every second")
+	case len(seconds) > 1 || seconds[0] != 0:
+		phrase = append(phrase, englishUnitValues(seconds, "second", 60))
+	}
+
+	switch {
+	case len(minutes) == 60:
+		if len(phrase) == 0 {
+			phrase = append(phrase, "every minute")
+		}
+	case len(minutes) > 0:
+		phrase = append(phrase, englishUnitValues(minutes, "minute", 60))
+	}
+
+	if len(hours) > 0 && len(hours) < 24 {
+		if step := englishStep(hours, 24); step > 0 {
+			phrase = append(phrase, "every "+strconv.Itoa(step)+" hours")
+		} else {
+			phrase = append(phrase, "during "+englishPlural(len(hours), "hour")+" "+englishRanges(hours, strconv.Itoa))
+		}
+	}
+
+	return phrase
+}
+
+// englishUnitValues writes "every 15 minutes" for regular steps and "at
+// minutes 0 and 45" otherwise.
+func englishUnitValues(values []int, unit string, count int) string {
+	if step := englishStep(values, count); step > 0 {
+		return "every " + strconv.Itoa(step) + " " + unit + "s"
+	}
+
+	return "at " + englishPlural(len(values), unit) + " " + englishRanges(values, strconv.Itoa)
+}
+
+// englishStep returns the step of values evenly dividing a cycle of count
+// units from zero, or 0 when they are not such a step.
+func englishStep(values []int, count int) int {
+	if len(values) < 2 || values[0] != 0 {
+		return 0
+	}
+
+	step := values[1]
+
+	if count%step != 0 || len(values) != count/step {
+		return 0
+	}
+
+	for i, value := range values {
+		if value != i*step {
+			return 0
+		}
+	}
+
+	return step
+}
+
+func englishDaysOfMonth(c CronSchedule) string {
+	var days []string
+
+	if values := cronValues(c.DayOfMonth, dayOfMonthBounds); len(values) > 0 {
+		days = append(days, englishRangeItems(values, func(day int) string {
+			return "the " + englishOrdinal(day)
+		})...)
+	}
+
+	for _, day := range c.nearestWeekday {
+		days = append(days, "the weekday nearest the "+englishOrdinal(day))
+	}
+
+	if c.lastDay {
+		if c.lastDayOffset > 0 {
+			days = append(days, englishCount(c.lastDayOffset, "day")+" before the last day")
+		} else {
+			days = append(days, "the last day")
+		}
+	}
+
+	if c.lastWeekday {
+		days = append(days, "the last weekday")
+	}
+
+	return englishList(days) + " of the month"
+}
+
+func englishDaysOfWeek(c CronSchedule) string {
+	var days []string
+
+	if values := cronValues(c.DayOfWeek, cronBounds{0, 6, nil}); len(values) > 0 {
+		days = append(days, englishRangeItems(values, englishWeekday)...)
+	}
+
+	nth := append([]nthWeekday(nil), c.nthOfWeek...)
+
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		if c.lastOfWeek&(1<<uint(weekday)) != 0 {
+			nth = append(nth, nthWeekday{weekday, -1})
+		}
+	}
+
+	for _, day := range nth {
+		days = append(days, "the "+englishOrdinal(day.n)+" "+day.weekday.String())
+	}
+
+	if len(nth) > 0 {
+		return englishList(days) + " of the month"
+	}
+
+	return englishList(days)
+}
+
+func englishByDay(r RRule) string {
+	var (
+		days    []string
+		ordinal bool
+	)
+
+	for _, day := range r.ByDay {
+		if day.N == 0 {
+			days = append(days, day.Weekday.String())
+		} else {
+			days = append(days, "the "+englishOrdinal(day.N)+" "+day.Weekday.String())
+			ordinal = true
+		}
+	}
+
+	switch {
+	case !ordinal:
+		return englishList(days)
+	case r.Freq == Yearly && len(r.ByMonth) == 0:
+		return englishList(days) + " of the year"
+	default:
+		return englishList(days) + " of the month"
+	}
+}
+
+// cronValues lists the values set in a cron bit set.
+func cronValues(bits uint64, bounds cronBounds) []int {
+	var values []int
+
+	for v := bounds.min; v <= bounds.max; v++ {
+		if bits&(1<<uint(v)) != 0 {
+			values = append(values, v)
+		}
+	}
+
+	return values
+}
+
+// englishRanges lists sorted values, writing runs of three or more as
+// "Monday through Friday".
+func englishRanges(values []int, name func(int) string) string {
+	return englishList(englishRangeItems(values, name))
+}
+
+func englishRangeItems(values []int, name func(int) string) []string {
+	values = append([]int(nil), values...)
+	sort.Ints(values)
+
+	var items []string
+
+	for i := 0; i < len(values); {
+		j := i
+
+		for j+1 < len(values) && values[j+1] == values[j]+1 {
+			j++
+		}
+
+		if j-i >= 2 {
+			items = append(items, name(values[i])+" through "+name(values[j]))
+		} else {
+			for k := i; k <= j; k++ {
+				items = append(items, name(values[k]))
+			}
+		}
+
+		i = j + 1
+	}
+
+	return items
+}
+
+// englishList joins items as "a, b and c".
+func englishList(items []string) string {
+	switch len(items) {
+	case 0:
+		return ""
+	case 1:
+		return items[0]
+	}
+
+	return strings.Join(items[:len(items)-1], ", ") + " and " + items[len(items)-1]
+}
+
+func mapInts(values []int, name func(int) string) []string {
+	items := make([]string, len(values))
+
+	for i, value := range values {
+		items[i] = name(value)
+	}
+
+	return items
+}
+
+func englishPlural(n int, unit string) string {
+	if n == 1 {
+		return unit
+	}
+
+	return unit + "s"
+}
+
+func englishCount(n int, unit string) string {
+	return strconv.Itoa(n) + " " + englishPlural(n, unit)
+}
+
+func englishTimes(n int) string {
+	switch n {
+	case 1:
+		return "once"
+	case 2:
+		return "twice"
+	}
+
+	return strconv.Itoa(n) + " times"
+}
+
+// englishOrdinal writes 1 as 1st, -1 as last and -2 as 2nd to last.
+func englishOrdinal(n int) string {
+	switch {
+	case n == -1:
+		return "last"
+	case n < 0:
+		return englishOrdinal(-n) + " to last"
+	case n%100 >= 11 && n%100 <= 13:
+		return strconv.Itoa(n) + "th"
+	case n%10 == 1:
+		return strconv.Itoa(n) + "st"
+	case n%10 == 2:
+		return strconv.Itoa(n) + "nd"
+	case n%10 == 3:
+		return strconv.Itoa(n) + "rd"
+	}
+
+	return strconv.Itoa(n) + "th"
+}
+
+func englishMonth(month int) string {
+	return time.Month(month).String()
+}
+
+func englishWeekday(weekday int) string {
+	return time.Weekday(weekday).String()
+}
+
+func englishTime(hour, minute, second int) string {
+	t := time.Date(2000, 1, 1, hour, minute, second, 0, time.UTC)
+
+	if second != 0 {
+		return t.Format("15:04:05")
+	}
+
+	return t.Format("15:04")
+}
+
+// englishDate writes a time in the location as "1 Jan 2017 09:00 UTC".
+func englishDate(t time.Time, loc *time.Location) string {
+	t = t.In(loc)
+
+	return t.Format("2 Jan 2006 ") + englishTime(t.Hour(), t.Minute(), t.Second()) + " " + loc.String()
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDescribe(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		Rule     string
+		TimeZone string
+		Expected string
+	}{
+		{"R10/20170101T090000/P2W", "Europe/Berlin", "every 2 weeks, 10 times, starting 1 Jan 2017 09:00 Europe/Berlin"},
+		{"R/PT1H30M", "", "every 1 hour and 30 minutes"},
+		{"R1/2017-01-01T00:00:00Z/P1D", "", "every day, once, starting 1 Jan 2017 00:00 UTC"},
+		{"R2/PT0.5S/2017-01-01T00:00:30Z", "", "every 0.5 seconds, twice, ending 1 Jan 2017 00:00:30 UTC"},
+		{"R/2017-01-01/2017-01-08", "", "every week, starting 1 Jan 2017 00:00 UTC"},
+		{"FREQ=DAILY", "", "every day at 00:00, UTC time"},
+		{"DTSTART;TZID=Europe/Berlin:20170101T090000 RRULE:FREQ=WEEKLY;BYDAY=MO,WE,TH,FR;COUNT=10", "", "every week on Monday, Wednesday, Thursday and Friday at 09:00, 10 times, starting 1 Jan 2017 09:00 Europe/Berlin"},
+		{"FREQ=MONTHLY;INTERVAL=3;BYDAY=-1FR;BYHOUR=9,17;BYMINUTE=30", "", "every 3 months on the last Friday of the month at 09:30 and 17:30, UTC time"},
+		{"FREQ=YEARLY;BYMONTH=1,7;BYMONTHDAY=1,-1", "", "every year in January and July on the 1st and last day of the month at 00:00, UTC time"},
+		{"FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1;UNTIL=20171231T000000Z", "", "every month on Monday, Tuesday, Wednesday, Thursday and Friday at 00:00 keeping the last of each month, until 31 Dec 2017 00:00 UTC, UTC time"},
+		{"FREQ=MINUTELY;INTERVAL=15;BYHOUR=9,10,11,12", "", "every 15 minutes during hours 9 through 12, UTC time"},
+		{"0 9 * * 1-5", "Europe/Berlin", "at 09:00 on Monday through Friday, Europe/Berlin time"},
+		{"*/15 * * * *", "", "every 15 minutes, UTC time"},
+		{"* * * * *", "", "every minute, UTC time"},
+		{"*/10 * * * * *", "", "every 10 seconds, UTC time"},
+		{"0 */2 * JAN-MAR *", "", "at minute 0 every 2 hours in January through March, UTC time"},
+		{"30 8 1,15,L * *", "", "at 08:30 on the 1st, the 15th and the last day of the month, UTC time"},
+		{"0 0 15W,L-3 * 5#3", "", "at 00:00 on the weekday nearest the 15th and 3 days before the last day of the month or the 3rd Friday of the month, UTC time"},
+		{"@every 90m", "", "every 1 hour and 30 minutes"},
+		{"OFFSET:-PT3H R/2017-01-01T09:00:00/P1D", "", "3 hours before each occurrence of: every day, starting 1 Jan 2017 09:00 UTC"},
+		{"OFFSET;DAYS=BUSINESS:-P2D 0 0 L * *", "", "2 business days before each occurrence of: at 00:00 on the last day of the month, UTC time"},
+		{"OFFSET:P1DT1H FREQ=WEEKLY", "", "1 day and 1 hour after each occurrence of: every week at 00:00, UTC time"},
+		{"*/15 * * * * INTERSECT * 9-16 * * MON-FRI", "", "every 15 minutes, UTC time, whenever also every minute during hours 9 through 16 on Monday through Friday, UTC time"},
+		{"0 12 * * * EXCEPT 0 12 * * SAT,SUN", "", "at 12:00, UTC time, except at 12:00 on Sunday and Saturday, UTC time"},
+		{"0 9 * * MON UNION 0 17 * * FRI", "", "at 09:00 on Monday, UTC time, as well as at 17:00 on Friday, UTC time"},
+	}
+
+	for index, test := range tests {
+		schedule, err := Task{Rule: test.Rule, TimeZone: test.TimeZone}.Schedule()
+
+		if err != nil {
+			t.Fatalf("Test %d expected correct rule but got error %s", index+1, err)
+		}
+
+		if result := Describe(schedule, "en"); result != test.Expected {
+			t.Errorf("Test %d expected %q but got %q", index+1, test.Expected, result)
+		}
+	}
+
+	schedule, err := Task{Rule: "R/2017-01-01T09:00:00/P1D", ExDates: []time.Time{now}, RDates: []time.Time{now, now}}.Schedule()
+
+	if err != nil {
+		t.Fatalf("Expected correct rule but got error %s", err)
+	}
+
+	expected := "every day, starting 1 Jan 2017 09:00 UTC, except on 1 date, plus 2 extra dates"
+
+	if result := Describe(schedule, "en"); result != expected {
+		t.Errorf("Expected %q but got %q", expected, result)
+	}
+
+	calendar, _ := NewCalendar("test-describe", nil, nil)
+	RegisterCalendar(calendar)
+
+	if schedule, err = (Task{Rule: "0 9 L * *", Calendar: "test-describe", Roll: "modified-following"}).Schedule(); err != nil {
+		t.Fatalf("Expected correct rule but got error %s", err)
+	}
+
+	expected = "at 09:00 on the last day of the month, UTC time, moved to the following working day within the month on test-describe holidays"
+
+	if result := Describe(schedule, "en"); result != expected {
+		t.Errorf("Expected %q but got %q", expected, result)
+	}
+}
+
+type shoutingLocale struct {
+	English
+}
+
+func (l shoutingLocale) Recurrence(r Recurrence) string {
+	return strings.ToUpper(l.English.Recurrence(r))
+}
+
+func TestRegisterLocale(t *testing.T) {
+	RegisterLocale("x-Shout", shoutingLocale{})
+
+	start := time.Date(2017, 1, 1, 9, 0, 0, 0, time.UTC)
+	recurrence := Recurrence{Repetitions: -1, Start: &start, Duration: &RecurrenceInterval{Days: 1}}
+
+	var tests = []struct {
+		Tag      string
+		Expected string
+	}{
+		{"x-shout", "EVERY DAY, STARTING 1 JAN 2017 09:00 UTC"},
+		{"X-SHOUT", "EVERY DAY, STARTING 1 JAN 2017 09:00 UTC"},
+		{"en-GB", "every day, starting 1 Jan 2017 09:00 UTC"},
+		{"unknown", "every day, starting 1 Jan 2017 09:00 UTC"},
+		{"", "every day, starting 1 Jan 2017 09:00 UTC"},
+	}
+
+	for index, test := range tests {
+		if result := Describe(recurrence, test.Tag); result != test.Expected {
+			t.Errorf("Test %d expected %q but got %q", index+1, test.Expected, result)
+		}
+	}
+}
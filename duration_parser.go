@@ -3,7 +3,7 @@ package main
 import (
 	"errors"
 	"math"
-	"regexp"
+	"math/bits"
 	"strconv"
 	"strings"
 	"time"
@@ -12,129 +12,535 @@ import (
 var (
 	//ErrBadFormat bad duration or recurrence format
 	ErrBadFormat = errors.New("bad duration or recurrence format")
+)
 
-	basicDateFormat    = "20060102T150405"
-	extendedDateFormat = "2006-01-02T15:04:05"
-
-	repeatRegexp = regexp.MustCompile(`^(R|R(\d+))$`)
+// RecurrenceParser parses ISO 8601 repeating intervals with dates and
+// calendar arithmetic in Location.
+type RecurrenceParser struct {
+	Location *time.Location
+	// Lenient accepts weeks combined with other components, e.g. P1W2D.
+	Lenient bool
+}
 
-	fullDurationRegexp = regexp.MustCompile(`P((?P<year>\d+)Y)?((?P<month>\d+)M)?((?P<day>\d+)D)?(T((?P<hour>\d+)H)?((?P<minute>\d+)M)?((?P<second>\d+)S)?)?`)
-	weekDurationRegexp = regexp.MustCompile(`P(\d+)W`)
+// designator ranks, a duration must list them in this order
+const (
+	rankYears = iota + 1
+	rankMonths
+	rankWeeks
+	rankDays
+	rankHours
+	rankMinutes
+	rankSeconds
 )
 
 //DurationFromString parses duration from string
 func DurationFromString(dur string) (RecurrenceInterval, error) {
+	return RecurrenceParser{}.Duration(dur)
+}
+
+// Duration parses PnYnMnDTnHnMnS or PnW. The whole string must match, time
+// components need the T designator and T must be followed by at least one of
+// them. Components may be zero as long as the duration is not. The last
+// component may have a decimal fraction, written with a dot or a comma, which
+// is carried over into the smaller components. A leading minus sign negates
+// every component, e.g. -P2D.
+func (p RecurrenceParser) Duration(dur string) (RecurrenceInterval, error) {
 	d := RecurrenceInterval{}
 
-	if weekDurationRegexp.MatchString(dur) {
-		match := weekDurationRegexp.FindStringSubmatch(dur)
-		val, err := strconv.Atoi(match[1])
+	if strings.HasPrefix(dur, "-P") {
+		positive, err := p.Duration(dur[1:])
 
-		if err != nil || val <= 0 {
-			return RecurrenceInterval{}, ErrBadFormat
+		if err != nil {
+			return RecurrenceInterval{}, err.(*ParseError).shifted(1)
 		}
 
-		d.Weeks = val
+		return positive.Negate(), nil
+	}
+
+	if !strings.HasPrefix(dur, "P") {
+		return RecurrenceInterval{}, newParseError(0, dur, ReasonMalformedDuration)
+	}
 
-		return d, nil
+	if isAlternativeDuration(dur) {
+		return alternativeDurationFromString(dur)
 	}
 
 	var (
-		match []string
-		re    *regexp.Regexp
+		rank       = 0
+		timeAt     = -1
+		weeksAt    = -1
+		otherAt    = -1
+		fractionAt = -1
+		lastValue  = 0
 	)
 
-	if fullDurationRegexp.MatchString(dur) {
-		match = fullDurationRegexp.FindStringSubmatch(dur)
-		re = fullDurationRegexp
-	} else {
-		return RecurrenceInterval{}, ErrBadFormat
-	}
+	for i := 1; i < len(dur); {
+		if dur[i] == 'T' {
+			if fractionAt >= 0 {
+				return RecurrenceInterval{}, newParseError(fractionAt, dur[fractionAt:], ReasonFractionNotLast)
+			}
 
-	for i, name := range re.SubexpNames() {
-		part := match[i]
+			if timeAt >= 0 {
+				return RecurrenceInterval{}, newParseError(i, "T", ReasonOutOfOrder)
+			}
+
+			timeAt = i
+			i++
 
-		if i == 0 || name == "" || part == "" {
 			continue
 		}
 
-		val, err := strconv.Atoi(part)
+		start := i
+
+		for i < len(dur) && dur[i] >= '0' && dur[i] <= '9' {
+			i++
+		}
+
+		intEnd := i
+
+		if i < len(dur) && i > start && (dur[i] == '.' || dur[i] == ',') {
+			i++
+
+			for i < len(dur) && dur[i] >= '0' && dur[i] <= '9' {
+				i++
+			}
+
+			if i == intEnd+1 {
+				return RecurrenceInterval{}, newParseError(intEnd, dur[intEnd:], ReasonMissingValue)
+			}
+		}
+
+		if i == len(dur) {
+			return RecurrenceInterval{}, newParseError(start, dur[start:], ReasonMissingDesignator)
+		}
+
+		if fractionAt >= 0 {
+			return RecurrenceInterval{}, newParseError(fractionAt, dur[fractionAt:], ReasonFractionNotLast)
+		}
+
+		if i == start {
+			if durationRank(dur[i], timeAt >= 0) == 0 {
+				return RecurrenceInterval{}, newParseError(i, dur[i:], ReasonUnknownDesignator)
+			}
 
-		if err != nil || val <= 0 {
-			return RecurrenceInterval{}, ErrBadFormat
+			return RecurrenceInterval{}, newParseError(i, dur[i:i+1], ReasonMissingValue)
 		}
 
-		switch name {
-		case "year":
+		val, err := strconv.Atoi(dur[start:intEnd])
+
+		if err != nil {
+			return RecurrenceInterval{}, newParseError(start, dur[start:i], ReasonOutOfRange)
+		}
+
+		next := durationRank(dur[i], timeAt >= 0)
+
+		switch {
+		case next == 0 && timeAt < 0 && (dur[i] == 'H' || dur[i] == 'S'):
+			return RecurrenceInterval{}, newParseError(i, dur[i:i+1], ReasonMissingTimeDesignator)
+		case next == 0:
+			return RecurrenceInterval{}, newParseError(i, dur[i:], ReasonUnknownDesignator)
+		case next <= rank:
+			return RecurrenceInterval{}, newParseError(i, dur[i:i+1], ReasonOutOfOrder)
+		}
+
+		switch next {
+		case rankYears:
 			d.Years = val
-		case "month":
+		case rankMonths:
 			d.Months = val
-		case "week":
+		case rankWeeks:
 			d.Weeks = val
-		case "day":
+		case rankDays:
 			d.Days = val
-		case "hour":
+		case rankHours:
 			d.Hours = val
-		case "minute":
+		case rankMinutes:
 			d.Minutes = val
-		case "second":
+		case rankSeconds:
 			d.Seconds = val
-		default:
-			panic("unknown field " + name)
 		}
+
+		if intEnd < i {
+			if !d.addFraction(next, dur[intEnd+1:i]) {
+				return RecurrenceInterval{}, newParseError(start, dur[start:i+1], ReasonInexactFraction)
+			}
+
+			fractionAt = start
+		}
+
+		if next == rankWeeks {
+			weeksAt = start
+		} else if otherAt < 0 {
+			otherAt = start
+		}
+
+		rank, lastValue = next, i
+		i++
+	}
+
+	if timeAt >= 0 && rank < rankHours {
+		return RecurrenceInterval{}, newParseError(timeAt, dur[timeAt:], ReasonEmptyTime)
+	}
+
+	if rank == 0 {
+		return RecurrenceInterval{}, newParseError(len(dur), "", ReasonMissingValue)
 	}
 
-	if d.Years == 0 && d.Months == 0 && d.Weeks == 0 && d.Hours == 0 && d.Minutes == 0 && d.Seconds == 0 {
-		return RecurrenceInterval{}, ErrBadFormat
+	if weeksAt >= 0 && otherAt >= 0 && !p.Lenient {
+		return RecurrenceInterval{}, newParseError(weeksAt, dur[weeksAt:lastValue+1], ReasonCombinedWeeks)
+	}
+
+	if d.Years == 0 && d.Months == 0 && d.Weeks == 0 && d.Days == 0 && d.Hours == 0 && d.Minutes == 0 && d.Seconds == 0 && d.Nanoseconds == 0 {
+		return RecurrenceInterval{}, newParseError(0, dur, ReasonZeroDuration)
 	}
 
 	return d, nil
 }
 
-func RepeatFromString(repeatString string) (int, error) {
-	if repeatRegexp.MatchString(repeatString) {
-		match := repeatRegexp.FindStringSubmatch(repeatString)
+// fractionFactors tell how many units of the next smaller component make one
+// unit of the component with the given rank. Months have no fixed length in
+// days, so only fractions that are whole months carry over from years.
+var fractionFactors = [...]struct {
+	next   int
+	factor uint64
+}{
+	rankYears:   {rankMonths, 12},
+	rankMonths:  {0, 0},
+	rankWeeks:   {rankDays, 7},
+	rankDays:    {rankHours, 24},
+	rankHours:   {rankMinutes, 60},
+	rankMinutes: {rankSeconds, 60},
+	rankSeconds: {rankSeconds + 1, 1000000000},
+}
+
+// maxFractionDigits bounds the significant fraction digits, kept as a
+// numerator over a power of ten. Longer fractions never come out in whole
+// months or nanoseconds, the factors down to nanoseconds have at most 2^16
+// and 5^11 in them.
+const maxFractionDigits = 18
+
+// addFraction spreads the decimal fraction digits of the component with the
+// given rank over the smaller components, down to nanoseconds. It fails when
+// the fraction does not come out in whole months or nanoseconds.
+func (d *RecurrenceInterval) addFraction(rank int, digits string) bool {
+	digits = strings.TrimRight(digits, "0")
 
-		if match[1] == "R" { //unbounded repeats
-			return -1, nil
+	if len(digits) > maxFractionDigits {
+		return false
+	}
+
+	numerator, denominator := uint64(0), uint64(1)
+
+	for _, c := range []byte(digits) {
+		if c < '0' || c > '9' {
+			return false
 		}
 
-		val, err := strconv.Atoi(match[2])
+		numerator, denominator = numerator*10+uint64(c-'0'), denominator*10
+	}
+
+	for numerator != 0 {
+		step := fractionFactors[rank]
 
-		if err != nil || val < 0 {
-			return math.MinInt32, ErrBadFormat
+		if step.next == 0 {
+			return false
 		}
 
-		return val, nil
-	} else {
-		return math.MinInt32, ErrBadFormat
+		// the product fits 128 bits and its high half stays below the
+		// denominator, so Div64 does not overflow
+		hi, lo := bits.Mul64(numerator, step.factor)
+		whole, rest := bits.Div64(hi, lo, denominator)
+		numerator = rest
+
+		rank = step.next
+
+		switch rank {
+		case rankMonths:
+			d.Months += int(whole)
+		case rankDays:
+			d.Days += int(whole)
+		case rankHours:
+			d.Hours += int(whole)
+		case rankMinutes:
+			d.Minutes += int(whole)
+		case rankSeconds:
+			d.Seconds += int(whole)
+		default:
+			d.Nanoseconds += int(whole)
+
+			return numerator == 0
+		}
 	}
+
+	return true
 }
 
-func DateFromString(dateString string) (time.Time, error) {
-	date, err := time.Parse(basicDateFormat, dateString)
+// isAlternativeDuration tells whether the duration is written in the
+// alternative format, i.e. its leading digits are followed by a dash or are
+// a whole basic date rather than a number with a designator.
+func isAlternativeDuration(dur string) bool {
+	digits := 1
 
-	if err == nil {
-		return date, nil
+	for digits < len(dur) && dur[digits] >= '0' && dur[digits] <= '9' {
+		digits++
 	}
 
-	date, err = time.Parse(extendedDateFormat, dateString)
+	if digits == len(dur) || dur[digits] == 'T' {
+		return digits-1 == 7 || digits-1 == 8
+	}
+
+	return dur[digits] == '-' && digits-1 == 4
+}
+
+// alternativeDurationFromString parses PYYYY-MM-DDThh:mm:ss, PYYYY-DDDThh:mm:ss
+// and their basic forms PYYYYMMDDThhmmss and PYYYYDDDThhmmss, the time being
+// optional. Values may not exceed the carry-over points, 12 months, 30 days
+// (365 for ordinal days), 24 hours, 59 minutes and 59 seconds, and seconds may
+// have a decimal fraction.
+func alternativeDurationFromString(dur string) (RecurrenceInterval, error) {
+	d := RecurrenceInterval{}
+	datePart, clock := dur[1:], ""
 
-	if err == nil {
-		return date, nil
+	if t := strings.IndexByte(dur, 'T'); t >= 0 {
+		datePart, clock = dur[1:t], dur[t+1:]
+	}
+
+	// fixed size arrays stay on the stack whatever escape analysis decides
+	var values, limits, offsets [3]int
+
+	fields := 3
+
+	switch {
+	case len(datePart) == 10 && datePart[4] == '-' && datePart[7] == '-':
+		offsets, limits = [3]int{0, 5, 8}, [3]int{9999, 12, 30}
+	case len(datePart) == 8 && datePart[4] == '-':
+		offsets, limits, fields = [3]int{0, 5}, [3]int{9999, 365}, 2
+	case len(datePart) == 8:
+		offsets, limits = [3]int{0, 4, 6}, [3]int{9999, 12, 30}
+	case len(datePart) == 7:
+		offsets, limits, fields = [3]int{0, 4}, [3]int{9999, 365}, 2
+	default:
+		return RecurrenceInterval{}, newParseError(1, datePart, ReasonMalformedDuration)
+	}
+
+	for i, offset := range offsets[:fields] {
+		width := 2
+
+		if i == 0 {
+			width = 4
+		} else if limits[i] == 365 {
+			width = 3
+		}
+
+		val, ok := fixedDigits(datePart, offset, width)
+
+		if !ok {
+			return RecurrenceInterval{}, newParseError(1+offset, datePart[offset:], ReasonMalformedDuration)
+		} else if val > limits[i] {
+			return RecurrenceInterval{}, newParseError(1+offset, datePart[offset:offset+width], ReasonOutOfRange)
+		}
+
+		values[i] = val
+	}
+
+	d.Years = values[0]
+
+	if fields == 3 {
+		d.Months, d.Days = values[1], values[2]
 	} else {
-		return time.Time{}, ErrBadFormat
+		d.Days = values[1]
+	}
+
+	if clock != "" || len(datePart)+1 < len(dur) {
+		clockAt := len(datePart) + 2
+
+		if err := d.addAlternativeClock(clock); err != nil {
+			return RecurrenceInterval{}, err.inComponent(0, clockAt)
+		}
 	}
+
+	if d.Years == 0 && d.Months == 0 && d.Days == 0 && d.Hours == 0 && d.Minutes == 0 && d.Seconds == 0 && d.Nanoseconds == 0 {
+		return RecurrenceInterval{}, newParseError(0, dur, ReasonZeroDuration)
+	}
+
+	return d, nil
+}
+
+// addAlternativeClock parses hh:mm:ss or hhmmss with an optional fraction of
+// a second.
+func (d *RecurrenceInterval) addAlternativeClock(clock string) *ParseError {
+	offsets := [3]int{0, 2, 4}
+
+	if len(clock) > 2 && clock[2] == ':' {
+		offsets = [3]int{0, 3, 6}
+
+		if len(clock) < 6 || clock[5] != ':' {
+			return newParseError(0, clock, ReasonMalformedDuration)
+		}
+	}
+
+	limits := [3]int{24, 59, 59}
+
+	var values [3]int
+
+	for i, offset := range offsets {
+		val, ok := fixedDigits(clock, offset, 2)
+
+		if !ok && offset >= len(clock) {
+			return newParseError(len(clock), "", ReasonMalformedDuration)
+		} else if !ok {
+			return newParseError(offset, clock[offset:], ReasonMalformedDuration)
+		} else if val > limits[i] {
+			return newParseError(offset, clock[offset:offset+2], ReasonOutOfRange)
+		}
+
+		values[i] = val
+	}
+
+	d.Hours, d.Minutes, d.Seconds = values[0], values[1], values[2]
+
+	if end := offsets[2] + 2; end < len(clock) {
+		fraction, ok := fractionOf(clock[end+1:], time.Second)
+
+		if !ok || (clock[end] != '.' && clock[end] != ',') {
+			return newParseError(end, clock[end:], ReasonMalformedDuration)
+		}
+
+		d.Nanoseconds = int(fraction)
+	}
+
+	return nil
+}
+
+// durationRank returns the rank of a date or time designator, 0 when the
+// character is not one.
+func durationRank(designator byte, inTime bool) int {
+	if inTime {
+		switch designator {
+		case 'H':
+			return rankHours
+		case 'M':
+			return rankMinutes
+		case 'S':
+			return rankSeconds
+		}
+
+		return 0
+	}
+
+	switch designator {
+	case 'Y':
+		return rankYears
+	case 'M':
+		return rankMonths
+	case 'W':
+		return rankWeeks
+	case 'D':
+		return rankDays
+	}
+
+	return 0
+}
+
+// RepeatFromString parses R, unbounded and returned as -1, or Rn.
+func RepeatFromString(repeatString string) (int, error) {
+	if !strings.HasPrefix(repeatString, "R") {
+		return math.MinInt32, newParseError(0, repeatString, ReasonBadRepeat)
+	}
+
+	digits := repeatString[1:]
+
+	if digits == "" { //unbounded repeats
+		return -1, nil
+	}
+
+	for _, c := range []byte(digits) {
+		if c < '0' || c > '9' {
+			return math.MinInt32, newParseError(1, digits, ReasonBadRepeat)
+		}
+	}
+
+	val, err := strconv.Atoi(digits)
+
+	if err != nil || val < 0 {
+		return math.MinInt32, newParseError(1, digits, ReasonOutOfRange)
+	}
+
+	return val, nil
 }
 
 //RecurrenceFromString parsing ISO8601 recurrent intervals string
 func RecurrenceFromString(recurrenceString string) (Recurrence, error) {
-	components := strings.Split(recurrenceString, "/")
+	var values recurrenceValues
+
+	return RecurrenceParser{}.recurrence(recurrenceString, &values)
+}
+
+// RecurrenceFromStringIn parsing ISO8601 recurrent intervals string with dates
+// and calendar arithmetic in the given location
+func RecurrenceFromStringIn(recurrenceString string, loc *time.Location) (Recurrence, error) {
+	var values recurrenceValues
+
+	return RecurrenceParser{Location: loc}.recurrence(recurrenceString, &values)
+}
+
+// recurrenceValues backs the start, end and duration pointers of a parsed
+// recurrence.
+type recurrenceValues struct {
+	start, end time.Time
+	duration   RecurrenceInterval
+}
+
+// Recurrence parses R[n]/start/duration, R[n]/start/end, R[n]/duration/end or
+// R[n]/duration. Components starting with P are durations, others dates.
+//
+// The values the pointers of the recurrence point to are declared here, and
+// in RecurrenceFromString and RecurrenceFromStringIn, which are small enough
+// to be inlined. So they live in the frame of the caller and only move to the
+// heap when the recurrence outlives it.
+func (p RecurrenceParser) Recurrence(recurrenceString string) (Recurrence, error) {
+	var values recurrenceValues
+
+	return p.recurrence(recurrenceString, &values)
+}
 
-	componentsCount := len(components)
+// recurrence parses the recurrence with its start, end and duration stored in
+// the given values.
+func (p RecurrenceParser) recurrence(recurrenceString string, values *recurrenceValues) (Recurrence, error) {
+	loc := p.Location
 
-	if componentsCount < 2 || componentsCount > 3 {
-		return Recurrence{}, ErrBadFormat
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	var (
+		components [3]string
+		// byte offset of every component in the whole string
+		offsets         [3]int
+		componentsCount int
+	)
+
+	for at := 0; ; {
+		if componentsCount == len(components) {
+			return Recurrence{}, newParseError(at-1, recurrenceString[at-1:], ReasonComponentCount).inComponent(3, 0)
+		}
+
+		slash := strings.IndexByte(recurrenceString[at:], '/')
+		offsets[componentsCount] = at
+		componentsCount++
+
+		if slash < 0 {
+			components[componentsCount-1] = recurrenceString[at:]
+
+			break
+		}
+
+		components[componentsCount-1] = recurrenceString[at : at+slash]
+		at += slash + 1
+	}
+
+	if componentsCount < 2 {
+		return Recurrence{}, newParseError(len(recurrenceString), "", ReasonComponentCount)
 	}
 
 	repeat, err := RepeatFromString(components[0])
@@ -143,55 +549,63 @@ func RecurrenceFromString(recurrenceString string) (Recurrence, error) {
 		return Recurrence{}, err
 	}
 
-	recurrence := Recurrence{Repetitions: repeat}
+	for i := 1; i < componentsCount; i++ {
+		if strings.HasPrefix(components[i], "-P") {
+			return Recurrence{}, newParseError(0, components[i], ReasonNegativeDuration).inComponent(i, offsets[i])
+		}
+	}
+
+	var hasStart, hasEnd, hasDuration bool
 
-	startDate, err := DateFromString(components[1])
+	if strings.HasPrefix(components[1], "P") {
+		if values.duration, err = p.Duration(components[1]); err != nil {
+			return Recurrence{}, err.(*ParseError).inComponent(1, offsets[1])
+		}
 
-	if err == ErrBadFormat {
-		duration, err := DurationFromString(components[1])
+		hasDuration = true
+	} else if values.start, err = DateFromStringIn(components[1], loc); err != nil {
+		return Recurrence{}, err.(*ParseError).inComponent(1, offsets[1])
+	} else {
+		hasStart = true
+	}
 
-		if err != nil {
-			return Recurrence{}, ErrBadFormat
+	if componentsCount == 3 && strings.HasPrefix(components[2], "P") {
+		if hasDuration {
+			return Recurrence{}, newParseError(0, components[2], ReasonTwoDurations).inComponent(2, offsets[2])
 		}
 
-		recurrence.Duration = &duration
+		if values.duration, err = p.Duration(components[2]); err != nil {
+			return Recurrence{}, err.(*ParseError).inComponent(2, offsets[2])
+		}
 
-		if componentsCount == 2 {
-			return recurrence, nil
+		hasDuration = true
+	} else if componentsCount == 3 {
+		if values.end, err = DateFromStringIn(components[2], loc); err != nil {
+			return Recurrence{}, err.(*ParseError).inComponent(2, offsets[2])
 		}
-	} else if err != nil {
-		return Recurrence{}, ErrBadFormat
-	} else {
-		recurrence.Start = &startDate
-	}
 
-	if componentsCount != 3 {
-		return Recurrence{}, ErrBadFormat
+		hasEnd = true
+	} else if !hasDuration {
+		return Recurrence{}, newParseError(len(recurrenceString), "", ReasonMissingComponent).inComponent(2, 0)
 	}
 
-	endDate, err := DateFromString(components[2])
+	if hasStart && hasEnd && !values.end.After(values.start) {
+		return Recurrence{}, newParseError(0, components[2], ReasonEndBeforeStart).inComponent(2, offsets[2])
+	}
 
-	if err == ErrBadFormat && recurrence.Duration == nil {
-		duration, err := DurationFromString(components[2])
+	recurrence := Recurrence{Repetitions: repeat, Location: loc}
 
-		if err != nil {
-			return Recurrence{}, ErrBadFormat
-		}
+	if hasStart {
+		recurrence.Start = &values.start
+	}
 
-		recurrence.Duration = &duration
-	} else if err != nil {
-		return Recurrence{}, ErrBadFormat
-	} else {
-		recurrence.End = &endDate
+	if hasEnd {
+		recurrence.End = &values.end
 	}
 
-	if recurrence.Start != nil && recurrence.End != nil && recurrence.Duration == nil {
-		return recurrence, nil
-	} else if recurrence.Start != nil && recurrence.End == nil && recurrence.Duration != nil {
-		return recurrence, nil
-	} else if recurrence.Duration != nil && recurrence.End != nil && recurrence.Start == nil {
-		return recurrence, nil
-	} else {
-		return Recurrence{}, ErrBadFormat
+	if hasDuration {
+		recurrence.Duration = &values.duration
 	}
+
+	return recurrence, nil
 }
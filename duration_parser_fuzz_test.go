@@ -0,0 +1,323 @@
+package main
+
+import (
+	"math/big"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// parserSeeds returns the inputs of the duration_parser_test.go tables, each
+// written as a whole recurrence, that seed the fuzz targets and run as a
+// corpus with go test.
+func parserSeeds() []string {
+	var seeds []string
+
+	for _, test := range recurrenceFormatTests {
+		seeds = append(seeds, test.RecurrenceString)
+	}
+
+	for _, test := range recurrenceParseErrorTests {
+		seeds = append(seeds, test.RecurrenceString)
+	}
+
+	for _, duration := range parserDurationSeeds() {
+		seeds = append(seeds, "R/"+duration, "R/1985-04-12/"+duration)
+	}
+
+	for _, test := range dateFormatTests {
+		seeds = append(seeds, "R/"+test.DateString+"/P1D", "R5/PT1H/"+test.DateString)
+	}
+
+	return seeds
+}
+
+// parserDurationSeeds returns the durations of the duration_parser_test.go
+// tables.
+func parserDurationSeeds() []string {
+	var seeds []string
+
+	for _, test := range durationGrammarTests {
+		seeds = append(seeds, test.Duration)
+	}
+
+	for _, test := range negativeDurationTests {
+		seeds = append(seeds, test.Duration)
+	}
+
+	return seeds
+}
+
+func BenchmarkDurationFromString(b *testing.B) {
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := DurationFromString("P1Y2M3DT4H5M6.5S"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDateFromString(b *testing.B) {
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := DateFromString("1985-04-12T23:20:50.5+02:00"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRecurrenceFromString(b *testing.B) {
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := RecurrenceFromString("R10/1985-04-12T23:20:50Z/P1DT0.5H"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestParserAllocations(t *testing.T) {
+	var tests = []struct {
+		Name   string
+		Parse  func()
+		Allocs float64
+	}{
+		{"duration", func() { DurationFromString("P1Y2M3DT4H5M6.5S") }, 0},
+		{"alternative duration", func() { DurationFromString("P0001-02-03T04:05:06,5") }, 0},
+		{"fraction", func() { DurationFromString("P1.5W") }, 0},
+		{"date", func() { DateFromString("1985-04-12T23:20:50.5+02:00") }, 0},
+		{"week date", func() { DateFromString("2015W534T1200Z") }, 0},
+		{"repeat", func() { RepeatFromString("R10") }, 0},
+		{"recurrence", func() { RecurrenceFromString("R10/1985-04-12T23:20:50Z/P1DT0.5H") }, 0},
+		{"start and end", func() { RecurrenceFromString("R/19850412T232050/19860412T232050") }, 0},
+		{"parser", func() { RecurrenceParser{Lenient: true}.Recurrence("R/P1W2D") }, 0},
+	}
+
+	for index, test := range tests {
+		if allocs := testing.AllocsPerRun(100, test.Parse); allocs > test.Allocs {
+			t.Errorf("Test %d expected %s to allocate at most %v times but got %v", index+1, test.Name, test.Allocs, allocs)
+		}
+	}
+}
+
+func FuzzRecurrenceFromString(f *testing.F) {
+	for _, seed := range parserSeeds() {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, rule string) {
+		recurrence, err := RecurrenceFromString(rule)
+		expected, expectedErr := referenceRecurrence(rule)
+
+		if !reflect.DeepEqual(err, expectedErr) {
+			t.Fatalf("Expected %#v but got %#v for %q", expectedErr, err, rule)
+		}
+
+		if err != nil {
+			return
+		}
+
+		if recurrence.Repetitions != expected.Repetitions || !sameTime(recurrence.Start, expected.Start) || !sameTime(recurrence.End, expected.End) {
+			t.Fatalf("Expected %#v but got %#v for %q", expected, recurrence, rule)
+		}
+
+		if (recurrence.Duration == nil) != (expected.Duration == nil) || recurrence.Duration != nil && *recurrence.Duration != *expected.Duration {
+			t.Fatalf("Expected duration %v but got %v for %q", expected.Duration, recurrence.Duration, rule)
+		}
+	})
+}
+
+func FuzzDurationFromString(f *testing.F) {
+	for _, seed := range parserDurationSeeds() {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, dur string) {
+		d, err := RecurrenceParser{Lenient: true}.Duration(dur)
+
+		if err != nil {
+			return
+		}
+
+		again, err := RecurrenceParser{Lenient: true}.Duration(d.String())
+
+		if err != nil {
+			t.Fatalf("Expected %s parsed from %q to parse back but got %s", d, dur, err)
+		}
+
+		if again.Normalize() != d.Normalize() {
+			t.Fatalf("Expected %#v but got %#v for %q", d, again, dur)
+		}
+	})
+}
+
+func FuzzAddFraction(f *testing.F) {
+	for rank := rankYears; rank <= rankSeconds; rank++ {
+		for _, digits := range []string{"5", "25", "001", "0000000001", "333", "142857", "1234567890123456789"} {
+			f.Add(rank, digits)
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, rank int, digits string) {
+		if rank < rankYears || rank > rankSeconds || digits == "" || strings.Trim(digits, "0123456789") != "" {
+			return
+		}
+
+		var d, expected RecurrenceInterval
+
+		ok := d.addFraction(rank, digits)
+		expectedOk := expected.referenceAddFraction(rank, digits)
+
+		if ok != expectedOk || ok && d != expected {
+			t.Fatalf("Expected %v %#v but got %v %#v for 0.%s of rank %d", expectedOk, expected, ok, d, digits, rank)
+		}
+	})
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return a.Equal(*b) && a.Location() == b.Location()
+}
+
+// referenceRepeat is the regular expression RepeatFromString used to match.
+var referenceRepeat = regexp.MustCompile(`^(R|R(\d+))$`)
+
+// referenceRecurrence is the recurrence parser the scanner replaced, splitting
+// the components and matching the repeat with a regular expression.
+func referenceRecurrence(recurrenceString string) (Recurrence, error) {
+	components := strings.Split(recurrenceString, "/")
+	componentsCount := len(components)
+	offsets := make([]int, componentsCount)
+
+	for i := 1; i < componentsCount; i++ {
+		offsets[i] = offsets[i-1] + len(components[i-1]) + 1
+	}
+
+	if componentsCount < 2 {
+		return Recurrence{}, newParseError(len(recurrenceString), "", ReasonComponentCount)
+	} else if componentsCount > 3 {
+		return Recurrence{}, newParseError(offsets[3]-1, recurrenceString[offsets[3]-1:], ReasonComponentCount).inComponent(3, 0)
+	}
+
+	var repeat int
+
+	if match := referenceRepeat.FindStringSubmatch(components[0]); match == nil && strings.HasPrefix(components[0], "R") {
+		return Recurrence{}, newParseError(1, components[0][1:], ReasonBadRepeat)
+	} else if match == nil {
+		return Recurrence{}, newParseError(0, components[0], ReasonBadRepeat)
+	} else if match[1] == "R" {
+		repeat = -1
+	} else if val, err := strconv.Atoi(match[2]); err != nil {
+		return Recurrence{}, newParseError(1, match[2], ReasonOutOfRange)
+	} else {
+		repeat = val
+	}
+
+	recurrence := Recurrence{Repetitions: repeat, Location: time.UTC}
+
+	for i := 1; i < componentsCount; i++ {
+		if strings.HasPrefix(components[i], "-P") {
+			return Recurrence{}, newParseError(0, components[i], ReasonNegativeDuration).inComponent(i, offsets[i])
+		}
+	}
+
+	startDate, err := DateFromString(components[1])
+
+	if err != nil && strings.HasPrefix(components[1], "P") {
+		duration, err := DurationFromString(components[1])
+
+		if err != nil {
+			return Recurrence{}, err.(*ParseError).inComponent(1, offsets[1])
+		}
+
+		recurrence.Duration = &duration
+
+		if componentsCount == 2 {
+			return recurrence, nil
+		}
+	} else if err != nil {
+		return Recurrence{}, err.(*ParseError).inComponent(1, offsets[1])
+	} else {
+		recurrence.Start = &startDate
+	}
+
+	if componentsCount != 3 {
+		return Recurrence{}, newParseError(len(recurrenceString), "", ReasonMissingComponent).inComponent(2, 0)
+	}
+
+	endDate, err := DateFromString(components[2])
+
+	if err != nil && strings.HasPrefix(components[2], "P") {
+		if recurrence.Duration != nil {
+			return Recurrence{}, newParseError(0, components[2], ReasonTwoDurations).inComponent(2, offsets[2])
+		}
+
+		duration, err := DurationFromString(components[2])
+
+		if err != nil {
+			return Recurrence{}, err.(*ParseError).inComponent(2, offsets[2])
+		}
+
+		recurrence.Duration = &duration
+	} else if err != nil {
+		return Recurrence{}, err.(*ParseError).inComponent(2, offsets[2])
+	} else {
+		recurrence.End = &endDate
+	}
+
+	if recurrence.Start != nil && recurrence.End != nil && !recurrence.End.After(*recurrence.Start) {
+		return Recurrence{}, newParseError(0, components[2], ReasonEndBeforeStart).inComponent(2, offsets[2])
+	}
+
+	return recurrence, nil
+}
+
+// referenceAddFraction is the exact rational arithmetic addFraction replaced.
+func (d *RecurrenceInterval) referenceAddFraction(rank int, digits string) bool {
+	fraction, ok := new(big.Rat).SetString("0." + digits)
+
+	if !ok {
+		return false
+	}
+
+	for fraction.Sign() != 0 {
+		step := fractionFactors[rank]
+
+		if step.next == 0 {
+			return false
+		}
+
+		fraction.Mul(fraction, new(big.Rat).SetUint64(step.factor))
+		whole := new(big.Int).Quo(fraction.Num(), fraction.Denom())
+		fraction.Sub(fraction, new(big.Rat).SetInt(whole))
+
+		rank = step.next
+
+		switch rank {
+		case rankMonths:
+			d.Months += int(whole.Int64())
+		case rankDays:
+			d.Days += int(whole.Int64())
+		case rankHours:
+			d.Hours += int(whole.Int64())
+		case rankMinutes:
+			d.Minutes += int(whole.Int64())
+		case rankSeconds:
+			d.Seconds += int(whole.Int64())
+		default:
+			d.Nanoseconds += int(whole.Int64())
+
+			return fraction.Sign() == 0
+		}
+	}
+
+	return true
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"testing"
 	"time"
 )
@@ -8,12 +9,12 @@ import (
 func TestDurationFromString(t *testing.T) {
 	t.Parallel()
 
-	if _, err := DurationFromString("asdf"); err != ErrBadFormat {
+	if _, err := DurationFromString("asdf"); !errors.Is(err, ErrBadFormat) {
 		t.Errorf("Expected %s but got %s", ErrBadFormat, err)
 	}
 
 	// test without params
-	if _, err := DurationFromString("PYMDTHMS"); err != ErrBadFormat {
+	if _, err := DurationFromString("PYMDTHMS"); !errors.Is(err, ErrBadFormat) {
 		t.Errorf("Expected %s but got %#v", ErrBadFormat, err)
 	}
 
@@ -64,11 +65,90 @@ func TestDurationFromString(t *testing.T) {
 	}
 
 	// test with bad week string
-	if _, err := DurationFromString("PW"); err != ErrBadFormat {
+	if _, err := DurationFromString("PW"); !errors.Is(err, ErrBadFormat) {
 		t.Errorf("Expected %s but got %s", ErrBadFormat, err)
 	}
 }
 
+// durationGrammarTests are durations with the value they parse to, or the
+// reason they are rejected for.
+var durationGrammarTests = []struct {
+	Duration string
+	Lenient  bool
+	Expected RecurrenceInterval
+	Reason   ParseReason
+}{
+	/* correct formats */
+	{"P3D", false, RecurrenceInterval{Days: 3}, ""},
+	{"PT36H", false, RecurrenceInterval{Hours: 36}, ""},
+	{"P0Y1M", false, RecurrenceInterval{Months: 1}, ""},
+	{"P1DT0H", false, RecurrenceInterval{Days: 1}, ""},
+	{"P1W2D", true, RecurrenceInterval{Weeks: 1, Days: 2}, ""},
+	{"P1Y2WT3H", true, RecurrenceInterval{Years: 1, Weeks: 2, Hours: 3}, ""},
+	{"PT0.5H", false, RecurrenceInterval{Minutes: 30}, ""},
+	{"PT1,5M", false, RecurrenceInterval{Minutes: 1, Seconds: 30}, ""},
+	{"PT0.250S", false, RecurrenceInterval{Nanoseconds: 250000000}, ""},
+	{"PT1.5S", false, RecurrenceInterval{Seconds: 1, Nanoseconds: 500000000}, ""},
+	{"P0.5Y", false, RecurrenceInterval{Months: 6}, ""},
+	{"P1.5W", false, RecurrenceInterval{Weeks: 1, Days: 3, Hours: 12}, ""},
+	{"P1DT0.001H", false, RecurrenceInterval{Days: 1, Seconds: 3, Nanoseconds: 600000000}, ""},
+	{"P0001-02-03T04:05:06", false, RecurrenceInterval{Years: 1, Months: 2, Days: 3, Hours: 4, Minutes: 5, Seconds: 6}, ""},
+	{"P00010203T040506", false, RecurrenceInterval{Years: 1, Months: 2, Days: 3, Hours: 4, Minutes: 5, Seconds: 6}, ""},
+	{"P0000-00-01", false, RecurrenceInterval{Days: 1}, ""},
+	{"P0000-100", false, RecurrenceInterval{Days: 100}, ""},
+	{"P0000000T24:00:00", false, RecurrenceInterval{Hours: 24}, ""},
+	{"P00000000T000001,5", false, RecurrenceInterval{Seconds: 1, Nanoseconds: 500000000}, ""},
+	/* incorrect formats */
+	{"xxP1Dyy", false, RecurrenceInterval{}, ReasonMalformedDuration},
+	{"P1Dyy", false, RecurrenceInterval{}, ReasonUnknownDesignator},
+	{"P1W2D", false, RecurrenceInterval{}, ReasonCombinedWeeks},
+	{"P1H", false, RecurrenceInterval{}, ReasonMissingTimeDesignator},
+	{"P1DT", false, RecurrenceInterval{}, ReasonEmptyTime},
+	{"PT1D", false, RecurrenceInterval{}, ReasonUnknownDesignator},
+	{"P1D2Y", false, RecurrenceInterval{}, ReasonOutOfOrder},
+	{"P1M1M", false, RecurrenceInterval{}, ReasonOutOfOrder},
+	{"PT1HT1M", false, RecurrenceInterval{}, ReasonOutOfOrder},
+	{"P12", false, RecurrenceInterval{}, ReasonMissingDesignator},
+	{"P", false, RecurrenceInterval{}, ReasonMissingValue},
+	{"P0D", false, RecurrenceInterval{}, ReasonZeroDuration},
+	{"P99999999999999999999Y", false, RecurrenceInterval{}, ReasonOutOfRange},
+	{"PT1.5H30M", false, RecurrenceInterval{}, ReasonFractionNotLast},
+	{"P1.5DT1H", false, RecurrenceInterval{}, ReasonFractionNotLast},
+	{"P0.5M", false, RecurrenceInterval{}, ReasonInexactFraction},
+	{"P0.1Y", false, RecurrenceInterval{}, ReasonInexactFraction},
+	{"PT0.0000000001S", false, RecurrenceInterval{}, ReasonInexactFraction},
+	{"PT1.S", false, RecurrenceInterval{}, ReasonMissingValue},
+	{"PT.5S", false, RecurrenceInterval{}, ReasonUnknownDesignator},
+	{"PT0.0S", false, RecurrenceInterval{}, ReasonZeroDuration},
+	{"P0001-13-03T04:05:06", false, RecurrenceInterval{}, ReasonOutOfRange},
+	{"P0001-02-31", false, RecurrenceInterval{}, ReasonOutOfRange},
+	{"P0000-00-00T00:60:00", false, RecurrenceInterval{}, ReasonOutOfRange},
+	{"P0001-02-03T04:05", false, RecurrenceInterval{}, ReasonMalformedDuration},
+	{"P0001-0203", false, RecurrenceInterval{}, ReasonMalformedDuration},
+	{"P00010203T", false, RecurrenceInterval{}, ReasonMalformedDuration},
+	{"P0000-00-00", false, RecurrenceInterval{}, ReasonZeroDuration},
+}
+
+func TestDurationGrammar(t *testing.T) {
+	t.Parallel()
+
+	for index, test := range durationGrammarTests {
+		dur, err := RecurrenceParser{Lenient: test.Lenient}.Duration(test.Duration)
+
+		var parseErr *ParseError
+
+		if test.Reason == "" && err != nil {
+			t.Errorf("Test %d expected correct format but got error %s", index+1, err)
+		} else if test.Reason == "" && dur != test.Expected {
+			t.Errorf("Test %d expected %#v but got %#v", index+1, test.Expected, dur)
+		} else if test.Reason != "" && !errors.As(err, &parseErr) {
+			t.Errorf("Test %d expected parse error but got %#v", index+1, err)
+		} else if test.Reason != "" && parseErr.Reason != test.Reason {
+			t.Errorf("Test %d expected reason %s but got %s", index+1, test.Reason, parseErr.Reason)
+		}
+	}
+}
+
 func TestRepeatFromString(t *testing.T) {
 	t.Parallel()
 
@@ -90,7 +170,7 @@ func TestRepeatFromString(t *testing.T) {
 
 	repeat, err = RepeatFromString("R-1")
 
-	if err == nil || err != ErrBadFormat {
+	if !errors.Is(err, ErrBadFormat) {
 		t.Errorf("Expected invalid format but got error %#v", err)
 	}
 
@@ -103,27 +183,54 @@ func TestRepeatFromString(t *testing.T) {
 	}
 }
 
+// dateFormatTests are dates with the time they parse to in UTC, or none when
+// they are rejected.
+var dateFormatTests = []struct {
+	DateString    string
+	Expected      string
+	FormatCorrect bool
+}{
+	/* correct formats */
+	{"19850412T232050", "1985-04-12T23:20:50Z", true},     //basic
+	{"1985-04-12T23:20:50", "1985-04-12T23:20:50Z", true}, //extended
+	{"2020-02-29T23:20:50", "2020-02-29T23:20:50Z", true}, //leap year,
+	{"1985-04-12T23:20:50Z", "1985-04-12T23:20:50Z", true},
+	{"1985-04-12T23:20:50+02:00", "1985-04-12T21:20:50Z", true},  //offset
+	{"19850412T232050-0130", "1985-04-13T00:50:50Z", true},       //basic offset
+	{"1985-04-12T23:20:50+02", "1985-04-12T21:20:50Z", true},     //hour offset
+	{"1985-04-12T23:20:50.5", "1985-04-12T23:20:50.5Z", true},    //fractional seconds
+	{"1985-04-12T23:20:50,25Z", "1985-04-12T23:20:50.25Z", true}, //decimal comma
+	{"1985-04-12T23:20", "1985-04-12T23:20:00Z", true},           //reduced to minutes
+	{"1985-04-12T23.5", "1985-04-12T23:30:00Z", true},            //fractional hours
+	{"1985-04-12", "1985-04-12T00:00:00Z", true},                 //date only
+	{"19850412", "1985-04-12T00:00:00Z", true},                   //basic date only
+	{"1985-04", "1985-04-01T00:00:00Z", true},                    //reduced to month
+	{"2017-032", "2017-02-01T00:00:00Z", true},                   //ordinal
+	{"2016366T1200", "2016-12-31T12:00:00Z", true},               //basic ordinal in leap year
+	{"2017-W05-3", "2017-02-01T00:00:00Z", true},                 //week date
+	{"2015W534", "2015-12-31T00:00:00Z", true},                   //basic week date in 53 week year
+	{"2017-W01", "2017-01-02T00:00:00Z", true},                   //reduced week date
+	/* incorrect formats */
+	{"19850412232050", "", false},            //no T separator
+	{"1985-31-12T23:20:50", "", false},       //month is more than 12
+	{"1985-04-31T23:20:50", "", false},       //day is more than days in month
+	{"2019-02-29T23:20:50", "", false},       //non leap year but 29 days in Feb
+	{"1985-04-12T25:60:60", "", false},       //time is incorrect
+	{"1985-04-12T", "", false},               //empty time
+	{"1985-04-12T23:20:50+25:00", "", false}, //offset out of range
+	{"1985-04-12T23:20:50Z01", "", false},    //junk after Z
+	{"1985-04-12T23:20:", "", false},         //missing seconds
+	{"1985-04-12T23:20:50.", "", false},      //empty fraction
+	{"2017-366", "", false},                  //ordinal out of range
+	{"2017-W53-1", "", false},                //no week 53 in 2017
+	{"2017-W05-8", "", false},                //weekday out of range
+	{"198504", "", false},                    //basic year and month
+}
+
 func TestDateFromString(t *testing.T) {
 	t.Parallel()
 
-	var formatTests = []struct {
-		DateString    string
-		Expected      string
-		FormatCorrect bool
-	}{
-		/* correct formats */
-		{"19850412T232050", "1985-04-12T23:20:50Z", true},     //basic
-		{"1985-04-12T23:20:50", "1985-04-12T23:20:50Z", true}, //extended
-		{"2020-02-29T23:20:50", "2020-02-29T23:20:50Z", true}, //leap year,
-		/* incorrect formats */
-		{"19850412232050", "", false},      //no T separator
-		{"1985-31-12T23:20:50", "", false}, //month is more than 12
-		{"1985-04-31T23:20:50", "", false}, //day is more than days in month
-		{"2019-02-29T23:20:50", "", false}, //non leap year but 29 days in Feb
-		{"1985-04-12T25:60:60", "", false}, //time is incorrect
-	}
-
-	for index, test := range formatTests {
+	for index, test := range dateFormatTests {
 		date, err := DateFromString(test.DateString)
 
 		if test.FormatCorrect && err != nil {
@@ -131,11 +238,11 @@ func TestDateFromString(t *testing.T) {
 		} else if !test.FormatCorrect {
 			if err == nil {
 				t.Errorf("Expected incorrect format but got %#v", date)
-			} else if err != ErrBadFormat {
+			} else if !errors.Is(err, ErrBadFormat) {
 				t.Errorf("Expected incorrect format error but got error %#v", err)
 			}
 		} else {
-			result := date.Format(time.RFC3339)
+			result := date.Format(time.RFC3339Nano)
 
 			if result != test.Expected {
 				t.Errorf("Test %d expected %s but got %s", index+1, test.Expected, result)
@@ -144,34 +251,70 @@ func TestDateFromString(t *testing.T) {
 	}
 }
 
-func TestFullISORecurrenceFromString(t *testing.T) {
+func TestDateFromStringIn(t *testing.T) {
 	t.Parallel()
 
-	var formatTests = []struct {
-		RecurrenceString    string
-		ExpectedRepetitions int
-		ExpectedStartDate   string
-		ExpectedEndDate     string
-		ExpectedInterval    RecurrenceInterval
-		FormatCorrect       bool
+	berlin, err := time.LoadLocation("Europe/Berlin")
+
+	if err != nil {
+		t.Skipf("No time zone database: %s", err)
+	}
+
+	var tests = []struct {
+		DateString string
+		Expected   string
 	}{
-		/* correct formats */
-		{"R1/1985-04-12T23:20:50/1986-04-12T23:20:50", 1, "1985-04-12T23:20:50Z", "1986-04-12T23:20:50Z", RecurrenceInterval{}, true},
-		{"R/1985-04-12T23:20:50/P1Y2M3DT4H5M6S", -1, "1985-04-12T23:20:50Z", "<nil>", RecurrenceInterval{Years: 1, Months: 2, Days: 3, Hours: 4, Minutes: 5, Seconds: 6}, true},
-		{"R10/19850412T232050/P1W", 10, "1985-04-12T23:20:50Z", "<nil>", RecurrenceInterval{Weeks: 1}, true},
-		{"R/PT1H2M3S/19850412T232050", -1, "<nil>", "1985-04-12T23:20:50Z", RecurrenceInterval{Hours: 1, Minutes: 2, Seconds: 3}, true},
-		/* incorrect formats */
-		{RecurrenceString: "PT1H2M3S", FormatCorrect: false},
-		{RecurrenceString: "R-1/PT1H2M3S", FormatCorrect: false},
-		{RecurrenceString: "R0", FormatCorrect: false},
-		{RecurrenceString: "P1Y/R1", FormatCorrect: false},
-		{RecurrenceString: "P1Y/R/19850412T232050", FormatCorrect: false},
-		{RecurrenceString: "R/19850412T232050", FormatCorrect: false},
-		{RecurrenceString: "R/19850412T232050/19850412T232050/P1Y", FormatCorrect: false},
-		{RecurrenceString: "19850412T232050/19850412T232050/P1Y", FormatCorrect: false},
-	}
-
-	for index, test := range formatTests {
+		{"2017-03-19T09:00:00", "2017-03-19T08:00:00Z"},
+		// the wall clock is read on the day clocks go forward or back
+		{"2017-03-26T09:00:00", "2017-03-26T07:00:00Z"},
+		{"20171029T090000", "2017-10-29T08:00:00Z"},
+		{"2017-03-26T09.5", "2017-03-26T07:30:00Z"},
+		// a zone in the string wins over the location
+		{"2017-03-26T09:00:00Z", "2017-03-26T09:00:00Z"},
+		{"2017-03-26", "2017-03-25T23:00:00Z"},
+	}
+
+	for index, test := range tests {
+		date, err := DateFromStringIn(test.DateString, berlin)
+
+		if err != nil {
+			t.Errorf("Test %d expected correct format but got error %#v", index+1, err)
+		} else if result := date.UTC().Format(time.RFC3339Nano); result != test.Expected {
+			t.Errorf("Test %d expected %s but got %s", index+1, test.Expected, result)
+		}
+	}
+}
+
+// recurrenceFormatTests are recurrences with the repetitions, start, end and
+// duration they parse to.
+var recurrenceFormatTests = []struct {
+	RecurrenceString    string
+	ExpectedRepetitions int
+	ExpectedStartDate   string
+	ExpectedEndDate     string
+	ExpectedInterval    RecurrenceInterval
+	FormatCorrect       bool
+}{
+	/* correct formats */
+	{"R1/1985-04-12T23:20:50/1986-04-12T23:20:50", 1, "1985-04-12T23:20:50Z", "1986-04-12T23:20:50Z", RecurrenceInterval{}, true},
+	{"R/1985-04-12T23:20:50/P1Y2M3DT4H5M6S", -1, "1985-04-12T23:20:50Z", "<nil>", RecurrenceInterval{Years: 1, Months: 2, Days: 3, Hours: 4, Minutes: 5, Seconds: 6}, true},
+	{"R10/19850412T232050/P1W", 10, "1985-04-12T23:20:50Z", "<nil>", RecurrenceInterval{Weeks: 1}, true},
+	{"R/PT1H2M3S/19850412T232050", -1, "<nil>", "1985-04-12T23:20:50Z", RecurrenceInterval{Hours: 1, Minutes: 2, Seconds: 3}, true},
+	/* incorrect formats */
+	{RecurrenceString: "PT1H2M3S", FormatCorrect: false},
+	{RecurrenceString: "R-1/PT1H2M3S", FormatCorrect: false},
+	{RecurrenceString: "R0", FormatCorrect: false},
+	{RecurrenceString: "P1Y/R1", FormatCorrect: false},
+	{RecurrenceString: "P1Y/R/19850412T232050", FormatCorrect: false},
+	{RecurrenceString: "R/19850412T232050", FormatCorrect: false},
+	{RecurrenceString: "R/19850412T232050/19850412T232050/P1Y", FormatCorrect: false},
+	{RecurrenceString: "19850412T232050/19850412T232050/P1Y", FormatCorrect: false},
+}
+
+func TestFullISORecurrenceFromString(t *testing.T) {
+	t.Parallel()
+
+	for index, test := range recurrenceFormatTests {
 		recurrence, err := RecurrenceFromString(test.RecurrenceString)
 
 		if test.FormatCorrect && err != nil {
@@ -179,7 +322,7 @@ func TestFullISORecurrenceFromString(t *testing.T) {
 		} else if !test.FormatCorrect {
 			if err == nil {
 				t.Errorf("Test %d expected incorrect format but got %#v", index+1, recurrence)
-			} else if err != ErrBadFormat {
+			} else if !errors.Is(err, ErrBadFormat) {
 				t.Errorf("Test %d expected incorrect format error but got error %#v", index+1, err)
 			}
 		} else {
@@ -223,3 +366,85 @@ func TestFullISORecurrenceFromString(t *testing.T) {
 		}
 	}
 }
+
+// recurrenceParseErrorTests are rejected recurrences with where and why.
+var recurrenceParseErrorTests = []struct {
+	RecurrenceString string
+	Component        int
+	Offset           int
+	Token            string
+	Reason           ParseReason
+}{
+	{"PT1H", 0, 4, "", ReasonComponentCount},
+	{"X/PT1H", 0, 0, "X", ReasonBadRepeat},
+	{"R-1/PT1H", 0, 1, "-1", ReasonBadRepeat},
+	{"R/P0W", 1, 2, "P0W", ReasonZeroDuration},
+	{"R/PT", 1, 3, "T", ReasonEmptyTime},
+	{"R/19850412T232050", 2, 17, "", ReasonMissingComponent},
+	{"R/19850412T232050/19850412T232050/P1Y", 3, 33, "/P1Y", ReasonComponentCount},
+	{"R/1985-04-12T23:20:50/1985-04-13T23:2", 2, 36, "2", ReasonBadDate},
+	{"R/1985-13-12T23:20:50/P1W", 1, 2, "1985-13-12", ReasonOutOfRange},
+	{"R/P1W/P1M", 2, 6, "P1M", ReasonTwoDurations},
+	{"R/19850412T232050/19850412T232049", 2, 18, "19850412T232049", ReasonEndBeforeStart},
+	{"R/-P1D", 1, 2, "-P1D", ReasonNegativeDuration},
+	{"R/P1D/-PT1H", 2, 6, "-PT1H", ReasonNegativeDuration},
+}
+
+func TestRecurrenceParseError(t *testing.T) {
+	t.Parallel()
+
+	for index, test := range recurrenceParseErrorTests {
+		_, err := RecurrenceFromString(test.RecurrenceString)
+
+		var parseErr *ParseError
+
+		if !errors.As(err, &parseErr) {
+			t.Errorf("Test %d expected parse error but got %#v", index+1, err)
+			continue
+		}
+
+		expected := ParseError{Component: test.Component, Offset: test.Offset, Token: test.Token, Reason: test.Reason}
+
+		if *parseErr != expected {
+			t.Errorf("Test %d expected %#v but got %#v", index+1, expected, *parseErr)
+		}
+	}
+}
+
+// negativeDurationTests are negative durations with the value they parse to.
+var negativeDurationTests = []struct {
+	Duration string
+	Expected RecurrenceInterval
+}{
+	{"-P2D", RecurrenceInterval{Days: -2}},
+	{"-PT3H30M", RecurrenceInterval{Hours: -3, Minutes: -30}},
+	{"-P1W", RecurrenceInterval{Weeks: -1}},
+	{"-PT0.5S", RecurrenceInterval{Nanoseconds: -500000000}},
+	{"-P0001-02-00", RecurrenceInterval{Years: -1, Months: -2}},
+}
+
+func TestNegativeDurationFromString(t *testing.T) {
+	t.Parallel()
+
+	for index, test := range negativeDurationTests {
+		dur, err := DurationFromString(test.Duration)
+
+		if err != nil {
+			t.Errorf("Test %d expected no error but got %s", index+1, err)
+		} else if dur != test.Expected {
+			t.Errorf("Test %d expected %#v but got %#v", index+1, test.Expected, dur)
+		}
+	}
+
+	for index, test := range []string{"-", "--P1D", "-P", "-P0D", "+P1D"} {
+		if _, err := DurationFromString(test); !errors.Is(err, ErrBadFormat) {
+			t.Errorf("Test %d expected %s but got %v", index+1, ErrBadFormat, err)
+		}
+	}
+
+	var parseErr *ParseError
+
+	if _, err := DurationFromString("-P1X"); !errors.As(err, &parseErr) || parseErr.Offset != 3 {
+		t.Errorf("Expected parse error at offset 3 but got %#v", err)
+	}
+}
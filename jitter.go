@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"hash/fnv"
+	"time"
+)
+
+var (
+	//ErrBadJitter jitter window not a positive duration without years or months, or set for a rule without start or end
+	ErrBadJitter = errors.New("bad jitter")
+)
+
+// JitteredSchedule delays every occurrence of another schedule by the same
+// offset, so tasks sharing a rule do not all fire at once.
+type JitteredSchedule struct {
+	Base   Schedule
+	Offset time.Duration
+}
+
+// jitterOffset picks a whole number of seconds within the window from a hash
+// of the task id, the same one on every run.
+func jitterOffset(id string, window time.Duration) time.Duration {
+	seconds := uint64(window / time.Second)
+
+	if seconds == 0 {
+		return 0
+	}
+
+	hash := fnv.New64a()
+	hash.Write([]byte(id))
+
+	return time.Duration(hash.Sum64()%seconds) * time.Second
+}
+
+// Next returns the first delayed occurrence strictly after the given time.
+func (s JitteredSchedule) Next(after time.Time) (time.Time, bool) {
+	next, ok := s.Base.Next(after.Add(-s.Offset))
+
+	if !ok {
+		return time.Time{}, false
+	}
+
+	return next.Add(s.Offset), true
+}
+
+// String returns the canonical base rule, the jitter is a task setting.
+func (s JitteredSchedule) String() string {
+	return s.Base.String()
+}
+
+// firesAt tells whether a delayed fire time is still an occurrence of the
+// base schedule, see RecurrenceSet.
+func (s JitteredSchedule) firesAt(t time.Time) bool {
+	if base, ok := s.Base.(interface{ firesAt(time.Time) bool }); ok {
+		return base.firesAt(t.Add(-s.Offset))
+	}
+
+	return true
+}
+
+// remaining returns the repetitions left of the base schedule counting the
+// occurrence delayed to t, see scheduleRemaining.
+func (s JitteredSchedule) remaining(t time.Time) int {
+	return scheduleRemaining(s.Base, t.Add(-s.Offset))
+}
+
+// JitterInterval returns the offset as a duration, e.g. PT2M13S.
+func (s JitteredSchedule) JitterInterval() RecurrenceInterval {
+	return RecurrenceInterval{Seconds: int(s.Offset / time.Second)}.Normalize()
+}
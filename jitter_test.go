@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTaskJitterOffset(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		Task     Task
+		Expected time.Duration
+		Error    error
+	}{
+		{Task{Id: "report-43", Jitter: "PT5M"}, 3*time.Minute + 35*time.Second, nil},
+		{Task{Id: "backup", Jitter: "PT1H"}, 27*time.Minute + 27*time.Second, nil},
+		{Task{Id: "backup"}, 0, nil},
+		{Task{Id: "backup", Jitter: "PT0.5S"}, 0, nil},
+		{Task{Id: "backup", Jitter: "-PT5M"}, 0, ErrBadJitter},
+		{Task{Id: "backup", Jitter: "P1M"}, 0, ErrBadJitter},
+		{Task{Id: "backup", Jitter: "5 minutes"}, 0, ErrBadJitter},
+	}
+
+	for index, test := range tests {
+		result, err := test.Task.JitterOffset()
+
+		if err != test.Error {
+			t.Errorf("Test %d expected %v but got %v", index+1, test.Error, err)
+		}
+
+		if result != test.Expected {
+			t.Errorf("Test %d expected %s but got %s", index+1, test.Expected, result)
+		}
+	}
+
+	spread := map[time.Duration]bool{}
+
+	for i := 0; i < 1000; i++ {
+		offset, _ := Task{Id: fmt.Sprintf("task-%d", i), Jitter: "PT1H"}.JitterOffset()
+
+		if offset < 0 || offset >= time.Hour {
+			t.Fatalf("Expected an offset within an hour but got %s", offset)
+		}
+
+		spread[offset.Truncate(time.Minute)] = true
+	}
+
+	if len(spread) < 55 {
+		t.Errorf("Expected offsets spread over the hour but got %d distinct minutes", len(spread))
+	}
+}
+
+func TestJitteredScheduleNext(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		Task     Task
+		After    time.Time
+		Expected []string
+	}{
+		{Task{Id: "report-43", Rule: "0 * * * *", Jitter: "PT5M"}, now, []string{"2017-01-01T00:03:35Z", "2017-01-01T01:03:35Z", "2017-01-01T02:03:35Z"}},
+		// an occurrence is due until its delayed time
+		{Task{Id: "report-43", Rule: "0 * * * *", Jitter: "PT5M"}, now.Add(time.Minute), []string{"2017-01-01T00:03:35Z", "2017-01-01T01:03:35Z", "2017-01-01T02:03:35Z"}},
+		{Task{Id: "backup", Rule: "R2/2017-01-01T00:00:00Z/P1D", Jitter: "PT1H"}, now, []string{"2017-01-01T00:27:27Z", "2017-01-02T00:27:27Z"}},
+		// excluded and extra dates are given undelayed
+		{Task{Id: "backup", Rule: "FREQ=DAILY", Jitter: "PT1H", ExDates: []time.Time{now.AddDate(0, 0, 1)}, RDates: []time.Time{now.Add(12 * time.Hour)}}, now, []string{"2017-01-01T00:27:27Z", "2017-01-01T12:27:27Z", "2017-01-03T00:27:27Z"}},
+	}
+
+	for index, test := range tests {
+		schedule, err := test.Task.Schedule()
+
+		if err != nil {
+			t.Fatalf("Test %d expected correct rule but got error %s", index+1, err)
+		}
+
+		result := nextOccurrences(schedule, test.After, 3)
+
+		if strings.Join(result, " ") != strings.Join(test.Expected, " ") {
+			t.Errorf("Test %d expected %v but got %v", index+1, test.Expected, result)
+		}
+	}
+
+	calendar, _ := NewCalendar("test-jitter-roll", nil, nil)
+	RegisterCalendar(calendar)
+
+	// Friday 6 January 23:45 is delayed onto Saturday and rolled to Monday,
+	// as are the weekend occurrences
+	schedule, _ := Task{Id: "backup", Rule: "R/2017-01-06T23:45:00Z/P1D", Jitter: "PT1H", Calendar: "test-jitter-roll"}.Schedule()
+	expected := []string{"2017-01-09T00:12:27Z", "2017-01-10T00:12:27Z", "2017-01-11T00:12:27Z"}
+
+	if result := nextOccurrences(schedule, now, 3); strings.Join(result, " ") != strings.Join(expected, " ") {
+		t.Errorf("Expected %v but got %v", expected, result)
+	}
+
+	if _, err := (Task{Id: "backup", Rule: "R/PT1H", Jitter: "PT5M"}).Schedule(); err != ErrBadJitter {
+		t.Errorf("Expected %s but got %v", ErrBadJitter, err)
+	}
+
+	schedule, _ = Task{Id: "backup", Rule: "FREQ=DAILY", Jitter: "PT1H", ExDates: []time.Time{now.AddDate(0, 0, 1)}}.Schedule()
+	jittered := schedule.(JitteredSchedule)
+
+	if !jittered.firesAt(time.Date(2017, 1, 1, 0, 27, 27, 0, time.UTC)) || jittered.firesAt(time.Date(2017, 1, 2, 0, 27, 27, 0, time.UTC)) {
+		t.Errorf("Expected the delayed excluded date not to fire")
+	}
+}
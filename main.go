@@ -1,17 +1,22 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"log"
 	"net"
 	"runtime"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/kataras/iris"
 	"github.com/valyala/tcplisten"
 )
 
 var (
-	addr = flag.String("addr", ":8080", "TCP address to listen to")
+	addr          = flag.String("addr", ":8080", "TCP address to listen to")
+	calendarFiles = flag.String("calendars", "", "Comma separated iCalendar (.ics) and JSON calendar files to load")
 )
 
 func getListener() net.Listener {
@@ -32,6 +37,19 @@ func getListener() net.Listener {
 	return l
 }
 
+// badRequest responds with the error, and with its position and reason when it
+// is a rule parse error.
+func badRequest(ctx iris.Context, err error) {
+	var parseErr *ParseError
+
+	if errors.As(err, &parseErr) {
+		ctx.Values().Set("details", parseErr)
+	}
+
+	ctx.Values().Set("error", err.Error())
+	ctx.StatusCode(iris.StatusBadRequest)
+}
+
 func main() {
 	db, err := initDb("tempo.db")
 
@@ -39,11 +57,21 @@ func main() {
 		log.Fatal(err)
 	}
 
+	if err := loadCalendars(*db); err != nil {
+		log.Fatal(err)
+	}
+
 	app := iris.New()
 
 	app.OnAnyErrorCode(func(ctx iris.Context) {
 		if msg := ctx.Value("error"); msg != nil {
-			ctx.JSON(iris.Map{"error": ctx.Value("error")})
+			response := iris.Map{"error": msg}
+
+			if details := ctx.Value("details"); details != nil {
+				response["details"] = details
+			}
+
+			ctx.JSON(response)
 		} else {
 			ctx.JSON(iris.Map{"error": "unknown"})
 		}
@@ -58,6 +86,12 @@ func main() {
 			return
 		}
 
+		locale := ctx.URLParamDefault("locale", DefaultLocale)
+
+		for i := range tasks {
+			tasks[i].Describe(locale)
+		}
+
 		ctx.JSON(tasks)
 	})
 
@@ -75,6 +109,7 @@ func main() {
 			return
 		}
 
+		task.Describe(ctx.URLParamDefault("locale", DefaultLocale))
 		ctx.JSON(task)
 	})
 
@@ -90,17 +125,178 @@ func main() {
 		dbTask, err := addTask(*db, *task)
 
 		if err != nil {
-			ctx.Values().Set("error", err.Error())
-			ctx.StatusCode(iris.StatusBadRequest)
+			badRequest(ctx, err)
 			return
 		}
 
+		// the compiled rule and its description let the caller check a phrase
+		dbTask.Describe(ctx.URLParamDefault("locale", DefaultLocale))
+
 		ctx.StatusCode(201)
 		ctx.JSON(*dbTask)
 	})
 
+	for path, table := range map[string]string{"exDates": exDatesTable, "rDates": rDatesTable} {
+		table := table
+
+		app.Post("/tasks/{id:string}/"+path, func(ctx iris.Context) {
+			var dates []time.Time
+
+			if err := ctx.ReadJSON(&dates); err != nil {
+				badRequest(ctx, err)
+				return
+			}
+
+			task, err := addTaskDates(*db, table, ctx.Params().Get("id"), dates, time.Now())
+
+			if err != nil {
+				badRequest(ctx, err)
+				return
+			} else if task == nil {
+				ctx.StatusCode(iris.StatusNotFound)
+				return
+			}
+
+			ctx.JSON(task)
+		})
+
+		app.Delete("/tasks/{id:string}/"+path, func(ctx iris.Context) {
+			var dates []time.Time
+
+			if err := ctx.ReadJSON(&dates); err != nil {
+				badRequest(ctx, err)
+				return
+			}
+
+			task, err := removeTaskDates(*db, table, ctx.Params().Get("id"), dates, time.Now())
+
+			if err != nil {
+				ctx.Values().Set("error", err.Error())
+				ctx.StatusCode(iris.StatusInternalServerError)
+				return
+			} else if task == nil {
+				ctx.StatusCode(iris.StatusNotFound)
+				return
+			}
+
+			ctx.StatusCode(iris.StatusNoContent)
+		})
+	}
+
+	app.Get("/calendars", func(ctx iris.Context) {
+		ctx.JSON(Calendars())
+	})
+
+	app.Get("/calendars/{name:string}", func(ctx iris.Context) {
+		calendar, err := CalendarByName(ctx.Params().Get("name"))
+
+		if err != nil {
+			ctx.StatusCode(iris.StatusNotFound)
+			return
+		}
+
+		ctx.JSON(calendar)
+	})
+
+	app.Put("/calendars/{name:string}", func(ctx iris.Context) {
+		body, err := ctx.GetBody()
+
+		if err != nil {
+			badRequest(ctx, err)
+			return
+		}
+
+		calendar, err := calendarFromBody(ctx.Params().Get("name"), ctx.GetHeader("Content-Type"), body)
+
+		if err != nil {
+			badRequest(ctx, err)
+			return
+		}
+
+		if err := saveCalendar(*db, calendar); err != nil {
+			ctx.Values().Set("error", err.Error())
+			ctx.StatusCode(iris.StatusInternalServerError)
+			return
+		}
+
+		ctx.JSON(calendar)
+	})
+
+	app.Delete("/calendars/{name:string}", func(ctx iris.Context) {
+		if err := deleteCalendar(*db, ctx.Params().Get("name")); err == ErrCalendarInUse {
+			ctx.Values().Set("error", err.Error())
+			ctx.StatusCode(iris.StatusConflict)
+			return
+		} else if err != nil {
+			ctx.Values().Set("error", err.Error())
+			ctx.StatusCode(iris.StatusInternalServerError)
+			return
+		}
+
+		ctx.StatusCode(iris.StatusNoContent)
+	})
+
+	app.Get("/rules/preview", func(ctx iris.Context) {
+		task := Task{
+			Id:         ctx.URLParam("id"),
+			Rule:       ctx.URLParam("rule"),
+			TimeZone:   ctx.URLParam("timeZone"),
+			Calendar:   ctx.URLParam("calendar"),
+			Roll:       ctx.URLParam("roll"),
+			Jitter:     ctx.URLParam("jitter"),
+			DSTGap:     ctx.URLParam("dstGap"),
+			DSTOverlap: ctx.URLParam("dstOverlap"),
+			MonthEnd:   ctx.URLParam("monthEnd"),
+
+			LenientDurations: ctx.URLParam("lenientDurations") == "true",
+		}
+
+		from, count := time.Now(), 10
+
+		if param := ctx.URLParam("from"); param != "" {
+			loc, err := LocationFromString(task.TimeZone)
+
+			if err == nil {
+				from, err = DateFromStringIn(param, loc)
+			}
+
+			if err != nil {
+				badRequest(ctx, err)
+				return
+			}
+		}
+
+		if param := ctx.URLParam("count"); param != "" {
+			var err error
+
+			if count, err = strconv.Atoi(param); err != nil {
+				badRequest(ctx, ErrBadCount)
+				return
+			}
+		}
+
+		preview, err := previewRule(task, from, count, ctx.URLParamDefault("locale", DefaultLocale))
+
+		if err != nil {
+			badRequest(ctx, err)
+			return
+		}
+
+		ctx.JSON(preview)
+	})
+
 	flag.Parse()
 
+	for _, file := range strings.Split(*calendarFiles, ",") {
+		if file == "" {
+			continue
+		}
+
+		if err := loadCalendarFile(*db, file); err != nil {
+			log.Fatal("Loading calendar ", file, " failed ", err)
+		}
+	}
+
 	go startScheduler(*db, 10)
 
 	app.Run(iris.Listener(getListener()))
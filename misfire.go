@@ -0,0 +1,198 @@
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	// defaultEpsilon is the tolerance of a task without one, in seconds.
+	defaultEpsilon = 60
+	// defaultMaxCatchUp is the cap of catch-up runs of a task without one.
+	defaultMaxCatchUp = 10
+	// maxDueOccurrences bounds the occurrences a single tick looks at, the
+	// earlier ones are only counted.
+	maxDueOccurrences = 1000
+	// maxCountedOccurrences bounds the count of passed over occurrences of a
+	// schedule that is walked one occurrence at a time.
+	maxCountedOccurrences = 10000
+)
+
+var (
+	//ErrBadMisfirePolicy unknown misfire policy, or negative epsilon or catch-up cap
+	ErrBadMisfirePolicy = errors.New("bad misfire policy")
+)
+
+// MisfirePolicy tells what happens to occurrences that came due longer than
+// the task epsilon ago, because the scheduler was down or lagging.
+type MisfirePolicy int
+
+const (
+	// MisfireFireOnce runs the task once now for all of them, unless it runs
+	// for an occurrence on time anyway.
+	MisfireFireOnce MisfirePolicy = iota
+	// MisfireSkip runs none of them.
+	MisfireSkip
+	// MisfireCatchUp runs the task for every one of them, the latest ones up
+	// to the catch-up cap.
+	MisfireCatchUp
+)
+
+// MisfirePolicyFromString parses "fire-once", "skip" or "catch-up", an empty
+// string means fire-once.
+func MisfirePolicyFromString(policy string) (MisfirePolicy, error) {
+	switch policy {
+	case "", "fire-once":
+		return MisfireFireOnce, nil
+	case "skip":
+		return MisfireSkip, nil
+	case "catch-up":
+		return MisfireCatchUp, nil
+	}
+
+	return MisfireFireOnce, ErrBadMisfirePolicy
+}
+
+// validateMisfire checks the misfire policy of the task and fills in the
+// default epsilon and catch-up cap.
+func (task *Task) validateMisfire() error {
+	if _, err := MisfirePolicyFromString(task.Misfire); err != nil || task.Epsilon < 0 || task.MaxCatchUp < 0 {
+		return ErrBadMisfirePolicy
+	}
+
+	if task.Epsilon == 0 {
+		task.Epsilon = defaultEpsilon
+	}
+
+	if task.MaxCatchUp == 0 {
+		task.MaxCatchUp = defaultMaxCatchUp
+	}
+
+	return nil
+}
+
+// dueOccurrences returns the occurrences from the stored fire time up to now,
+// the latest maxDueOccurrences of them, and how many earlier ones were passed
+// over. The stored one is left out when it no longer is an occurrence, see
+// RecurrenceSet.
+func dueOccurrences(schedule Schedule, fireAt time.Time, now time.Time) ([]time.Time, int) {
+	var due []time.Time
+
+	if set, isSet := schedule.(interface{ firesAt(time.Time) bool }); !isSet || set.firesAt(fireAt) {
+		due = append(due, fireAt)
+	}
+
+	first, all := occurrencesUpTo(schedule, fireAt, now, maxDueOccurrences-len(due))
+
+	if all {
+		return append(due, first...), 0
+	}
+
+	// more than the cap come after lo, at most the cap after hi, and the
+	// occurrences seen so far guess where the latest ones start
+	lo, hi := fireAt, now
+	latest := []time.Time(nil)
+	from := seekGuess(first, now, maxDueOccurrences, lo, hi)
+
+	for hi.Sub(lo) > time.Nanosecond {
+		occurrences, all := occurrencesUpTo(schedule, from, now, maxDueOccurrences)
+
+		if !all {
+			lo = from
+		} else if hi, latest = from, occurrences; len(latest) == maxDueOccurrences {
+			break
+		}
+
+		if len(latest) > 1 {
+			from = seekGuess(latest, latest[0], maxDueOccurrences-len(latest), lo, hi)
+		} else {
+			from = seekGuess(first, now, maxDueOccurrences, lo, hi)
+		}
+	}
+
+	return latest, len(due) + countOccurrences(schedule, fireAt, hi)
+}
+
+// seekGuess returns the time count more occurrences before the given one
+// would start after, were they spaced like the given occurrences, or the
+// middle of lo and hi when that falls outside them.
+func seekGuess(occurrences []time.Time, before time.Time, count int, lo, hi time.Time) time.Time {
+	if n := len(occurrences); n > 1 {
+		spacing := occurrences[n-1].Sub(occurrences[0]) / time.Duration(n-1)
+
+		if guess := before.Add(-spacing*time.Duration(count) - spacing/2); guess.After(lo) && guess.Before(hi) {
+			return guess
+		}
+	}
+
+	return lo.Add(hi.Sub(lo) / 2)
+}
+
+// occurrencesUpTo returns the occurrences after the given time up to now, and
+// false when there are more than limit of them.
+func occurrencesUpTo(schedule Schedule, after time.Time, now time.Time, limit int) ([]time.Time, bool) {
+	var occurrences []time.Time
+
+	it := iterate(schedule, after)
+
+	for next, ok := it.Next(); ok && !next.After(now); next, ok = it.Next() {
+		if len(occurrences) == limit {
+			return occurrences, false
+		}
+
+		occurrences = append(occurrences, next)
+	}
+
+	return occurrences, true
+}
+
+// countOccurrences returns how many occurrences there are after the given
+// time up to upTo. A recurrence counts its slots, other schedules are walked
+// up to maxCountedOccurrences.
+func countOccurrences(schedule Schedule, after time.Time, upTo time.Time) int {
+	if recurrence, ok := schedule.(Recurrence); ok {
+		return recurrence.count(after, upTo)
+	}
+
+	count := 0
+	it := iterate(schedule, after)
+
+	for next, ok := it.Next(); ok && !next.After(upTo) && count < maxCountedOccurrences; next, ok = it.Next() {
+		count++
+	}
+
+	return count
+}
+
+// misfire splits the due occurrences into those the task runs for now and
+// those recorded as missed. Occurrences up to Epsilon seconds late run, the
+// later ones are settled by the misfire policy.
+func (task Task) misfire(due []time.Time, now time.Time) (run []time.Time, missed []time.Time) {
+	policy, _ := MisfirePolicyFromString(task.Misfire)
+	tolerance := time.Duration(task.Epsilon) * time.Second
+
+	var late []time.Time
+
+	for _, at := range due {
+		if now.Sub(at) > tolerance {
+			late = append(late, at)
+		} else {
+			run = append(run, at)
+		}
+	}
+
+	switch {
+	case len(late) == 0:
+		return run, nil
+	case policy == MisfireCatchUp && len(late) > task.MaxCatchUp:
+		cut := len(late) - task.MaxCatchUp
+
+		return append(late[cut:], run...), late[:cut]
+	case policy == MisfireCatchUp:
+		return append(late, run...), nil
+	case policy == MisfireFireOnce && len(run) == 0:
+		return late[len(late)-1:], late[:len(late)-1]
+	}
+
+	return run, late
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTaskMisfire(t *testing.T) {
+	t.Parallel()
+
+	at := func(minutes ...int) []time.Time {
+		var times []time.Time
+
+		for _, minute := range minutes {
+			times = append(times, now.Add(time.Duration(minute)*time.Minute))
+		}
+
+		return times
+	}
+
+	var tests = []struct {
+		Misfire    string
+		Epsilon    int
+		MaxCatchUp int
+		Due        []time.Time
+		Run        []time.Time
+		Missed     []time.Time
+	}{
+		// on time, or early by the lookahead of the scheduler loop
+		{"", 60, 10, at(0), at(0), nil},
+		{"skip", 60, 10, at(0), at(0), nil},
+		{"", 60, 10, at(-1, 0), at(-1, 0), nil},
+		// the latest of the late ones runs once
+		{"", 60, 10, at(-30, -20, -10), at(-10), at(-30, -20)},
+		{"fire-once", 60, 10, at(-30, -20, -10, 0), at(0), at(-30, -20, -10)},
+		{"skip", 60, 10, at(-30, -20, -10, 0), at(0), at(-30, -20, -10)},
+		{"skip", 600, 10, at(-30, -20, -10), at(-10), at(-30, -20)},
+		{"catch-up", 60, 10, at(-30, -20, -10, 0), at(-30, -20, -10, 0), nil},
+		{"catch-up", 60, 2, at(-40, -30, -20, -10, 0), at(-20, -10, 0), at(-40, -30)},
+	}
+
+	for index, test := range tests {
+		task := Task{Id: "test-misfire", Misfire: test.Misfire, Epsilon: test.Epsilon, MaxCatchUp: test.MaxCatchUp}
+		run, missed := task.misfire(test.Due, now)
+
+		if !sameTimes(run, test.Run) || !sameTimes(missed, test.Missed) {
+			t.Errorf("Test %d expected %v and missed %v but got %v and missed %v", index+1, test.Run, test.Missed, run, missed)
+		}
+	}
+}
+
+func TestValidateMisfire(t *testing.T) {
+	t.Parallel()
+
+	task := Task{Id: "test-misfire"}
+
+	if err := task.validateMisfire(); err != nil || task.Epsilon != defaultEpsilon || task.MaxCatchUp != defaultMaxCatchUp {
+		t.Errorf("Expected the default epsilon and catch-up cap but got %d %d %v", task.Epsilon, task.MaxCatchUp, err)
+	}
+
+	for index, task := range []Task{{Misfire: "later"}, {Epsilon: -1}, {Misfire: "catch-up", MaxCatchUp: -1}} {
+		if err := task.validateMisfire(); err != ErrBadMisfirePolicy {
+			t.Errorf("Test %d expected %s but got %v", index+1, ErrBadMisfirePolicy, err)
+		}
+	}
+}
+
+func TestDueOccurrences(t *testing.T) {
+	t.Parallel()
+
+	schedule, _ := ScheduleFromString("*/10 * * * *", ScheduleOptions{})
+	due, _ := dueOccurrences(schedule, now, now.Add(35*time.Minute))
+
+	if expected := []time.Time{now, now.Add(10 * time.Minute), now.Add(20 * time.Minute), now.Add(30 * time.Minute)}; !sameTimes(due, expected) {
+		t.Errorf("Expected %v but got %v", expected, due)
+	}
+
+	// an excluded fire time is no longer due
+	set := RecurrenceSet{Schedule: schedule, ExDates: []time.Time{now}}
+	due, _ = dueOccurrences(set, now, now.Add(15*time.Minute))
+
+	if expected := []time.Time{now.Add(10 * time.Minute)}; !sameTimes(due, expected) {
+		t.Errorf("Expected %v but got %v", expected, due)
+	}
+
+	// the scheduler may pick a task up before it is due
+	due, _ = dueOccurrences(schedule, now, now.Add(-5*time.Second))
+
+	if expected := []time.Time{now}; !sameTimes(due, expected) {
+		t.Errorf("Expected %v but got %v", expected, due)
+	}
+}
+
+func TestDueOccurrencesCap(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		Rule    string
+		Until   time.Duration
+		Skipped int
+	}{
+		{"* * * * *", 48 * time.Hour, 2880 + 1 - maxDueOccurrences},
+		{"R/2017-01-01T00:00:00Z/PT1S", 2 * time.Hour, 7200 + 1 - maxDueOccurrences},
+		{"R/PT1S/2018-01-01T00:00:00Z", 2 * time.Hour, 7200 + 1 - maxDueOccurrences},
+		{"R5000/2017-01-01T00:00:00Z/PT1S", 2 * time.Hour, 5000 - maxDueOccurrences},
+	}
+
+	for index, test := range tests {
+		schedule, err := ScheduleFromString(test.Rule, ScheduleOptions{})
+
+		if err != nil {
+			t.Fatalf("Test %d expected correct rule but got error %s", index+1, err)
+		}
+
+		until := now.Add(test.Until)
+		due, skipped := dueOccurrences(schedule, now, until)
+		last, _ := walkUpTo(schedule, until)
+
+		if len(due) != maxDueOccurrences || !due[len(due)-1].Equal(last) {
+			t.Errorf("Test %d expected the latest %d occurrences up to %s but got %d up to %s", index+1, maxDueOccurrences, last, len(due), due[len(due)-1])
+		}
+
+		if skipped != test.Skipped {
+			t.Errorf("Test %d expected %d skipped occurrences but got %d", index+1, test.Skipped, skipped)
+		}
+	}
+}
+
+// walkUpTo returns the last occurrence of the schedule up to the given time
+// after now.
+func walkUpTo(schedule Schedule, until time.Time) (time.Time, bool) {
+	last, found := time.Time{}, false
+
+	for next, ok := schedule.Next(now); ok && !next.After(until); next, ok = schedule.Next(next) {
+		last, found = next, true
+	}
+
+	return last, found
+}
+
+func sameTimes(a, b []time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+
+	return true
+}
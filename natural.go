@@ -0,0 +1,990 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var (
+	naturalTimeRegexp    = regexp.MustCompile(`^(\d{1,2})(?:[:.](\d{2}))?(am|pm)?$`)
+	naturalOrdinalRegexp = regexp.MustCompile(`^(\d{1,2})(?:st|nd|rd|th)$`)
+
+	naturalOrdinals = map[string]int{"first": 1, "second": 2, "third": 3, "fourth": 4, "fifth": 5, "last": -1}
+	naturalUnits    = map[string]string{
+		"second": "second", "seconds": "second", "sec": "second", "secs": "second",
+		"minute": "minute", "minutes": "minute", "min": "minute", "mins": "minute",
+		"hour": "hour", "hours": "hour", "hr": "hour", "hrs": "hour",
+		"day": "day", "days": "day", "week": "week", "weeks": "week",
+		"month": "month", "months": "month", "quarter": "quarter", "quarters": "quarter",
+		"year": "year", "years": "year",
+	}
+	naturalAdverbs = map[string]string{
+		"hourly": "hour", "daily": "day", "weekly": "week", "monthly": "month",
+		"quarterly": "quarter", "yearly": "year", "annually": "year",
+	}
+	naturalUnitSeconds = map[string]int{"second": 1, "minute": 60, "hour": 3600}
+)
+
+type naturalWord struct {
+	text string
+	at   int
+	size int
+}
+
+// naturalRule is what a phrase asks for, before it is written as a rule.
+type naturalRule struct {
+	period string
+	// every is the number of periods between runs, step the seconds between
+	// runs for periods of an hour or less
+	every int
+	step  int
+	// times and the window are minutes of the day
+	times            []int
+	from, to         int
+	window           bool
+	weekdays         []time.Weekday
+	nth              int
+	monthDays        []string
+	months           []time.Month
+	stepAt, windowAt naturalWord
+	// start is the midnight runs every few periods are counted from
+	start time.Time
+}
+
+type naturalParser struct {
+	phrase string
+	words  []naturalWord
+	pos    int
+	rule   naturalRule
+}
+
+// isNatural tells whether the rule starts with a word, which no other rule
+// syntax does.
+func isNatural(rule string) bool {
+	fields := strings.Fields(rule)
+
+	if len(fields) == 0 {
+		return false
+	}
+
+	for _, r := range fields[0] {
+		if !unicode.IsLetter(r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// NaturalFromString compiles an English phrase into a cron expression, an
+// RRULE or a union of those, e.g.
+//
+//	every weekday at 9am                       0 9 * * MON-FRI
+//	first Monday of each quarter at noon       0 12 * JAN,APR,JUL,OCT MON#1
+//	every 90 minutes between 8:00 and 18:00    0 8,11,14,17 * * * UNION 30 9,12,15 * * *
+//	every 2 weeks on Tuesday and Thursday      DTSTART:20170101T000000Z
+//	                                           RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,TH;BYHOUR=0;BYMINUTE=0
+//
+// A phrase names a period, a list of weekdays or days of the month, followed
+// in any order by "at" times, "on" days, "in" months and, for periods of an
+// hour or less, a "between" window. Times without am or pm are on a 24 hour
+// clock and runs default to midnight. Runs every few days, weeks or months
+// are counted from the day of created, in its location.
+func NaturalFromString(phrase string, created time.Time) (string, error) {
+	p := &naturalParser{phrase: phrase, words: naturalWords(phrase)}
+	p.rule.every = 1
+	p.rule.start = time.Date(created.Year(), created.Month(), created.Day(), 0, 0, 0, 0, created.Location())
+
+	if err := p.parse(); err != nil {
+		return "", err
+	}
+
+	return p.rule.compile()
+}
+
+// naturalWords splits the phrase at white space and commas, lower case.
+func naturalWords(phrase string) []naturalWord {
+	var words []naturalWord
+
+	start := -1
+
+	for i, r := range phrase + " " {
+		if unicode.IsSpace(r) || r == ',' {
+			if start >= 0 {
+				words = append(words, naturalWord{strings.ToLower(phrase[start:i]), start, i - start})
+				start = -1
+			}
+		} else if start < 0 {
+			start = i
+		}
+	}
+
+	return words
+}
+
+func (p *naturalParser) peek() naturalWord {
+	if p.pos < len(p.words) {
+		return p.words[p.pos]
+	}
+
+	return naturalWord{at: len(p.phrase)}
+}
+
+func (p *naturalParser) next() naturalWord {
+	word := p.peek()
+	p.pos++
+
+	return word
+}
+
+func (p *naturalParser) accept(texts ...string) bool {
+	for _, text := range texts {
+		if p.peek().text == text {
+			p.pos++
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *naturalParser) fail(word naturalWord, reason ParseReason) error {
+	if word.text == "" {
+		return newParseError(len(p.phrase), "", ReasonIncompletePhrase)
+	}
+
+	return newParseError(word.at, p.phrase[word.at:word.at+word.size], reason)
+}
+
+func (p *naturalParser) parse() error {
+	p.accept("every", "each", "on")
+	p.accept("the")
+
+	word := p.peek()
+
+	if p.ordinalFollows() {
+		if err := p.days(); err != nil {
+			return err
+		}
+	} else if unit, ok := naturalAdverbs[word.text]; ok {
+		p.pos++
+
+		if err := p.period(unit, word); err != nil {
+			return err
+		}
+	} else if unit, ok := naturalUnits[word.text]; ok {
+		p.pos++
+
+		if err := p.period(unit, word); err != nil {
+			return err
+		}
+	} else if word.text == "other" || isNaturalNumber(word.text) {
+		p.pos++
+		p.rule.every = 2
+
+		if word.text != "other" {
+			p.rule.every, _ = strconv.Atoi(word.text)
+		}
+
+		unit := p.next()
+
+		if _, ok := naturalUnits[unit.text]; !ok || p.rule.every < 1 {
+			return p.fail(unit, ReasonUnknownWord)
+		}
+
+		if err := p.period(naturalUnits[unit.text], word); err != nil {
+			return err
+		}
+	} else if err := p.days(); err != nil {
+		return err
+	}
+
+	return p.clauses()
+}
+
+// ordinalFollows tells whether the next word starts "second Tuesday" or
+// "second day" rather than a number of seconds.
+func (p *naturalParser) ordinalFollows() bool {
+	if p.peek().text != "second" || p.pos+1 >= len(p.words) {
+		return false
+	}
+
+	kind := p.words[p.pos+1].text
+
+	return kind == "day" || kind == "weekday" || isNaturalWeekday(kind)
+}
+
+// period sets the unit of the phrase, word being where it starts.
+func (p *naturalParser) period(unit string, word naturalWord) error {
+	p.rule.period = unit
+
+	if seconds, ok := naturalUnitSeconds[unit]; ok {
+		p.rule.step, p.rule.stepAt = p.rule.every*seconds, word
+	} else if p.rule.every > 1 && (unit == "quarter" || unit == "year") {
+		return p.fail(word, ReasonOutOfRange)
+	}
+
+	return nil
+}
+
+func (p *naturalParser) clauses() error {
+	for p.pos < len(p.words) {
+		word := p.next()
+
+		switch word.text {
+		case "at":
+			if err := p.times(); err != nil {
+				return err
+			}
+		case "on":
+			if err := p.days(); err != nil {
+				return err
+			}
+		case "in":
+			if err := p.months(); err != nil {
+				return err
+			}
+		case "between", "from":
+			if err := p.window(word); err != nil {
+				return err
+			}
+		default:
+			if !isNaturalTime(word.text) {
+				return p.fail(word, ReasonUnknownWord)
+			}
+
+			p.pos--
+
+			if err := p.times(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if p.rule.window && p.rule.step == 0 {
+		return p.fail(p.rule.windowAt, ReasonUnknownWord)
+	}
+
+	if p.rule.step > 0 && len(p.rule.times) > 0 {
+		return p.fail(p.rule.stepAt, ReasonUnknownWord)
+	}
+
+	return nil
+}
+
+// days reads weekdays, days of the month or a date.
+func (p *naturalParser) days() error {
+	p.accept("the")
+
+	word := p.peek()
+
+	switch {
+	case word.text == "weekday" || word.text == "weekdays":
+		p.pos++
+		return p.addWeekdays(word, time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday)
+	case word.text == "weekend" || word.text == "weekends":
+		p.pos++
+		return p.addWeekdays(word, time.Saturday, time.Sunday)
+	case isNaturalWeekday(word.text):
+		for {
+			weekday, ok := naturalWeekday(p.peek().text)
+
+			if !ok {
+				return p.fail(p.peek(), ReasonUnknownWord)
+			}
+
+			if err := p.addWeekdays(p.next(), weekday); err != nil {
+				return err
+			}
+
+			if !p.accept("and") && !isNaturalWeekday(p.peek().text) {
+				return nil
+			}
+		}
+	case isNaturalMonth(word.text):
+		p.pos++
+		month, _ := naturalMonth(word.text)
+		day := p.next()
+
+		if err := p.addMonthDay(day, naturalDay(day.text)); err != nil {
+			return err
+		}
+
+		p.rule.months = append(p.rule.months, month)
+		p.rule.period = "year"
+
+		return nil
+	}
+
+	return p.ordinal()
+}
+
+// ordinal reads "first Monday", "last weekday", "15th" or "1st and 15th",
+// optionally followed by "of" a period or a month.
+func (p *naturalParser) ordinal() error {
+	word := p.next()
+	n := naturalDay(word.text)
+
+	if n == 0 {
+		return p.fail(word, ReasonUnknownWord)
+	}
+
+	kind := p.peek()
+
+	if weekday, ok := naturalWeekday(kind.text); ok && (n <= 5 || n == -1) {
+		p.pos++
+
+		if err := p.addWeekdays(kind, weekday); err != nil {
+			return err
+		}
+
+		p.rule.nth = n
+	} else if kind.text == "weekday" && (n == 1 || n == -1) {
+		p.pos++
+
+		if err := p.addMonthDay(kind, n); err != nil {
+			return err
+		}
+
+		p.rule.monthDays[len(p.rule.monthDays)-1] += "W"
+	} else {
+		p.accept("day")
+
+		if err := p.addMonthDay(word, n); err != nil {
+			return err
+		}
+
+		for p.accept("and") || naturalDay(p.peek().text) != 0 {
+			day := p.next()
+
+			if err := p.addMonthDay(day, naturalDay(day.text)); err != nil {
+				return err
+			}
+
+			p.accept("day")
+		}
+
+		if month, ok := naturalMonth(p.peek().text); ok {
+			p.pos++
+			p.rule.months, p.rule.period = append(p.rule.months, month), "year"
+		}
+	}
+
+	if p.rule.period == "" {
+		p.rule.period = "month"
+	}
+
+	if !p.accept("of") {
+		return nil
+	}
+
+	p.accept("each", "every", "the")
+	of := p.next()
+	first, last := n > 0, n == -1
+
+	switch {
+	case naturalUnits[of.text] == "month":
+	case naturalUnits[of.text] == "quarter" && first:
+		p.rule.months = append(p.rule.months, time.January, time.April, time.July, time.October)
+	case naturalUnits[of.text] == "quarter" && last:
+		p.rule.months = append(p.rule.months, time.March, time.June, time.September, time.December)
+	case naturalUnits[of.text] == "year" && first:
+		p.rule.months = append(p.rule.months, time.January)
+	case naturalUnits[of.text] == "year" && last:
+		p.rule.months = append(p.rule.months, time.December)
+	case isNaturalMonth(of.text):
+		p.pos--
+		return p.months()
+	default:
+		return p.fail(of, ReasonUnknownWord)
+	}
+
+	return nil
+}
+
+func (p *naturalParser) addWeekdays(word naturalWord, weekdays ...time.Weekday) error {
+	if len(p.rule.monthDays) > 0 || p.rule.nth != 0 {
+		return p.fail(word, ReasonUnknownWord)
+	}
+
+	p.rule.weekdays = append(p.rule.weekdays, weekdays...)
+
+	return nil
+}
+
+func (p *naturalParser) addMonthDay(word naturalWord, day int) error {
+	if day == 0 {
+		return p.fail(word, ReasonUnknownWord)
+	}
+
+	if day > 31 {
+		return p.fail(word, ReasonOutOfRange)
+	}
+
+	if len(p.rule.weekdays) > 0 {
+		return p.fail(word, ReasonUnknownWord)
+	}
+
+	if day == -1 {
+		p.rule.monthDays = append(p.rule.monthDays, "L")
+	} else {
+		p.rule.monthDays = append(p.rule.monthDays, strconv.Itoa(day))
+	}
+
+	return nil
+}
+
+func (p *naturalParser) months() error {
+	for {
+		word := p.next()
+		month, ok := naturalMonth(word.text)
+
+		if !ok {
+			return p.fail(word, ReasonUnknownWord)
+		}
+
+		p.rule.months = append(p.rule.months, month)
+
+		if !p.accept("and") && !isNaturalMonth(p.peek().text) {
+			return nil
+		}
+	}
+}
+
+func (p *naturalParser) times() error {
+	for {
+		word := p.next()
+		minutes, ok := p.time(word)
+
+		if !ok {
+			return p.fail(word, ReasonUnknownWord)
+		}
+
+		if minutes < 0 {
+			return p.fail(word, ReasonOutOfRange)
+		}
+
+		p.rule.times = append(p.rule.times, minutes)
+
+		if !p.accept("and") && !isNaturalTime(p.peek().text) {
+			return nil
+		}
+	}
+}
+
+func (p *naturalParser) window(word naturalWord) error {
+	start := p.next()
+	from, ok := p.time(start)
+
+	if !ok || from < 0 {
+		return p.fail(start, ReasonUnknownWord)
+	}
+
+	if !p.accept("and", "to", "until") {
+		return p.fail(p.peek(), ReasonUnknownWord)
+	}
+
+	end := p.next()
+	to, ok := p.time(end)
+
+	if !ok || to < 0 {
+		return p.fail(end, ReasonUnknownWord)
+	}
+
+	if to < from {
+		return p.fail(end, ReasonOutOfRange)
+	}
+
+	p.rule.from, p.rule.to, p.rule.window, p.rule.windowAt = from, to, true, word
+
+	return nil
+}
+
+// time reads a time of the day such as 9am, 9:30 pm, 17:00, noon or midnight
+// as minutes of the day, consuming a separate am or pm. It returns -1 for a
+// time out of range and false for a word that is no time.
+func (p *naturalParser) time(word naturalWord) (int, bool) {
+	switch word.text {
+	case "noon", "midday":
+		return 12 * 60, true
+	case "midnight":
+		return 0, true
+	}
+
+	match := naturalTimeRegexp.FindStringSubmatch(word.text)
+
+	if match == nil {
+		return 0, false
+	}
+
+	hour, _ := strconv.Atoi(match[1])
+	minute, _ := strconv.Atoi("0" + match[2])
+	suffix := match[3]
+
+	if next := p.peek(); suffix == "" && (next.text == "am" || next.text == "pm") {
+		suffix = next.text
+		p.pos++
+	}
+
+	if suffix != "" {
+		if hour < 1 || hour > 12 {
+			return -1, true
+		}
+
+		hour %= 12
+
+		if suffix == "pm" {
+			hour += 12
+		}
+	}
+
+	if hour > 23 || minute > 59 {
+		return -1, true
+	}
+
+	return hour*60 + minute, true
+}
+
+// compile writes the rule as cron expressions, or as RRULEs when runs are
+// more than a day, week or month apart.
+func (r naturalRule) compile() (string, error) {
+	switch r.period {
+	case "week":
+		if len(r.weekdays) == 0 {
+			r.weekdays = []time.Weekday{time.Monday}
+		}
+	case "quarter":
+		if len(r.months) == 0 {
+			r.months = []time.Month{time.January, time.April, time.July, time.October}
+		}
+
+		fallthrough
+	case "month", "year":
+		if len(r.monthDays) == 0 && len(r.weekdays) == 0 {
+			r.monthDays = []string{"1"}
+		}
+
+		if r.period == "year" && len(r.months) == 0 {
+			r.months = []time.Month{time.January}
+		}
+	}
+
+	if r.step > 0 {
+		return r.compileStep()
+	}
+
+	if len(r.times) == 0 {
+		r.times = []int{0}
+	}
+
+	if r.every > 1 {
+		return r.compileRRule()
+	}
+
+	return r.compileCron(r.times), nil
+}
+
+// compileStep writes runs every so many seconds, minutes or hours, which
+// restart at the beginning of the window, or of the day, on every day. Steps
+// that are not whole minutes run on seconds within the minutes.
+func (r naturalRule) compileStep() (string, error) {
+	restricted := r.window || len(r.weekdays) > 0 || len(r.monthDays) > 0 || len(r.months) > 0
+	day := 24 * 60 * 60
+	wholeMinutes := r.step%60 == 0
+	withinMinute := r.step < 60 && 60%r.step == 0
+
+	if !restricted && (day%r.step != 0 || !wholeMinutes && !withinMinute) {
+		every := (time.Duration(r.step) * time.Second).String()
+
+		// 5h0m0s is written 5h, 1m30s stays as it is
+		for _, zero := range []string{"m0s", "h0m"} {
+			if strings.HasSuffix(every, zero) {
+				every = every[:len(every)-2]
+			}
+		}
+
+		return "@every " + every, nil
+	}
+
+	if withinMinute && !r.window {
+		dom, month, dow := r.cronDays()
+
+		return fmt.Sprintf("*/%d * * %s %s %s", r.step, dom, month, dow), nil
+	}
+
+	if r.step > day {
+		return "", newParseError(r.stepAt.at, r.stepAt.text, ReasonOutOfRange)
+	}
+
+	from, to := 0, 24*60-1
+
+	if r.window {
+		from, to = r.from, r.to
+	}
+
+	if !wholeMinutes {
+		end := to*60 + 59
+
+		if r.window {
+			// a window ends on its last minute
+			end = to * 60
+		}
+
+		return r.compileSeconds(from*60, end), nil
+	}
+
+	var times []int
+
+	for t := from; t <= to; t += r.step / 60 {
+		times = append(times, t)
+	}
+
+	return r.compileCron(times), nil
+}
+
+// compileSeconds writes runs every step seconds from and up to the given
+// seconds of the day as 6 field cron expressions, grouped by the second past
+// the minute and then by the minutes past the hour.
+func (r naturalRule) compileSeconds(from, to int) string {
+	minutes := map[[2]int][]int{}
+
+	for t := from; t <= to; t += r.step {
+		key := [2]int{t % 60, t / 3600}
+		minutes[key] = append(minutes[key], t/60%60)
+	}
+
+	dom, month, dow := r.cronDays()
+
+	var (
+		rules []string
+		keys  []string
+	)
+
+	hours := map[string][]int{}
+
+	for second := 0; second < 60; second++ {
+		for hour := 0; hour < 24; hour++ {
+			list, ok := minutes[[2]int{second, hour}]
+
+			if !ok {
+				continue
+			}
+
+			key := strconv.Itoa(second) + " " + cronList(list, 0, 59)
+
+			if _, ok := hours[key]; !ok {
+				keys = append(keys, key)
+			}
+
+			hours[key] = append(hours[key], hour)
+		}
+	}
+
+	for _, key := range keys {
+		rules = append(rules, key+" "+cronList(hours[key], 0, 23)+" "+dom+" "+month+" "+dow)
+	}
+
+	return strings.Join(rules, "\nUNION ")
+}
+
+// naturalTimes groups times of the day by the minutes past the hour, so hours
+// with the same minutes share an expression.
+type naturalTimes struct {
+	minutes []int
+	hours   []int
+}
+
+func groupNaturalTimes(times []int) []naturalTimes {
+	times = uniqueInts(times)
+	byHour := map[int][]int{}
+
+	for _, t := range times {
+		byHour[t/60] = append(byHour[t/60], t%60)
+	}
+
+	var groups []naturalTimes
+
+	index := map[string]int{}
+
+	for hour := 0; hour < 24; hour++ {
+		minutes, ok := byHour[hour]
+
+		if !ok {
+			continue
+		}
+
+		key := fmt.Sprint(minutes)
+
+		if i, ok := index[key]; ok {
+			groups[i].hours = append(groups[i].hours, hour)
+		} else {
+			index[key] = len(groups)
+			groups = append(groups, naturalTimes{minutes, []int{hour}})
+		}
+	}
+
+	return groups
+}
+
+func (r naturalRule) compileCron(times []int) string {
+	dom, month, dow := r.cronDays()
+
+	var rules []string
+
+	for _, group := range groupNaturalTimes(times) {
+		rules = append(rules, cronList(group.minutes, 0, 59)+" "+cronList(group.hours, 0, 23)+" "+dom+" "+month+" "+dow)
+	}
+
+	return strings.Join(rules, "\nUNION ")
+}
+
+func (r naturalRule) cronDays() (string, string, string) {
+	dom, month, dow := "*", "*", "*"
+
+	if len(r.monthDays) > 0 {
+		dom = strings.Join(r.monthDays, ",")
+	}
+
+	if len(r.months) > 0 {
+		var names []string
+
+		for _, m := range uniqueInts(monthInts(r.months)) {
+			names = append(names, strings.ToUpper(time.Month(m).String()[:3]))
+		}
+
+		month = strings.Join(names, ",")
+	}
+
+	weekdays := uniqueInts(weekdayInts(r.weekdays))
+
+	switch {
+	case len(weekdays) == 1 && r.nth > 0:
+		dow = weekdayName(weekdays[0]) + "#" + strconv.Itoa(r.nth)
+	case len(weekdays) == 1 && r.nth < 0:
+		dow = weekdayName(weekdays[0]) + "L"
+	case len(weekdays) > 0 && len(weekdays) < 7:
+		var items []string
+
+		for _, run := range intRuns(weekdays) {
+			if run[1]-run[0] >= 2 {
+				items = append(items, weekdayName(run[0])+"-"+weekdayName(run[1]))
+			} else {
+				for w := run[0]; w <= run[1]; w++ {
+					items = append(items, weekdayName(w))
+				}
+			}
+		}
+
+		dow = strings.Join(items, ",")
+	}
+
+	return dom, month, dow
+}
+
+func (r naturalRule) compileRRule() (string, error) {
+	freq := map[string]string{"day": "DAILY", "week": "WEEKLY", "month": "MONTHLY"}[r.period]
+	parts := []string{"FREQ=" + freq, "INTERVAL=" + strconv.Itoa(r.every)}
+
+	if len(r.months) > 0 {
+		parts = append(parts, "BYMONTH="+joinInts(uniqueInts(monthInts(r.months))))
+	}
+
+	if len(r.monthDays) > 0 {
+		days := make([]string, len(r.monthDays))
+
+		for i, day := range r.monthDays {
+			if strings.HasSuffix(day, "W") {
+				return "", newParseError(r.stepAt.at, "", ReasonUnknownWord)
+			}
+
+			days[i] = strings.Replace(day, "L", "-1", 1)
+		}
+
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+
+	if len(r.weekdays) > 0 {
+		var days []string
+
+		for _, w := range uniqueInts(weekdayInts(r.weekdays)) {
+			day := strings.ToUpper(time.Weekday(w).String()[:2])
+
+			if r.nth != 0 {
+				day = strconv.Itoa(r.nth) + day
+			}
+
+			days = append(days, day)
+		}
+
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+
+	var rules []string
+
+	start := rruleStartLine(r.start, r.start.Location())
+
+	for _, group := range groupNaturalTimes(r.times) {
+		rules = append(rules, start+"\nRRULE:"+strings.Join(append(parts, "BYHOUR="+joinInts(group.hours), "BYMINUTE="+joinInts(group.minutes)), ";"))
+	}
+
+	return strings.Join(rules, "\nUNION "), nil
+}
+
+// cronList writes sorted values as *, a */n step or a list of values and
+// ranges.
+func cronList(values []int, min, max int) string {
+	if len(values) == max-min+1 {
+		return "*"
+	}
+
+	if len(values) > 2 && values[0] == min {
+		step := values[1] - values[0]
+		progression := values[len(values)-1]+step > max
+
+		for i := 1; i < len(values); i++ {
+			progression = progression && values[i]-values[i-1] == step
+		}
+
+		if progression {
+			return "*/" + strconv.Itoa(step)
+		}
+	}
+
+	var items []string
+
+	for _, run := range intRuns(values) {
+		if run[1]-run[0] >= 2 {
+			items = append(items, strconv.Itoa(run[0])+"-"+strconv.Itoa(run[1]))
+		} else {
+			for v := run[0]; v <= run[1]; v++ {
+				items = append(items, strconv.Itoa(v))
+			}
+		}
+	}
+
+	return strings.Join(items, ",")
+}
+
+// intRuns splits sorted values into runs of consecutive ones.
+func intRuns(values []int) [][2]int {
+	var runs [][2]int
+
+	for _, v := range values {
+		if len(runs) > 0 && runs[len(runs)-1][1] == v-1 {
+			runs[len(runs)-1][1] = v
+		} else {
+			runs = append(runs, [2]int{v, v})
+		}
+	}
+
+	return runs
+}
+
+func uniqueInts(values []int) []int {
+	sorted := append([]int(nil), values...)
+	sort.Ints(sorted)
+
+	var unique []int
+
+	for i, v := range sorted {
+		if i == 0 || v != sorted[i-1] {
+			unique = append(unique, v)
+		}
+	}
+
+	return unique
+}
+
+func monthInts(months []time.Month) []int {
+	values := make([]int, len(months))
+
+	for i, m := range months {
+		values[i] = int(m)
+	}
+
+	return values
+}
+
+func weekdayInts(weekdays []time.Weekday) []int {
+	values := make([]int, len(weekdays))
+
+	for i, w := range weekdays {
+		values[i] = int(w)
+	}
+
+	return values
+}
+
+func weekdayName(w int) string {
+	return strings.ToUpper(time.Weekday(w).String()[:3])
+}
+
+func isNaturalTime(word string) bool {
+	return word == "noon" || word == "midday" || word == "midnight" || naturalTimeRegexp.MatchString(word)
+}
+
+func isNaturalNumber(word string) bool {
+	_, err := strconv.Atoi(word)
+
+	return err == nil
+}
+
+// naturalDay reads an ordinal such as first, 2nd or last as a day number, -1
+// being the last, and 0 for a word that is no ordinal.
+func naturalDay(word string) int {
+	if n, ok := naturalOrdinals[word]; ok {
+		return n
+	}
+
+	if match := naturalOrdinalRegexp.FindStringSubmatch(word); match != nil {
+		n, _ := strconv.Atoi(match[1])
+		return n
+	}
+
+	if n, err := strconv.Atoi(word); err == nil && n > 0 {
+		return n
+	}
+
+	return 0
+}
+
+// naturalWeekday reads a weekday name, abbreviated or plural.
+func naturalWeekday(word string) (time.Weekday, bool) {
+	word = strings.TrimSuffix(word, "s")
+
+	for w := time.Sunday; w <= time.Saturday; w++ {
+		if len(word) >= 3 && strings.HasPrefix(strings.ToLower(w.String()), word) {
+			return w, true
+		}
+	}
+
+	return time.Sunday, false
+}
+
+func isNaturalWeekday(word string) bool {
+	_, ok := naturalWeekday(word)
+
+	return ok
+}
+
+// naturalMonth reads a month name, possibly abbreviated.
+func naturalMonth(word string) (time.Month, bool) {
+	for m := time.January; m <= time.December; m++ {
+		if len(word) >= 3 && strings.HasPrefix(strings.ToLower(m.String()), word) {
+			return m, true
+		}
+	}
+
+	return time.January, false
+}
+
+func isNaturalMonth(word string) bool {
+	_, ok := naturalMonth(word)
+
+	return ok
+}
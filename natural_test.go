@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNaturalFromString(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		Phrase   string
+		Expected string
+	}{
+		{"every weekday at 9am", "0 9 * * MON-FRI"},
+		{"First Monday of each quarter at noon", "0 12 * JAN,APR,JUL,OCT MON#1"},
+		{"every 90 minutes between 8:00 and 18:00", "0 8,11,14,17 * * *\nUNION 30 9,12,15 * * *"},
+		{"every 15 minutes between 9am and 5pm on weekdays", "*/15 9-16 * * MON-FRI\nUNION 0 17 * * MON-FRI"},
+		{"every 2 weeks on Tuesday and Thursday at 6:30 pm", "DTSTART:20170101T000000Z\nRRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,TH;BYHOUR=18;BYMINUTE=30"},
+		{"every other month on the 1st and 15th", "DTSTART:20170101T000000Z\nRRULE:FREQ=MONTHLY;INTERVAL=2;BYMONTHDAY=1,15;BYHOUR=0;BYMINUTE=0"},
+		{"daily at 9:00 and 17:30", "0 9 * * *\nUNION 30 17 * * *"},
+		{"every day at 9am, noon and 5pm", "0 9,12,17 * * *"},
+		{"hourly", "0 * * * *"},
+		{"every minute", "* * * * *"},
+		{"every 2 hours", "0 */2 * * *"},
+		{"every 5 hours", "@every 5h"},
+		{"every 7 minutes", "@every 7m"},
+		{"every 30 seconds", "*/30 * * * * *"},
+		{"every 45 seconds", "@every 45s"},
+		{"every 90 seconds", "@every 1m30s"},
+		{"every 150 seconds", "@every 2m30s"},
+		{"every 100 seconds between 9:00 and 9:10", "0 0,5,10 9 * * *\nUNION 20 3,8 9 * * *\nUNION 40 1,6 9 * * *"},
+		{"every 90 seconds on mondays", "0 */3 * * * MON\nUNION 30 1,4,7,10,13,16,19,22,25,28,31,34,37,40,43,46,49,52,55,58 * * * MON"},
+		{"every hour between 9 and 17 on mondays", "0 9-17 * * MON"},
+		{"mon, wed and fri at 7", "0 7 * * MON,WED,FRI"},
+		{"weekends at midnight", "0 0 * * SUN,SAT"},
+		{"every week", "0 0 * * MON"},
+		{"weekly on friday at 16:00", "0 16 * * FRI"},
+		{"every month", "0 0 1 * *"},
+		{"monthly on the last day at 23:59", "59 23 L * *"},
+		{"last Friday of the month at 5pm", "0 17 * * FRIL"},
+		{"second Tuesday of every month at 10", "0 10 * * TUE#2"},
+		{"the first weekday of the month at 8am", "0 8 1W * *"},
+		{"last weekday of each month", "0 0 LW * *"},
+		{"last day of each quarter at 18:00", "0 18 L MAR,JUN,SEP,DEC *"},
+		{"first day of the year", "0 0 1 JAN *"},
+		{"every quarter", "0 0 1 JAN,APR,JUL,OCT *"},
+		{"every year on March 15 at 9am", "0 9 15 MAR *"},
+		{"on the 1st and 15th at 12:00", "0 12 1,15 * *"},
+		{"every day at 9am in january and july", "0 9 * JAN,JUL *"},
+		{"every 3 days at 6am", "DTSTART:20170101T000000Z\nRRULE:FREQ=DAILY;INTERVAL=3;BYHOUR=6;BYMINUTE=0"},
+	}
+
+	for index, test := range tests {
+		result, err := NaturalFromString(test.Phrase, now)
+
+		if err != nil {
+			t.Errorf("Test %d expected %q but got error %s", index+1, test.Expected, err)
+			continue
+		}
+
+		if result != test.Expected {
+			t.Errorf("Test %d expected %q but got %q", index+1, test.Expected, result)
+		}
+
+		if _, err := ScheduleFromString(result, ScheduleOptions{}); err != nil {
+			t.Errorf("Test %d expected %q to parse but got error %s", index+1, result, err)
+		}
+	}
+}
+
+func TestNaturalParseError(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		Phrase string
+		Offset int
+		Token  string
+		Reason ParseReason
+	}{
+		{"every fortnight", 6, "fortnight", ReasonUnknownWord},
+		{"every weekday at", 16, "", ReasonIncompletePhrase},
+		{"every weekday at 25:00", 17, "25:00", ReasonOutOfRange},
+		{"every weekday at 13pm", 17, "13pm", ReasonOutOfRange},
+		{"every day between 9 and 17", 10, "between", ReasonUnknownWord},
+		{"every hour between 17 and 9", 26, "9", ReasonOutOfRange},
+		{"every 15 minutes at 9am", 6, "15", ReasonUnknownWord},
+		{"every 2 years", 6, "2", ReasonOutOfRange},
+		{"sixth Monday of the month", 0, "sixth", ReasonUnknownWord},
+		{"on the 15th on mondays", 15, "mondays", ReasonUnknownWord},
+		{"every month on the 32nd", 19, "32nd", ReasonOutOfRange},
+	}
+
+	for index, test := range tests {
+		_, err := NaturalFromString(test.Phrase, now)
+		var parseErr *ParseError
+
+		if !errors.As(err, &parseErr) {
+			t.Errorf("Test %d expected a parse error but got %v", index+1, err)
+			continue
+		}
+
+		if parseErr.Offset != test.Offset || parseErr.Token != test.Token || parseErr.Reason != test.Reason {
+			t.Errorf("Test %d expected %s %q at %d but got %s %q at %d", index+1, test.Reason, test.Token, test.Offset, parseErr.Reason, parseErr.Token, parseErr.Offset)
+		}
+	}
+}
+
+func TestNaturalSchedule(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		Rule     string
+		Expected string
+		Next     string
+	}{
+		{"every weekday at 9am", "0 9 * * MON-FRI", "2017-01-02T09:00:00Z"},
+		{"First Monday of each quarter at noon", "0 12 * JAN,APR,JUL,OCT MON#1", "2017-01-02T12:00:00Z"},
+		{"every day at 9am EXCEPT weekends at 9am", "0 9 * * *\nEXCEPT 0 9 * * SUN,SAT", "2017-01-02T09:00:00Z"},
+	}
+
+	for index, test := range tests {
+		schedule, err := Task{Rule: test.Rule}.Schedule()
+
+		if err != nil {
+			t.Fatalf("Test %d expected correct rule but got error %s", index+1, err)
+		}
+
+		if schedule.String() != test.Expected {
+			t.Errorf("Test %d expected %q but got %q", index+1, test.Expected, schedule.String())
+		}
+
+		if next := nextOccurrences(schedule, now, 1); len(next) != 1 || next[0] != test.Next {
+			t.Errorf("Test %d expected %s but got %v", index+1, test.Next, next)
+		}
+	}
+
+	loc, err := time.LoadLocation("Europe/Berlin")
+
+	if err != nil {
+		t.Skipf("No time zone database: %s", err)
+	}
+
+	// every other week counts from the week the rule is created in
+	created := time.Date(2017, 1, 18, 15, 0, 0, 0, loc)
+	schedule, err := ScheduleFromString("every 2 weeks on monday at 9am", ScheduleOptions{Location: loc, Created: created})
+	expected := "DTSTART;TZID=Europe/Berlin:20170118T000000\nRRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=MO;BYHOUR=9;BYMINUTE=0"
+
+	if err != nil || schedule.String() != expected {
+		t.Fatalf("Expected %q but got %v, %v", expected, schedule, err)
+	}
+
+	if next := nextOccurrences(schedule, created, 2); strings.Join(next, " ") != "2017-01-30T08:00:00Z 2017-02-13T08:00:00Z" {
+		t.Errorf("Expected 30 January and 13 February but got %v", next)
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// OffsetSchedule fires a fixed offset before or after every occurrence of
+// another schedule, e.g. three hours before each run of a daily rule. The
+// offset is added with NextDate in the location of the base schedule. With
+// BusinessDays the days of the offset count the working days of Calendar
+// only, Monday to Friday without one, so the offset may have no years, months
+// or weeks.
+type OffsetSchedule struct {
+	Base         Schedule
+	Offset       RecurrenceInterval
+	BusinessDays bool
+	Calendar     *Calendar
+}
+
+// isOffset tells whether the rule starts with an OFFSET line.
+func isOffset(rule string) bool {
+	upper := strings.ToUpper(strings.TrimSpace(rule))
+
+	return strings.HasPrefix(upper, "OFFSET:") || strings.HasPrefix(upper, "OFFSET;")
+}
+
+// OffsetFromString parses an OFFSET line followed by the base rule in any
+// supported syntax, separated by white space:
+//
+//	OFFSET:-PT3H R/2017-01-01T09:00:00/P1D
+//	OFFSET;DAYS=BUSINESS:-P2D 0 0 L * *
+//
+// The offset is a signed ISO 8601 duration and the base rule must have a start
+// or an end.
+func OffsetFromString(rule string, options ScheduleOptions) (OffsetSchedule, error) {
+	at := len(rule) - len(strings.TrimLeftFunc(rule, unicode.IsSpace))
+	end := strings.IndexFunc(rule[at:], unicode.IsSpace)
+
+	if end < 0 {
+		return OffsetSchedule{}, newParseError(len(rule), "", ReasonComponentCount)
+	}
+
+	line := rule[at : at+end]
+	colon := strings.Index(line, ":")
+
+	if colon < 0 {
+		return OffsetSchedule{}, newParseError(at, line, ReasonMissingOffset)
+	}
+
+	params := strings.Split(line[:colon], ";")
+	o := OffsetSchedule{Calendar: options.Calendar}
+
+	if strings.ToUpper(params[0]) != "OFFSET" {
+		return OffsetSchedule{}, newParseError(at, params[0], ReasonUnknownWord)
+	}
+
+	paramAt := at + len(params[0]) + 1
+
+	for _, param := range params[1:] {
+		if strings.ToUpper(param) != "DAYS=BUSINESS" {
+			return OffsetSchedule{}, newParseError(paramAt, param, ReasonUnknownParameter)
+		}
+
+		o.BusinessDays = true
+		paramAt += len(param) + 1
+	}
+
+	var err error
+
+	if o.Offset, err = (RecurrenceParser{Lenient: options.LenientDurations}).Duration(line[colon+1:]); err != nil {
+		return OffsetSchedule{}, err.(*ParseError).shifted(at + colon + 1)
+	}
+
+	if o.BusinessDays && (o.Offset.Years != 0 || o.Offset.Months != 0 || o.Offset.Weeks != 0) {
+		return OffsetSchedule{}, newParseError(at+colon+1, line[colon+1:], ReasonBusinessOffset)
+	}
+
+	baseAt := at + end + len(rule[at+end:]) - len(strings.TrimLeftFunc(rule[at+end:], unicode.IsSpace))
+
+	if o.Base, err = scheduleFromString(rule[baseAt:], options); err != nil {
+		var parseErr *ParseError
+
+		if errors.As(err, &parseErr) {
+			return OffsetSchedule{}, parseErr.shifted(baseAt)
+		}
+
+		return OffsetSchedule{}, err
+	}
+
+	if recurrence, ok := o.Base.(Recurrence); ok && recurrence.Start == nil && recurrence.End == nil {
+		return OffsetSchedule{}, newParseError(baseAt, rule[baseAt:], ReasonUnanchoredOffset)
+	}
+
+	return o, nil
+}
+
+func (o OffsetSchedule) location() *time.Location {
+	if base, ok := o.Base.(interface{ location() *time.Location }); ok {
+		return base.location()
+	}
+
+	return time.UTC
+}
+
+// Next returns the first shifted occurrence strictly after the given time, or
+// false when the base schedule runs out. Base occurrences shifted onto the
+// same time fire once.
+func (o OffsetSchedule) Next(after time.Time) (time.Time, bool) {
+	from := after.Add(-o.reach())
+
+	for {
+		base, ok := o.Base.Next(from)
+
+		if !ok {
+			return time.Time{}, false
+		}
+
+		if next := o.apply(base); next.After(after) {
+			return next, true
+		}
+
+		from = base
+	}
+}
+
+// apply moves a base occurrence by the offset.
+func (o OffsetSchedule) apply(t time.Time) time.Time {
+	t = t.In(o.location())
+
+	if !o.BusinessDays {
+		return o.Offset.NextDate(t)
+	}
+
+	days, step := o.Offset.Days, 1
+
+	if days < 0 {
+		days, step = -days, -1
+	}
+
+	for days > 0 {
+		t = t.AddDate(0, 0, step)
+
+		if o.isWorkingDay(t) {
+			days--
+		}
+	}
+
+	return t.Add(o.Offset.clockDuration())
+}
+
+func (o OffsetSchedule) isWorkingDay(t time.Time) bool {
+	if o.Calendar != nil {
+		return o.Calendar.IsWorkingDay(t)
+	}
+
+	return t.Weekday() != time.Saturday && t.Weekday() != time.Sunday
+}
+
+// reach bounds how far the offset moves an occurrence, so Next knows how far
+// back to look for the base occurrence of the next fire time. The extra day
+// covers DST changes.
+func (o OffsetSchedule) reach() time.Duration {
+	d := o.Offset
+
+	if d.negative() {
+		d = d.Negate()
+	}
+
+	days := d.Years*366 + d.Months*31 + d.Weeks*7 + d.Days
+
+	if o.BusinessDays {
+		days = (d.Days/5 + 1) * 7
+	}
+
+	if o.BusinessDays && o.Calendar != nil {
+		// room for the holidays on the way
+		days += maxRollDays
+	}
+
+	return time.Duration(days+1)*24*time.Hour + d.clockDuration()
+}
+
+// String formats the OFFSET line followed by the canonical base rule.
+func (o OffsetSchedule) String() string {
+	line := "OFFSET:"
+
+	if o.BusinessDays {
+		line = "OFFSET;DAYS=BUSINESS:"
+	}
+
+	return line + o.Offset.String() + "\n" + o.Base.String()
+}
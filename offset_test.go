@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOffsetFromString(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		Rule     string
+		Expected string
+	}{
+		{"OFFSET:-PT3H R/2017-01-01T09:00:00/P1D", "OFFSET:-PT3H\nR/2017-01-01T09:00:00/P1D"},
+		{"offset;days=business:-P2D 0 0 L * *", "OFFSET;DAYS=BUSINESS:-P2D\n0 0 L * *"},
+		{"OFFSET:PT180M\nDTSTART:20170101T090000Z\nRRULE:FREQ=DAILY", "OFFSET:PT3H\nDTSTART:20170101T090000Z\nRRULE:FREQ=DAILY"},
+	}
+
+	for index, test := range tests {
+		schedule, err := ScheduleFromString(test.Rule, ScheduleOptions{})
+
+		if err != nil {
+			t.Fatalf("Test %d expected correct rule but got error %s", index+1, err)
+		}
+
+		if result := schedule.String(); result != test.Expected {
+			t.Errorf("Test %d expected %q but got %q", index+1, test.Expected, result)
+		}
+	}
+
+	var errorTests = []struct {
+		Rule   string
+		Offset int
+		Token  string
+		Reason ParseReason
+	}{
+		{"OFFSET:-PT3H", 12, "", ReasonComponentCount},
+		{"OFFSET:-P3X R/2017-01-01T09:00:00/P1D", 10, "X", ReasonUnknownDesignator},
+		{"OFFSET;DAYS=BUSINESS:-P1M 0 0 L * *", 21, "-P1M", ReasonBusinessOffset},
+		{"OFFSET:-PT3H R/PT1H", 13, "R/PT1H", ReasonUnanchoredOffset},
+		{"OFFSET:-PT3H R/2017-01-01T09:00:00/P0D", 35, "P0D", ReasonZeroDuration},
+		{"OFFSET;DAYS=BUSINESS R/2017-01-01T09:00:00/P1D", 0, "OFFSET;DAYS=BUSINESS", ReasonMissingOffset},
+		{"OFFSET;DAYS=CALENDAR:-P1D R/2017-01-01T09:00:00/P1D", 7, "DAYS=CALENDAR", ReasonUnknownParameter},
+		{" OFFSET;DAYS=BUSINESS;WEEKS=2:-P1D R/2017-01-01T09:00:00/P1D", 22, "WEEKS=2", ReasonUnknownParameter},
+	}
+
+	for index, test := range errorTests {
+		_, err := ScheduleFromString(test.Rule, ScheduleOptions{})
+
+		var parseErr *ParseError
+
+		if !errors.As(err, &parseErr) {
+			t.Errorf("Test %d expected parse error but got %#v", index+1, err)
+		} else if parseErr.Offset != test.Offset || parseErr.Token != test.Token || parseErr.Reason != test.Reason {
+			t.Errorf("Test %d expected %s %q at offset %d but got %s %q at offset %d", index+1, test.Reason, test.Token, test.Offset, parseErr.Reason, parseErr.Token, parseErr.Offset)
+		} else if !errors.Is(err, ErrBadFormat) {
+			t.Errorf("Test %d expected %s but got %v", index+1, ErrBadFormat, err)
+		}
+	}
+}
+
+func TestOffsetNext(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		Rule     string
+		TimeZone string
+		After    time.Time
+		Expected []string
+	}{
+		{"OFFSET:-PT3H R/2017-01-01T09:00:00/P1D", "", now, []string{"2017-01-01T06:00:00Z", "2017-01-02T06:00:00Z", "2017-01-03T06:00:00Z"}},
+		{"OFFSET:-PT3H R2/2017-01-01T09:00:00/P1D", "", now.Add(7 * time.Hour), []string{"2017-01-02T06:00:00Z"}},
+		{"OFFSET:PT1H30M 0 9 * * MON", "", now, []string{"2017-01-02T10:30:00Z", "2017-01-09T10:30:00Z", "2017-01-16T10:30:00Z"}},
+		{"OFFSET:-P1D FREQ=MONTHLY;BYMONTHDAY=1", "", now, []string{"2017-01-31T00:00:00Z", "2017-02-28T00:00:00Z", "2017-03-31T00:00:00Z"}},
+		// month ends on Tuesday, Saturday and Friday
+		{"OFFSET;DAYS=BUSINESS:-P2D 0 18 L * *", "", now, []string{"2017-01-27T18:00:00Z", "2017-02-24T18:00:00Z", "2017-03-29T18:00:00Z"}},
+		// Saturday 4 and Sunday 5 February both become Monday 6 February
+		{"OFFSET;DAYS=BUSINESS:P1D 0 9 * FEB SAT,SUN", "", now, []string{"2017-02-06T09:00:00Z", "2017-02-13T09:00:00Z", "2017-02-20T09:00:00Z"}},
+		// a calendar day before 26 March 09:00 is 25 March 09:00, 23 hours
+		// earlier across the DST change
+		{"OFFSET:-P1D R/2017-03-19T09:00:00/P1W", "Europe/Berlin", time.Date(2017, 3, 21, 0, 0, 0, 0, time.UTC), []string{"2017-03-25T08:00:00Z", "2017-04-01T07:00:00Z", "2017-04-08T07:00:00Z"}},
+	}
+
+	for index, test := range tests {
+		schedule, err := Task{Rule: test.Rule, TimeZone: test.TimeZone}.Schedule()
+
+		if err != nil {
+			t.Fatalf("Test %d expected correct rule but got error %s", index+1, err)
+		}
+
+		result := nextOccurrences(schedule, test.After, 3)
+
+		if strings.Join(result, " ") != strings.Join(test.Expected, " ") {
+			t.Errorf("Test %d expected %v but got %v", index+1, test.Expected, result)
+		}
+	}
+}
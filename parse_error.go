@@ -0,0 +1,79 @@
+package main
+
+import "fmt"
+
+// ParseReason is a short code telling why a rule failed to parse.
+type ParseReason string
+
+const (
+	ReasonMalformedDuration     ParseReason = "malformed duration"
+	ReasonZeroDuration          ParseReason = "zero-valued duration"
+	ReasonUnknownDesignator     ParseReason = "unknown designator"
+	ReasonMissingDesignator     ParseReason = "missing designator"
+	ReasonMissingValue          ParseReason = "missing value"
+	ReasonMissingTimeDesignator ParseReason = "missing T before time component"
+	ReasonEmptyTime             ParseReason = "empty time part"
+	ReasonOutOfOrder            ParseReason = "designator out of order or repeated"
+	ReasonCombinedWeeks         ParseReason = "weeks combined with other components"
+	ReasonFractionNotLast       ParseReason = "fraction on other than the smallest component"
+	ReasonInexactFraction       ParseReason = "fraction does not fit smaller components"
+	ReasonBadRepeat             ParseReason = "bad repeat count"
+	ReasonBadDate               ParseReason = "bad date"
+	ReasonOutOfRange            ParseReason = "value out of range"
+	ReasonComponentCount        ParseReason = "wrong number of components"
+	ReasonMissingComponent      ParseReason = "missing duration or end"
+	ReasonTwoDurations          ParseReason = "more than one duration"
+	ReasonEndBeforeStart        ParseReason = "end before start"
+	ReasonNegativeDuration      ParseReason = "negative duration in a repeating interval"
+	ReasonMissingOffset         ParseReason = "missing colon before the offset"
+	ReasonUnknownParameter      ParseReason = "unknown parameter"
+	ReasonBusinessOffset        ParseReason = "business day offset with years, months or weeks"
+	ReasonUnanchoredOffset      ParseReason = "offset from a rule without start or end"
+	ReasonUnanchoredRoll        ParseReason = "calendar roll of a rule without start or end"
+	ReasonUnanchoredOperand     ParseReason = "set operation on a rule without start or end"
+	ReasonUnanchoredCount       ParseReason = "COUNT without DTSTART"
+	ReasonUnknownWord           ParseReason = "word not understood"
+	ReasonIncompletePhrase      ParseReason = "phrase ends too early"
+)
+
+// ParseError locates a rule parsing failure. Component is the index of the
+// "/" separated part of a recurrence, Offset the byte offset of Token in the
+// whole input. It matches ErrBadFormat with errors.Is.
+type ParseError struct {
+	Component int         `json:"component"`
+	Offset    int         `json:"offset"`
+	Token     string      `json:"token"`
+	Reason    ParseReason `json:"reason"`
+}
+
+func newParseError(offset int, token string, reason ParseReason) *ParseError {
+	return &ParseError{Offset: offset, Token: token, Reason: reason}
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s: %s %q at offset %d of component %d", ErrBadFormat, e.Reason, e.Token, e.Offset, e.Component)
+}
+
+// Unwrap makes the error match ErrBadFormat.
+func (e *ParseError) Unwrap() error {
+	return ErrBadFormat
+}
+
+// shifted returns a copy of the error moved by offset bytes within its
+// component.
+func (e *ParseError) shifted(offset int) *ParseError {
+	moved := *e
+	moved.Offset += offset
+
+	return &moved
+}
+
+// inComponent returns a copy of the error of a single component moved to its
+// place in the whole input.
+func (e *ParseError) inComponent(component, offset int) *ParseError {
+	moved := *e
+	moved.Component = component
+	moved.Offset += offset
+
+	return &moved
+}
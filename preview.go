@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	// maxPreviewCount caps the number of fire times a preview computes.
+	maxPreviewCount = 1000
+	// maxPreviewTime caps the time a preview spends computing fire times.
+	maxPreviewTime = 2 * time.Second
+)
+
+var (
+	//ErrBadCount preview count out of range
+	ErrBadCount = errors.New("bad count")
+)
+
+// RulePreview is a rule validated without creating a task, with its canonical
+// form, description and next fire times. Jitter is the delay the task id gets
+// within its jitter window. Truncated tells the fire times stopped short of
+// the count because computing them took too long.
+type RulePreview struct {
+	Rule        string              `json:"rule"`
+	Description string              `json:"description"`
+	Jitter      *RecurrenceInterval `json:"jitter,omitempty"`
+	FireTimes   []time.Time         `json:"fireTimes"`
+	Truncated   bool                `json:"truncated,omitempty"`
+}
+
+// previewRule returns the first count times the task would fire at if it were
+// created at the given time. Fire times are in the task time zone.
+func previewRule(task Task, from time.Time, count int, locale string) (*RulePreview, error) {
+	if count < 1 || count > maxPreviewCount {
+		return nil, ErrBadCount
+	}
+
+	options, err := task.ScheduleOptions()
+
+	if err != nil {
+		return nil, err
+	}
+
+	schedule, err := task.Schedule()
+
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &RulePreview{Rule: schedule.String(), Description: Describe(schedule, locale)}
+
+	if jittered, ok := schedule.(JitteredSchedule); ok {
+		jitter := jittered.JitterInterval()
+		preview.Jitter = &jitter
+	}
+
+	times, truncated := fireTimes(schedule, from, count, time.Now().Add(maxPreviewTime))
+	preview.Truncated = truncated
+
+	for _, fireTime := range times {
+		preview.FireTimes = append(preview.FireTimes, fireTime.In(options.Location))
+	}
+
+	return preview, nil
+}
+
+// fireTimes returns up to count times the schedule fires at after the given
+// time, the way scheduleTask walks it, and whether the deadline passed before
+// all of them were found. A bare duration fires right away and then counts
+// from there.
+func fireTimes(schedule Schedule, after time.Time, count int, deadline time.Time) ([]time.Time, bool) {
+	if recurrence, ok := schedule.(Recurrence); ok && recurrence.Start == nil && recurrence.End == nil {
+		schedule = recurrence.AnchoredAt(after)
+		after = after.Add(-time.Nanosecond)
+	}
+
+	var times []time.Time
+
+	it := iterate(schedule, after)
+
+	for len(times) < count {
+		if !time.Now().Before(deadline) {
+			return times, true
+		}
+
+		next, ok := it.Next()
+
+		if !ok {
+			break
+		}
+
+		times = append(times, next)
+	}
+
+	return times, false
+}
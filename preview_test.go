@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPreviewRule(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		Task     Task
+		Count    int
+		Expected []string
+	}{
+		{Task{Rule: "R/PT1H"}, 3, []string{"2017-01-01T00:00:00Z", "2017-01-01T01:00:00Z", "2017-01-01T02:00:00Z"}},
+		{Task{Rule: "R2/PT1H"}, 5, []string{"2017-01-01T00:00:00Z", "2017-01-01T01:00:00Z"}},
+		{Task{Rule: "R/2016-12-31T09:00:00/P1D", TimeZone: "Europe/Berlin"}, 2, []string{"2017-01-01T09:00:00+01:00", "2017-01-02T09:00:00+01:00"}},
+		{Task{Rule: "FREQ=WEEKLY;BYDAY=MO"}, 2, []string{"2017-01-02T00:00:00Z", "2017-01-09T00:00:00Z"}},
+		{Task{Rule: "0 9 * * *", TimeZone: "America/New_York"}, 1, []string{"2017-01-01T09:00:00-05:00"}},
+		{Task{Rule: "R1/2016-01-01T00:00:00Z/P1D"}, 3, nil},
+		{Task{Id: "backup", Rule: "0 0 * * *", Jitter: "PT1H"}, 2, []string{"2017-01-01T00:27:27Z", "2017-01-02T00:27:27Z"}},
+	}
+
+	for index, test := range tests {
+		preview, err := previewRule(test.Task, now, test.Count, DefaultLocale)
+
+		if err != nil {
+			t.Fatalf("Test %d expected no error but got %s", index+1, err)
+		}
+
+		if len(preview.FireTimes) != len(test.Expected) {
+			t.Errorf("Test %d expected %v but got %v", index+1, test.Expected, preview.FireTimes)
+			continue
+		}
+
+		for i, fireTime := range preview.FireTimes {
+			if result := fireTime.Format(time.RFC3339); result != test.Expected[i] {
+				t.Errorf("Test %d expected %s but got %s", index+1, test.Expected[i], result)
+			}
+		}
+	}
+}
+
+func TestPreviewRuleErrors(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		Task     Task
+		Count    int
+		Expected error
+	}{
+		{Task{Rule: "R/PT1H"}, 0, ErrBadCount},
+		{Task{Rule: "R/PT1H"}, maxPreviewCount + 1, ErrBadCount},
+		{Task{Rule: "R/PT1X"}, 1, ErrBadFormat},
+		{Task{Rule: "R/PT1H", TimeZone: "Mars/Olympus"}, 1, ErrBadTimeZone},
+		{Task{Rule: "R/PT1H", DSTGap: "later"}, 1, ErrBadDSTPolicy},
+		{Task{Rule: "R/PT1H", Jitter: "PT5M"}, 1, ErrBadJitter},
+	}
+
+	for index, test := range tests {
+		if _, err := previewRule(test.Task, now, test.Count, DefaultLocale); !errors.Is(err, test.Expected) {
+			t.Errorf("Test %d expected %s but got %v", index+1, test.Expected, err)
+		}
+	}
+
+	preview, err := previewRule(Task{Rule: "R/PT60M"}, now, 1, DefaultLocale)
+
+	if err != nil || preview.Rule != "R/PT1H" || preview.Description != "every hour" {
+		t.Errorf("Expected canonical rule and description but got %+v, %v", preview, err)
+	}
+}
+
+func TestPreviewRuleJitter(t *testing.T) {
+	t.Parallel()
+
+	preview, err := previewRule(Task{Id: "backup", Rule: "0 * * * *", Jitter: "PT1H"}, now, 1, DefaultLocale)
+
+	if err != nil {
+		t.Fatalf("Expected no error but got %s", err)
+	}
+
+	if preview.Jitter == nil || preview.Jitter.String() != "PT27M27S" {
+		t.Errorf("Expected jitter PT27M27S but got %v", preview.Jitter)
+	}
+
+	if expected := "at minute 0, UTC time, delayed by 27 minutes and 27 seconds"; preview.Description != expected {
+		t.Errorf("Expected %q but got %q", expected, preview.Description)
+	}
+}
+
+func TestFireTimesDeadline(t *testing.T) {
+	t.Parallel()
+
+	schedule, _ := ScheduleFromString("R/PT1S/2027-01-01T00:00:00Z", ScheduleOptions{})
+
+	if times, truncated := fireTimes(schedule, now, 3, time.Now().Add(time.Minute)); truncated || len(times) != 3 {
+		t.Errorf("Expected 3 fire times but got %v, truncated %t", times, truncated)
+	}
+
+	if times, truncated := fireTimes(schedule, now, 3, time.Now()); !truncated || len(times) != 0 {
+		t.Errorf("Expected a passed deadline to truncate the fire times but got %v", times)
+	}
+}
@@ -1,22 +1,64 @@
 package main
 
-import "time"
+import (
+	"errors"
+	"time"
+)
+
+var (
+	//ErrBadMonthEndPolicy unknown month end policy
+	ErrBadMonthEndPolicy = errors.New("bad month end policy")
+)
+
+// MonthEndPolicy tells what happens to an occurrence whose day does not exist
+// in its month, e.g. the 31st for a monthly series starting on 31 January.
+type MonthEndPolicy int
+
+const (
+	// MonthEndClamp fires the occurrence on the last day of the month.
+	MonthEndClamp MonthEndPolicy = iota
+	// MonthEndOverflow fires the occurrence the days past the end of the month
+	// into the next month, the way time.AddDate normalizes dates.
+	MonthEndOverflow
+	// MonthEndSkip drops the occurrence.
+	MonthEndSkip
+)
+
+// MonthEndPolicyFromString parses "clamp", "overflow" or "skip", an empty
+// string means clamp.
+func MonthEndPolicyFromString(policy string) (MonthEndPolicy, error) {
+	switch policy {
+	case "", "clamp":
+		return MonthEndClamp, nil
+	case "overflow":
+		return MonthEndOverflow, nil
+	case "skip":
+		return MonthEndSkip, nil
+	}
+
+	return MonthEndClamp, ErrBadMonthEndPolicy
+}
 
 type Recurrence struct {
 	Repetitions int
 	Start       *time.Time
 	End         *time.Time
 	Duration    *RecurrenceInterval
+	Location    *time.Location
+	DSTGap      GapPolicy
+	DSTOverlap  OverlapPolicy
+	MonthEnd    MonthEndPolicy
 }
 
 type RecurrenceInterval struct {
-	Years   int
-	Months  int
-	Weeks   int
-	Days    int
-	Hours   int
-	Minutes int
-	Seconds int
+	Years       int
+	Months      int
+	Weeks       int
+	Days        int
+	Hours       int
+	Minutes     int
+	Seconds     int
+	Nanoseconds int
 }
 
 // NextDate returns the next time by adding recurrence interval.
@@ -26,6 +68,440 @@ func (d RecurrenceInterval) NextDate(fromDate time.Time) time.Time {
 	nextTime = nextTime.Add(time.Duration(d.Hours) * time.Hour)
 	nextTime = nextTime.Add(time.Duration(d.Minutes) * time.Minute)
 	nextTime = nextTime.Add(time.Duration(d.Seconds) * time.Second)
+	nextTime = nextTime.Add(time.Duration(d.Nanoseconds))
 
 	return nextTime
 }
+
+// PrevDate returns the previous time by subtracting recurrence interval.
+func (d RecurrenceInterval) PrevDate(fromDate time.Time) time.Time {
+	prevTime := fromDate.Add(-time.Duration(d.Nanoseconds))
+
+	prevTime = prevTime.Add(-time.Duration(d.Seconds) * time.Second)
+
+	prevTime = prevTime.Add(-time.Duration(d.Minutes) * time.Minute)
+	prevTime = prevTime.Add(-time.Duration(d.Hours) * time.Hour)
+
+	return prevTime.AddDate(-d.Years, -d.Months, -(d.Weeks*7 + d.Days))
+}
+
+// calendarDate moves the date by n times the years and months of the interval,
+// settling a day missing from the target month by the policy, and then by n
+// times its weeks and days. It returns false when the policy skips the date.
+func (d RecurrenceInterval) calendarDate(fromDate time.Time, n int, policy MonthEndPolicy) (time.Time, bool) {
+	year, month, day := fromDate.Date()
+	hour, min, sec := fromDate.Clock()
+	target := time.Date(year, month+time.Month(n*(d.Years*12+d.Months)), 1, 0, 0, 0, 0, time.UTC)
+
+	if last := target.AddDate(0, 1, -1).Day(); day > last {
+		switch policy {
+		case MonthEndClamp:
+			day = last
+		case MonthEndSkip:
+			return time.Time{}, false
+		}
+	}
+
+	date := time.Date(target.Year(), target.Month(), day, hour, min, sec, fromDate.Nanosecond(), fromDate.Location())
+
+	return date.AddDate(0, 0, n*(d.Weeks*7+d.Days)), true
+}
+
+// clockDuration returns the hours, minutes, seconds and nanoseconds of the
+// interval as elapsed time.
+func (d RecurrenceInterval) clockDuration() time.Duration {
+	return time.Duration(d.Hours)*time.Hour + time.Duration(d.Minutes)*time.Minute + time.Duration(d.Seconds)*time.Second + time.Duration(d.Nanoseconds)
+}
+
+func (d RecurrenceInterval) hasCalendarPart() bool {
+	return d.Years != 0 || d.Months != 0 || d.Weeks != 0 || d.Days != 0
+}
+
+// AnchoredAt returns the recurrence starting at the given time when it has
+// neither start nor end, and the recurrence unchanged otherwise.
+func (r Recurrence) AnchoredAt(start time.Time) Recurrence {
+	if r.Start == nil && r.End == nil {
+		start = start.In(r.location())
+		r.Start = &start
+	}
+
+	return r
+}
+
+// Next returns the first occurrence strictly after the given time, or false
+// when there is none.
+func (r Recurrence) Next(after time.Time) (time.Time, bool) {
+	return r.Iterator(after).Next()
+}
+
+// count returns how many slots have occurrences after the given time up to
+// upTo. A slot skipped by a policy counts as well, one fired twice in a DST
+// overlap counts once.
+func (r Recurrence) count(after, upTo time.Time) int {
+	first, last := r.Iterator(after), r.Iterator(upTo)
+	first.Next()
+	last.Next()
+
+	return last.position() - first.position()
+}
+
+// remaining returns how many repetitions are left counting the first
+// occurrence at or after t, -1 for a series without a repetition count
+// running forward, see RecurrenceIterator.Remaining.
+func (r Recurrence) remaining(t time.Time) int {
+	it := r.Iterator(t.Add(-time.Nanosecond))
+
+	if _, ok := it.Next(); ok {
+		return it.Remaining()
+	}
+
+	return 0
+}
+
+// Occurrences returns the occurrences within [from, to), at most limit of
+// them. A zero to leaves the range open and a limit below 1 means no limit,
+// but an unbounded series running forward needs at least one of the two.
+func (r Recurrence) Occurrences(from, to time.Time, limit int) []time.Time {
+	if to.IsZero() && limit < 1 && r.Repetitions < 0 && r.Start != nil {
+		return nil
+	}
+
+	var occurrences []time.Time
+
+	it := r.Iterator(from.Add(-time.Nanosecond))
+
+	for limit < 1 || len(occurrences) < limit {
+		next, ok := it.Next()
+
+		if !ok || (!to.IsZero() && !next.Before(to)) {
+			break
+		}
+
+		occurrences = append(occurrences, next)
+	}
+
+	return occurrences
+}
+
+func (r Recurrence) location() *time.Location {
+	if r.Location == nil {
+		return time.UTC
+	}
+
+	return r.Location
+}
+
+// wall returns the floating wall time of t in the recurrence location when the
+// interval has a calendar part, and t itself otherwise.
+func (r Recurrence) wall(t time.Time) time.Time {
+	if !r.Duration.hasCalendarPart() {
+		return t
+	}
+
+	return floating(t.In(r.location()))
+}
+
+// instants returns when the occurrence with the given floating wall time and
+// elapsed clock part happens, or nothing when the DST gap policy skips it.
+func (r Recurrence) instants(wall time.Time, elapsed time.Duration) []time.Time {
+	if !r.Duration.hasCalendarPart() {
+		return []time.Time{wall.Add(elapsed)}
+	}
+
+	instants := resolveWallTime(wall, r.location(), r.DSTGap, r.DSTOverlap)
+
+	for i := range instants {
+		instants[i] = instants[i].Add(elapsed)
+	}
+
+	return instants
+}
+
+// slot returns the instants of the k-th occurrence counted from the anchor
+// wall time, or nothing when a policy skips it.
+func (r Recurrence) slot(anchor time.Time, k int) []time.Time {
+	if !r.Duration.hasCalendarPart() {
+		// the anchor is a real instant, which rebuilding its date would move
+		// to the other offset within a repeated hour
+		return r.instants(anchor, time.Duration(k)*r.Duration.clockDuration())
+	}
+
+	wall, ok := r.Duration.calendarDate(anchor, k, r.MonthEnd)
+
+	if !ok {
+		return nil
+	}
+
+	return r.instants(wall, time.Duration(k)*r.Duration.clockDuration())
+}
+
+// seekMargin is how many slots seek backs off from the one its wall time
+// estimate lands on, making up for DST offsets and month end policies.
+const seekMargin = 2
+
+// seek returns the index of a slot at or before the first occurrence strictly
+// after the given time, so a series that started long ago is not walked from
+// its start. A fixed-length interval divides the elapsed time, a calendar one
+// gallops and then bisects over the wall times of the slots.
+func (r Recurrence) seek(anchor, after time.Time) int {
+	d := *r.Duration
+
+	if !d.hasCalendarPart() {
+		elapsed := after.Sub(anchor)
+
+		if elapsed < 0 {
+			return 0
+		}
+
+		return r.capped(int(elapsed / d.clockDuration()))
+	}
+
+	target := r.wall(after)
+	reaches := func(k int) bool {
+		wall, _ := d.calendarDate(anchor, k, MonthEndClamp)
+
+		return !wall.Add(time.Duration(k) * d.clockDuration()).After(target)
+	}
+
+	if !reaches(0) {
+		return 0
+	}
+
+	// reaches(lo) holds, reaches(hi) does not unless hi is the cap
+	lo, hi := 0, 1
+
+	for hi < r.capped(hi+1) && reaches(hi) {
+		lo, hi = hi, r.capped(2*hi)
+	}
+
+	for hi-lo > 1 {
+		if mid := lo + (hi-lo)/2; reaches(mid) {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+
+	if lo < seekMargin {
+		return 0
+	}
+
+	return lo - seekMargin
+}
+
+// seekBack returns the slot, counted back from the end, at or before the first
+// occurrence strictly after the given time of a series running backwards, or
+// -1 when all of its occurrences are at or before that time. Like seek, it
+// divides a fixed-length interval and gallops over a calendar one.
+func (r Recurrence) seekBack(anchor, after time.Time) int {
+	d := *r.Duration
+
+	if r.Repetitions == 0 {
+		return -1
+	}
+
+	if !d.hasCalendarPart() {
+		elapsed := anchor.Sub(after)
+
+		if elapsed <= 0 {
+			return -1
+		}
+
+		return r.cappedBack(int(elapsed / d.clockDuration()))
+	}
+
+	target := r.wall(after)
+	reaches := func(k int) bool {
+		wall, _ := d.calendarDate(anchor, -k, MonthEndClamp)
+
+		return wall.Add(time.Duration(-k) * d.clockDuration()).After(target)
+	}
+
+	if !reaches(0) {
+		return r.cappedBack(seekMargin)
+	}
+
+	// reaches(lo) holds, reaches(hi) does not unless hi is the cap
+	lo, hi := 0, r.cappedBack(1)
+
+	for hi < r.cappedBack(hi+1) && reaches(hi) {
+		lo, hi = hi, r.cappedBack(2*hi)
+	}
+
+	for hi-lo > 1 {
+		if mid := lo + (hi-lo)/2; reaches(mid) {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+
+	return r.cappedBack(lo + seekMargin)
+}
+
+// capped returns the slot index bounded by the repetitions of the series.
+func (r Recurrence) capped(k int) int {
+	if r.Repetitions >= 0 && k > r.Repetitions {
+		return r.Repetitions
+	}
+
+	return k
+}
+
+// cappedBack returns the slot index counted back from the end bounded by the
+// repetitions of the series.
+func (r Recurrence) cappedBack(k int) int {
+	if r.Repetitions >= 0 && k > r.Repetitions-1 {
+		return r.Repetitions - 1
+	}
+
+	return k
+}
+
+// RecurrenceIterator walks the occurrences of a recurrence in chronological
+// order.
+type RecurrenceIterator struct {
+	recurrence Recurrence
+	after      time.Time
+	next       time.Time
+	anchor     time.Time
+	// index is the slot to compute next, counted back from the end for a
+	// series running backwards, and slot the one of the queued instants.
+	index  int
+	slot   int
+	queued []time.Time
+	done   bool
+}
+
+// Iterator returns an iterator positioned before the first occurrence strictly
+// after the given time. A recurrence with neither start nor end has no
+// occurrences until it is anchored.
+//
+// Years, months, weeks and days of the interval are added to the wall clock
+// in the recurrence location, hours, minutes and seconds are elapsed time.
+// The n-th occurrence is computed from the anchor as n times the interval, so
+// a month-end start settled by the MonthEnd policy does not drift. A series
+// given by start and end repeats their elapsed difference.
+//
+// A series seeks the given time rather than walking up to it. One given by
+// duration and end runs backwards from the end, so it seeks its earliest slot
+// after the given time and walks towards the end.
+func (r Recurrence) Iterator(after time.Time) *RecurrenceIterator {
+	it := &RecurrenceIterator{recurrence: r, after: after}
+
+	switch {
+	case r.Start != nil && r.Duration != nil:
+		it.anchor = r.wall(*r.Start)
+		it.index = r.seek(it.anchor, after)
+	case r.Start != nil && r.End != nil:
+		step := r.End.Sub(*r.Start)
+
+		if step <= 0 {
+			it.done = true
+			break
+		}
+
+		it.next = *r.Start
+
+		if !it.next.After(after) {
+			it.index = int(after.Sub(*r.Start)/step) + 1
+			it.next = r.Start.Add(time.Duration(it.index) * step)
+		}
+	case r.End != nil && r.Duration != nil:
+		it.anchor = r.wall(*r.End)
+		it.index = r.seekBack(it.anchor, after)
+	default:
+		it.done = true
+	}
+
+	return it
+}
+
+// Next returns the next occurrence, or false when the series is exhausted.
+func (it *RecurrenceIterator) Next() (time.Time, bool) {
+	for !it.done {
+		if len(it.queued) > 0 {
+			next := it.queued[0]
+			it.queued = it.queued[1:]
+
+			if next.After(it.after) {
+				return next, true
+			}
+
+			continue
+		}
+
+		if it.exhausted() {
+			it.done = true
+			break
+		}
+
+		it.queued = it.advance()
+	}
+
+	return time.Time{}, false
+}
+
+// advance returns the instants of the current slot and moves to the
+// following one.
+func (it *RecurrenceIterator) advance() []time.Time {
+	r := it.recurrence
+
+	if r.Start == nil {
+		it.slot = it.index
+		it.index--
+
+		return r.slot(it.anchor, -it.slot)
+	}
+
+	it.index++
+
+	if r.Duration == nil {
+		next := it.next
+		it.next = next.Add(r.End.Sub(*r.Start))
+
+		return []time.Time{next}
+	}
+
+	return r.slot(it.anchor, it.index-1)
+}
+
+func (it *RecurrenceIterator) exhausted() bool {
+	if it.recurrence.Start == nil {
+		return it.index < 0
+	}
+
+	return it.recurrence.Repetitions >= 0 && it.index >= it.recurrence.Repetitions
+}
+
+// position returns the slot of the occurrence Next returned last in
+// chronological order, or the one past the last slot once the series is
+// exhausted. The slots of a series running backwards count up to 0 at the
+// end.
+func (it *RecurrenceIterator) position() int {
+	switch {
+	case it.recurrence.Start == nil && it.done:
+		return 1
+	case it.recurrence.Start == nil:
+		return -it.slot
+	case it.done:
+		return it.index
+	}
+
+	return it.index - 1
+}
+
+// Remaining returns how many repetitions are left counting the occurrence
+// Next returned last, those before it being used up, or -1 for a series
+// without a repetition count running forward.
+func (it *RecurrenceIterator) Remaining() int {
+	if it.recurrence.Start == nil {
+		return it.slot + 1
+	}
+
+	if it.recurrence.Repetitions < 0 {
+		return -1
+	}
+
+	return it.recurrence.Repetitions - (it.index - 1)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+const canonicalDateFormat = "2006-01-02T15:04:05.999999999"
+
+// String formats the interval as a canonical ISO 8601 duration, e.g. PT60M
+// and PT1H are both written PT1H, and a negative one with a leading minus.
+func (d RecurrenceInterval) String() string {
+	if d.negative() {
+		return "-" + d.Negate().String()
+	}
+
+	d = d.Normalize()
+
+	if d.Weeks != 0 {
+		return "P" + strconv.Itoa(d.Weeks) + "W"
+	}
+
+	var b strings.Builder
+
+	b.WriteString("P")
+
+	for _, part := range []struct {
+		value      int
+		designator string
+	}{{d.Years, "Y"}, {d.Months, "M"}, {d.Days, "D"}} {
+		if part.value != 0 {
+			b.WriteString(strconv.Itoa(part.value) + part.designator)
+		}
+	}
+
+	if d.Hours == 0 && d.Minutes == 0 && d.Seconds == 0 && d.Nanoseconds == 0 {
+		if b.Len() == 1 {
+			return "PT0S"
+		}
+
+		return b.String()
+	}
+
+	b.WriteString("T")
+
+	for _, part := range []struct {
+		value      int
+		designator string
+	}{{d.Hours, "H"}, {d.Minutes, "M"}} {
+		if part.value != 0 {
+			b.WriteString(strconv.Itoa(part.value) + part.designator)
+		}
+	}
+
+	if d.Seconds != 0 || d.Nanoseconds != 0 {
+		b.WriteString(strconv.Itoa(d.Seconds))
+
+		if d.Nanoseconds != 0 {
+			fraction := strconv.Itoa(int(time.Second) + d.Nanoseconds)[1:]
+			b.WriteString("." + strings.TrimRight(fraction, "0"))
+		}
+
+		b.WriteString("S")
+	}
+
+	return b.String()
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (d RecurrenceInterval) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (d *RecurrenceInterval) UnmarshalText(text []byte) error {
+	duration, err := DurationFromString(string(text))
+
+	if err != nil {
+		return err
+	}
+
+	*d = duration
+
+	return nil
+}
+
+// String formats the recurrence as a canonical ISO 8601 repeating interval,
+// with dates as extended wall times in the recurrence location.
+func (r Recurrence) String() string {
+	parts := []string{"R"}
+
+	if r.Repetitions >= 0 {
+		parts[0] += strconv.Itoa(r.Repetitions)
+	}
+
+	if r.Start != nil {
+		parts = append(parts, r.Start.In(r.location()).Format(canonicalDateFormat))
+	}
+
+	if r.Duration != nil {
+		parts = append(parts, r.Duration.String())
+	}
+
+	if r.End != nil {
+		parts = append(parts, r.End.In(r.location()).Format(canonicalDateFormat))
+	}
+
+	return strings.Join(parts, "/")
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (r Recurrence) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, dates are taken in the
+// location already set on the recurrence, UTC when there is none.
+func (r *Recurrence) UnmarshalText(text []byte) error {
+	recurrence, err := RecurrenceFromStringIn(string(text), r.location())
+
+	if err != nil {
+		return err
+	}
+
+	recurrence.DSTGap, recurrence.DSTOverlap, recurrence.MonthEnd = r.DSTGap, r.DSTOverlap, r.MonthEnd
+	*r = recurrence
+
+	return nil
+}
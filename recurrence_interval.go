@@ -0,0 +1,116 @@
+package main
+
+import "time"
+
+// Average lengths Approx assumes for calendar units: a Gregorian year of
+// 365.2425 days and a twelfth of it for a month.
+const (
+	approxDay   = 24 * time.Hour
+	approxYear  = 365*approxDay + 5*time.Hour + 49*time.Minute + 12*time.Second
+	approxMonth = approxYear / 12
+)
+
+// Add returns the component-wise sum of the intervals.
+func (d RecurrenceInterval) Add(other RecurrenceInterval) RecurrenceInterval {
+	return RecurrenceInterval{
+		Years:       d.Years + other.Years,
+		Months:      d.Months + other.Months,
+		Weeks:       d.Weeks + other.Weeks,
+		Days:        d.Days + other.Days,
+		Hours:       d.Hours + other.Hours,
+		Minutes:     d.Minutes + other.Minutes,
+		Seconds:     d.Seconds + other.Seconds,
+		Nanoseconds: d.Nanoseconds + other.Nanoseconds,
+	}
+}
+
+// Scale returns the interval with every component multiplied by n.
+func (d RecurrenceInterval) Scale(n int) RecurrenceInterval {
+	return RecurrenceInterval{
+		Years:       d.Years * n,
+		Months:      d.Months * n,
+		Weeks:       d.Weeks * n,
+		Days:        d.Days * n,
+		Hours:       d.Hours * n,
+		Minutes:     d.Minutes * n,
+		Seconds:     d.Seconds * n,
+		Nanoseconds: d.Nanoseconds * n,
+	}
+}
+
+// Negate returns the interval with every component negated.
+func (d RecurrenceInterval) Negate() RecurrenceInterval {
+	return d.Scale(-1)
+}
+
+// IsZero tells whether every component of the interval is zero.
+func (d RecurrenceInterval) IsZero() bool {
+	return d == RecurrenceInterval{}
+}
+
+// negative tells whether the interval has a negative component and no
+// positive one.
+func (d RecurrenceInterval) negative() bool {
+	less, greater := false, false
+
+	for _, value := range []int{d.Years, d.Months, d.Weeks, d.Days, d.Hours, d.Minutes, d.Seconds, d.Nanoseconds} {
+		less, greater = less || value < 0, greater || value > 0
+	}
+
+	return less && !greater
+}
+
+// Normalize carries seconds into minutes, minutes into hours and months into
+// years, and writes weeks as days unless the interval is whole weeks only, so
+// PT90M becomes PT1H30M. Hours are not carried into days, which are calendar
+// days, and neither are days into months.
+func (d RecurrenceInterval) Normalize() RecurrenceInterval {
+	d.Seconds += d.Nanoseconds / int(time.Second)
+	d.Nanoseconds %= int(time.Second)
+	d.Minutes += d.Seconds / 60
+	d.Seconds %= 60
+	d.Hours += d.Minutes / 60
+	d.Minutes %= 60
+	d.Years += d.Months / 12
+	d.Months %= 12
+	d.Days += d.Weeks * 7
+	d.Weeks = 0
+
+	if d.Days%7 == 0 && d.Years == 0 && d.Months == 0 && d.Hours == 0 && d.Minutes == 0 && d.Seconds == 0 && d.Nanoseconds == 0 {
+		d.Weeks, d.Days = d.Days/7, 0
+	}
+
+	return d
+}
+
+// Approx returns the length of the interval assuming days of 24 hours,
+// Gregorian average years of 365.2425 days and months of a twelfth of that.
+// Use DurationAt where the exact length from a given date matters.
+func (d RecurrenceInterval) Approx() time.Duration {
+	return time.Duration(d.Years)*approxYear + time.Duration(d.Months)*approxMonth + time.Duration(d.Weeks*7+d.Days)*approxDay + d.clockDuration()
+}
+
+// DurationAt returns the elapsed time from the anchor to the anchor moved by
+// the interval, with calendar parts added to the wall clock in the anchor
+// location and a day missing from the target month clamped to its last day.
+func (d RecurrenceInterval) DurationAt(anchor time.Time) time.Duration {
+	date, _ := d.calendarDate(anchor, 1, MonthEndClamp)
+
+	return date.Add(d.clockDuration()).Sub(anchor)
+}
+
+// CompareAt returns -1, 0 or 1 as the interval is shorter than, as long as or
+// longer than the other one when both are measured from the anchor, e.g. P1M
+// is shorter than P30D from 1 February but longer from 1 March.
+func (d RecurrenceInterval) CompareAt(other RecurrenceInterval, anchor time.Time) int {
+	a, b := d.DurationAt(anchor), other.DurationAt(anchor)
+
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+
+	return 0
+}
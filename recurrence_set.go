@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	//ErrBadTaskDates extra or excluded dates for a rule without start or end
+	ErrBadTaskDates = errors.New("bad task dates")
+)
+
+// RecurrenceSet is a schedule with single occurrences taken out (EXDATE) and
+// one-off occurrences added (RDATE), as in RFC 5545. Excluded dates remove
+// added dates as well.
+type RecurrenceSet struct {
+	Schedule Schedule
+	ExDates  []time.Time
+	RDates   []time.Time
+}
+
+// Next returns the first occurrence of the schedule or the added dates
+// strictly after the given time that is not excluded.
+func (s RecurrenceSet) Next(after time.Time) (time.Time, bool) {
+	next, ok := s.Schedule.Next(after)
+
+	for ok && s.excludes(next) {
+		next, ok = s.Schedule.Next(next)
+	}
+
+	for _, rdate := range s.RDates {
+		if rdate.After(after) && (!ok || rdate.Before(next)) && !s.excludes(rdate) {
+			next, ok = rdate, true
+		}
+	}
+
+	return next, ok
+}
+
+// String returns the canonical rule of the schedule, the dates are kept apart
+// from it.
+func (s RecurrenceSet) String() string {
+	return s.Schedule.String()
+}
+
+func (s RecurrenceSet) excludes(t time.Time) bool {
+	for _, exdate := range s.ExDates {
+		if exdate.Equal(t) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// remaining returns the repetitions left of the schedule counting its first
+// occurrence at or after t, excluded occurrences using up repetitions as
+// well, see scheduleRemaining.
+func (s RecurrenceSet) remaining(t time.Time) int {
+	return scheduleRemaining(s.Schedule, t)
+}
+
+// firesAt tells whether the set has an occurrence within the second up to t,
+// the precision fire times are stored with.
+func (s RecurrenceSet) firesAt(t time.Time) bool {
+	next, ok := s.Next(t.Add(-time.Second))
+
+	return ok && !next.After(t)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecurrenceSetNext(t *testing.T) {
+	t.Parallel()
+
+	day := func(d int) time.Time {
+		return time.Date(2017, 1, d, 9, 0, 0, 0, time.UTC)
+	}
+
+	var tests = []struct {
+		Rule     string
+		ExDates  []time.Time
+		RDates   []time.Time
+		Expected []string
+	}{
+		{"R/2017-01-01T09:00:00/P1D", []time.Time{day(2)}, nil, []string{"2017-01-01T09:00:00Z", "2017-01-03T09:00:00Z", "2017-01-04T09:00:00Z"}},
+		{"R/2017-01-01T09:00:00/P1D", []time.Time{day(1), day(2), day(3)}, nil, []string{"2017-01-04T09:00:00Z", "2017-01-05T09:00:00Z", "2017-01-06T09:00:00Z"}},
+		{"R/2017-01-01T09:00:00/P1W", nil, []time.Time{day(10), day(3).Add(time.Hour)}, []string{"2017-01-01T09:00:00Z", "2017-01-03T10:00:00Z", "2017-01-08T09:00:00Z", "2017-01-10T09:00:00Z", "2017-01-15T09:00:00Z"}},
+		{"R/2017-01-01T09:00:00/P1W", []time.Time{day(3)}, []time.Time{day(3), day(8)}, []string{"2017-01-01T09:00:00Z", "2017-01-08T09:00:00Z", "2017-01-15T09:00:00Z"}},
+		{"R1/2017-01-01T09:00:00/P1D", []time.Time{day(1)}, []time.Time{day(5)}, []string{"2017-01-05T09:00:00Z"}},
+		{"0 9 * * *", []time.Time{day(1)}, nil, []string{"2017-01-02T09:00:00Z", "2017-01-03T09:00:00Z"}},
+	}
+
+	for index, test := range tests {
+		schedule, err := Task{Rule: test.Rule, ExDates: test.ExDates, RDates: test.RDates}.Schedule()
+
+		if err != nil {
+			t.Fatalf("Test %d expected correct rule but got error %s", index+1, err)
+		}
+
+		result := nextOccurrences(schedule, now, len(test.Expected))
+
+		if strings.Join(result, " ") != strings.Join(test.Expected, " ") {
+			t.Errorf("Test %d expected %v but got %v", index+1, test.Expected, result)
+		}
+	}
+}
+
+func TestRecurrenceSetFiresAt(t *testing.T) {
+	t.Parallel()
+
+	schedule, err := Task{Rule: "R/2017-01-01T09:00:00/P1D", ExDates: []time.Time{now.Add(33 * time.Hour)}}.Schedule()
+
+	if err != nil {
+		t.Fatalf("Expected correct rule but got error %s", err)
+	}
+
+	set := schedule.(RecurrenceSet)
+
+	if !set.firesAt(now.Add(9 * time.Hour)) {
+		t.Errorf("Expected set to fire on the first day")
+	}
+
+	if set.firesAt(now.Add(33 * time.Hour)) {
+		t.Errorf("Expected set not to fire on an excluded day")
+	}
+
+	if set.String() != "R/2017-01-01T09:00:00/P1D" {
+		t.Errorf("Expected the rule without dates but got %s", set.String())
+	}
+
+	if _, err := (Task{Rule: "R/PT1H", RDates: []time.Time{now}}).Schedule(); err != ErrBadTaskDates {
+		t.Errorf("Expected %s but got %v", ErrBadTaskDates, err)
+	}
+}
@@ -1,6 +1,9 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
+	"strings"
 	"testing"
 	"time"
 )
@@ -26,14 +29,660 @@ func TestNextDate(t *testing.T) {
 		{RecurrenceInterval{Years: 1}, "2018-01-01T00:00:00Z"},
 		{RecurrenceInterval{Years: 3, Months: 1, Days: 28}, "2020-02-29T00:00:00Z"}, //leap year test
 		{RecurrenceInterval{Years: 3, Months: 1, Weeks: 4}, "2020-02-29T00:00:00Z"}, //leap year test
+		{RecurrenceInterval{Seconds: 1, Nanoseconds: 500000000}, "2017-01-01T00:00:01.5Z"},
 	}
 
 	for index, test := range tests {
 		nextDate := test.Interval.NextDate(now)
-		result := nextDate.Format(time.RFC3339)
+		result := nextDate.Format(time.RFC3339Nano)
 
 		if result != test.Expected {
 			t.Errorf("Test %d expected %s but got %s", index+1, test.Expected, result)
 		}
 	}
 }
+
+func TestRecurrenceNext(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		Rule     string
+		After    time.Time
+		Expected string
+	}{
+		{"R/20170101T000000/PT1H", now, "2017-01-01T01:00:00Z"},
+		{"R/20170101T000000/PT1H", now.Add(-time.Second), "2017-01-01T00:00:00Z"},
+		{"R/20170101T000000/PT1H", now.Add(90 * time.Minute), "2017-01-01T02:00:00Z"},
+		{"R3/20170101T000000/PT1H", now.Add(90 * time.Minute), "2017-01-01T02:00:00Z"},
+		{"R2/20170101T000000/PT1H", now.Add(90 * time.Minute), "<none>"},
+		{"R0/20170101T000000/PT1H", now.Add(-time.Hour), "<none>"},
+		{"R/20170101T000000/20170101T020000", now.Add(3 * time.Hour), "2017-01-01T04:00:00Z"},
+		{"R2/20170101T000000/20170101T020000", now.Add(time.Hour), "2017-01-01T02:00:00Z"},
+		{"R2/20170101T000000/20170101T020000", now.Add(2 * time.Hour), "<none>"},
+		{"R/PT24H/20170110T000000", now, "2017-01-02T00:00:00Z"},
+		{"R3/PT24H/20170110T000000", now, "2017-01-08T00:00:00Z"},
+		{"R3/PT24H/20170110T000000", now.AddDate(0, 0, 7), "2017-01-09T00:00:00Z"},
+		{"R3/PT24H/20170110T000000", now.AddDate(0, 0, 8), "2017-01-10T00:00:00Z"},
+		{"R3/PT24H/20170110T000000", now.AddDate(0, 0, 9), "<none>"},
+		{"R/PT1H", now, "<none>"},
+	}
+
+	for index, test := range tests {
+		recurrence, err := RecurrenceFromString(test.Rule)
+
+		if err != nil {
+			t.Fatalf("Test %d expected correct rule but got error %#v", index+1, err)
+		}
+
+		result := "<none>"
+
+		if next, ok := recurrence.Next(test.After); ok {
+			result = next.Format(time.RFC3339)
+		}
+
+		if result != test.Expected {
+			t.Errorf("Test %d expected %s but got %s", index+1, test.Expected, result)
+		}
+	}
+}
+
+func TestRecurrenceOccurrences(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		Rule     string
+		From     time.Time
+		To       time.Time
+		Limit    int
+		Expected []string
+	}{
+		{"R/20170101T000000/P1M", now, time.Time{}, 3, []string{"2017-01-01T00:00:00Z", "2017-02-01T00:00:00Z", "2017-03-01T00:00:00Z"}},
+		{"R/20170101T000000/P1M", now.Add(time.Second), now.AddDate(0, 3, 0), 0, []string{"2017-02-01T00:00:00Z", "2017-03-01T00:00:00Z"}},
+		{"R2/20170101T000000/P1M", now, time.Time{}, 0, []string{"2017-01-01T00:00:00Z", "2017-02-01T00:00:00Z"}},
+		{"R/P1W/20170115T000000", now, time.Time{}, 0, []string{"2017-01-01T00:00:00Z", "2017-01-08T00:00:00Z", "2017-01-15T00:00:00Z"}},
+		{"R2/P1W/20170115T000000", now, time.Time{}, 5, []string{"2017-01-08T00:00:00Z", "2017-01-15T00:00:00Z"}},
+		{"R/20170101T000000/20170101T120000", now, now.AddDate(0, 0, 1), 0, []string{"2017-01-01T00:00:00Z", "2017-01-01T12:00:00Z"}},
+		{"R/20170101T000000/PT1H", now, time.Time{}, 0, nil},
+	}
+
+	for index, test := range tests {
+		recurrence, err := RecurrenceFromString(test.Rule)
+
+		if err != nil {
+			t.Fatalf("Test %d expected correct rule but got error %#v", index+1, err)
+		}
+
+		occurrences := recurrence.Occurrences(test.From, test.To, test.Limit)
+
+		if len(occurrences) != len(test.Expected) {
+			t.Errorf("Test %d expected %d occurrences but got %d", index+1, len(test.Expected), len(occurrences))
+			continue
+		}
+
+		for i, occurrence := range occurrences {
+			if result := occurrence.Format(time.RFC3339); result != test.Expected[i] {
+				t.Errorf("Test %d expected occurrence %d to be %s but got %s", index+1, i+1, test.Expected[i], result)
+			}
+		}
+	}
+}
+
+func TestRecurrenceMonthEnd(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		Rule     string
+		Policy   string
+		Expected []string
+	}{
+		{"R5/20170131T090000/P1M", "clamp", []string{"2017-01-31", "2017-02-28", "2017-03-31", "2017-04-30", "2017-05-31"}},
+		{"R5/20170131T090000/P1M", "overflow", []string{"2017-01-31", "2017-03-03", "2017-03-31", "2017-05-01", "2017-05-31"}},
+		{"R5/20170131T090000/P1M", "skip", []string{"2017-01-31", "2017-03-31", "2017-05-31"}},
+		{"R4/20160229T090000/P1Y", "clamp", []string{"2016-02-29", "2017-02-28", "2018-02-28", "2019-02-28"}},
+		{"R3/20170130T090000/P1M1D", "clamp", []string{"2017-01-30", "2017-03-01", "2017-04-01"}},
+		{"R3/P1M/20170331T090000", "clamp", []string{"2017-01-31", "2017-02-28", "2017-03-31"}},
+		{"R3/P1M/20170331T090000", "skip", []string{"2017-01-31", "2017-03-31"}},
+	}
+
+	for index, test := range tests {
+		policy, err := MonthEndPolicyFromString(test.Policy)
+
+		if err != nil {
+			t.Fatalf("Test %d expected correct policy but got error %s", index+1, err)
+		}
+
+		schedule, err := ScheduleFromString(test.Rule, ScheduleOptions{MonthEnd: policy})
+
+		if err != nil {
+			t.Fatalf("Test %d expected correct rule but got error %s", index+1, err)
+		}
+
+		occurrences := schedule.(Recurrence).Occurrences(time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC), time.Time{}, 0)
+		var result []string
+
+		for _, occurrence := range occurrences {
+			result = append(result, occurrence.Format("2006-01-02"))
+		}
+
+		if strings.Join(result, " ") != strings.Join(test.Expected, " ") {
+			t.Errorf("Test %d expected %v but got %v", index+1, test.Expected, result)
+		}
+	}
+
+	if _, err := MonthEndPolicyFromString("round"); err != ErrBadMonthEndPolicy {
+		t.Errorf("Expected %s but got %v", ErrBadMonthEndPolicy, err)
+	}
+}
+
+func TestRecurrenceAnchoredAt(t *testing.T) {
+	t.Parallel()
+
+	recurrence, err := RecurrenceFromString("R/PT30M")
+
+	if err != nil {
+		t.Fatalf("Expected correct rule but got error %#v", err)
+	}
+
+	next, ok := recurrence.AnchoredAt(now).Next(now)
+
+	if !ok {
+		t.Errorf("Expected anchored recurrence to have occurrences")
+	} else if result := next.Format(time.RFC3339); result != "2017-01-01T00:30:00Z" {
+		t.Errorf("Expected %s but got %s", "2017-01-01T00:30:00Z", result)
+	}
+}
+
+func TestRecurrenceInLocation(t *testing.T) {
+	t.Parallel()
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+
+	if err != nil {
+		t.Skipf("No time zone database: %s", err)
+	}
+
+	var tests = []struct {
+		Rule     string
+		Gap      GapPolicy
+		Overlap  OverlapPolicy
+		Expected []string
+	}{
+		// weekly at 09:00 stays at 09:00 local time across both transitions
+		{"R3/20170319T090000/P1W", GapShiftForward, OverlapOnce, []string{"2017-03-19T09:00:00+01:00", "2017-03-26T09:00:00+02:00", "2017-04-02T09:00:00+02:00"}},
+		{"R3/20171022T090000/P1W", GapShiftForward, OverlapOnce, []string{"2017-10-22T09:00:00+02:00", "2017-10-29T09:00:00+01:00", "2017-11-05T09:00:00+01:00"}},
+		// starting on the day of a transition
+		{"R3/20170326T090000/P1D", GapShiftForward, OverlapOnce, []string{"2017-03-26T09:00:00+02:00", "2017-03-27T09:00:00+02:00", "2017-03-28T09:00:00+02:00"}},
+		{"R3/20171029T090000/P1D", GapShiftForward, OverlapOnce, []string{"2017-10-29T09:00:00+01:00", "2017-10-30T09:00:00+01:00", "2017-10-31T09:00:00+01:00"}},
+		// hourly intervals are elapsed time
+		{"R3/20170326T013000/PT1H", GapShiftForward, OverlapOnce, []string{"2017-03-26T01:30:00+01:00", "2017-03-26T03:30:00+02:00", "2017-03-26T04:30:00+02:00"}},
+		// 02:30 does not exist on 26 Mar
+		{"R3/20170319T023000/P1W", GapShiftForward, OverlapOnce, []string{"2017-03-19T02:30:00+01:00", "2017-03-26T03:30:00+02:00", "2017-04-02T02:30:00+02:00"}},
+		{"R3/20170319T023000/P1W", GapSkip, OverlapOnce, []string{"2017-03-19T02:30:00+01:00", "2017-04-02T02:30:00+02:00"}},
+		// 02:30 happens twice on 29 Oct
+		{"R3/20171022T023000/P1W", GapShiftForward, OverlapOnce, []string{"2017-10-22T02:30:00+02:00", "2017-10-29T02:30:00+02:00", "2017-11-05T02:30:00+01:00"}},
+		{"R3/20171022T023000/P1W", GapShiftForward, OverlapTwice, []string{"2017-10-22T02:30:00+02:00", "2017-10-29T02:30:00+02:00", "2017-10-29T02:30:00+01:00", "2017-11-05T02:30:00+01:00"}},
+		// a start within the repeated hour keeps its offset
+		{"R3/2017-10-29T00:30:00Z/PT1H", GapShiftForward, OverlapOnce, []string{"2017-10-29T02:30:00+02:00", "2017-10-29T02:30:00+01:00", "2017-10-29T03:30:00+01:00"}},
+		{"R3/2017-10-29T00:30:00Z/PT30M", GapShiftForward, OverlapOnce, []string{"2017-10-29T02:30:00+02:00", "2017-10-29T02:00:00+01:00", "2017-10-29T02:30:00+01:00"}},
+		{"R3/PT1H/2017-10-29T01:30:00Z", GapShiftForward, OverlapOnce, []string{"2017-10-29T01:30:00+02:00", "2017-10-29T02:30:00+02:00", "2017-10-29T02:30:00+01:00"}},
+		// backwards from the end
+		{"R3/P1W/20170402T023000", GapSkip, OverlapOnce, []string{"2017-03-19T02:30:00+01:00", "2017-04-02T02:30:00+02:00"}},
+	}
+
+	for index, test := range tests {
+		recurrence, err := RecurrenceFromStringIn(test.Rule, berlin)
+
+		if err != nil {
+			t.Fatalf("Test %d expected correct rule but got error %#v", index+1, err)
+		}
+
+		recurrence.DSTGap = test.Gap
+		recurrence.DSTOverlap = test.Overlap
+
+		occurrences := recurrence.Occurrences(time.Date(2017, 1, 1, 0, 0, 0, 0, berlin), time.Time{}, 0)
+
+		if len(occurrences) != len(test.Expected) {
+			t.Errorf("Test %d expected %d occurrences but got %v", index+1, len(test.Expected), occurrences)
+			continue
+		}
+
+		for i, occurrence := range occurrences {
+			if result := occurrence.In(berlin).Format(time.RFC3339); result != test.Expected[i] {
+				t.Errorf("Test %d expected occurrence %d to be %s but got %s", index+1, i+1, test.Expected[i], result)
+			}
+		}
+	}
+}
+
+func TestRecurrenceIntervalString(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		Duration string
+		Expected string
+	}{
+		{"PT1H", "PT1H"},
+		{"PT60M", "PT1H"},
+		{"PT90M", "PT1H30M"},
+		{"PT3600S", "PT1H"},
+		{"PT36H", "PT36H"},
+		{"P14D", "P2W"},
+		{"P1W", "P1W"},
+		{"P24M", "P2Y"},
+		{"P1Y2M3DT4H5M6S", "P1Y2M3DT4H5M6S"},
+		{"PT0.5S", "PT0.5S"},
+		{"PT1,250S", "PT1.25S"},
+		{"PT0.5H", "PT30M"},
+		{"P0001-02-03T04:05:06", "P1Y2M3DT4H5M6S"},
+		{"-P2D", "-P2D"},
+		{"-PT90M", "-PT1H30M"},
+	}
+
+	for index, test := range tests {
+		dur, err := RecurrenceParser{Lenient: true}.Duration(test.Duration)
+
+		if err != nil {
+			t.Fatalf("Test %d expected correct duration but got error %s", index+1, err)
+		}
+
+		if result := dur.String(); result != test.Expected {
+			t.Errorf("Test %d expected %s but got %s", index+1, test.Expected, result)
+		}
+	}
+
+	if result := (RecurrenceInterval{Weeks: 1, Days: 2}).String(); result != "P9D" {
+		t.Errorf("Expected combined weeks to be written as days but got %s", result)
+	}
+
+	if _, err := (Task{Rule: "R/2017-01-01T00:00:00Z/P1W2D"}).Schedule(); err == nil {
+		t.Errorf("Expected combined weeks to be rejected without the task opting in")
+	}
+
+	// the stored form needs no leniency when read back
+	if schedule, err := (Task{Rule: "R/2017-01-01T00:00:00Z/P1W2D", LenientDurations: true}).Schedule(); err != nil || schedule.String() != "R/2017-01-01T00:00:00/P9D" {
+		t.Errorf("Expected R/2017-01-01T00:00:00/P9D but got %v, %v", schedule, err)
+	}
+}
+
+func TestRecurrenceString(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		Rule     string
+		Expected string
+	}{
+		{"R/PT60M", "R/PT1H"},
+		{"R5/19850412T232050/PT60M", "R5/1985-04-12T23:20:50/PT1H"},
+		{"R/1985-04-12T23:20:50.5Z/P1D", "R/1985-04-12T23:20:50.5/P1D"},
+		{"R1/1985-04-12/1986-04-12", "R1/1985-04-12T00:00:00/1986-04-12T00:00:00"},
+		{"R0/P1W/2017-W05-3", "R0/P1W/2017-02-01T00:00:00"},
+		{"R/1985-04-12T23:20:50+02:00/P1D", "R/1985-04-12T21:20:50/P1D"},
+	}
+
+	for index, test := range tests {
+		recurrence, err := RecurrenceFromString(test.Rule)
+
+		if err != nil {
+			t.Fatalf("Test %d expected correct rule but got error %s", index+1, err)
+		}
+
+		result := recurrence.String()
+
+		if result != test.Expected {
+			t.Errorf("Test %d expected %s but got %s", index+1, test.Expected, result)
+		}
+
+		reparsed, err := RecurrenceFromString(result)
+
+		if err != nil {
+			t.Errorf("Test %d expected canonical form to parse but got error %s", index+1, err)
+		} else if reparsed.String() != result {
+			t.Errorf("Test %d expected canonical form to round-trip but got %s", index+1, reparsed.String())
+		}
+	}
+}
+
+func TestRecurrenceJSON(t *testing.T) {
+	t.Parallel()
+
+	var value struct {
+		Rule     Recurrence         `json:"rule"`
+		Interval RecurrenceInterval `json:"interval"`
+	}
+
+	if err := json.Unmarshal([]byte(`{"rule": "R3/20170101T090000/PT60M", "interval": "P7D"}`), &value); err != nil {
+		t.Fatalf("Expected no error but got %s", err)
+	}
+
+	data, err := json.Marshal(value)
+
+	if err != nil {
+		t.Fatalf("Expected no error but got %s", err)
+	}
+
+	expected := `{"rule":"R3/2017-01-01T09:00:00/PT1H","interval":"P1W"}`
+
+	if string(data) != expected {
+		t.Errorf("Expected %s but got %s", expected, data)
+	}
+
+	if err := json.Unmarshal([]byte(`{"rule": "R3/P0D"}`), &value); !errors.Is(err, ErrBadFormat) {
+		t.Errorf("Expected %s but got %v", ErrBadFormat, err)
+	}
+}
+
+func TestRecurrenceIntervalArithmetic(t *testing.T) {
+	t.Parallel()
+
+	hour := RecurrenceInterval{Hours: 1}
+	halfHour := RecurrenceInterval{Minutes: 30}
+
+	var tests = []struct {
+		Result   RecurrenceInterval
+		Expected string
+	}{
+		{hour.Add(halfHour), "PT1H30M"},
+		{halfHour.Scale(3), "PT1H30M"},
+		{RecurrenceInterval{Months: 1, Days: 2}.Scale(6), "P6M12D"},
+		{RecurrenceInterval{Minutes: 90}.Normalize(), "PT1H30M"},
+		{RecurrenceInterval{Months: 14, Weeks: 1, Days: 1}.Normalize(), "P1Y2M8D"},
+		{RecurrenceInterval{Seconds: 59, Nanoseconds: 1500000000}.Normalize(), "PT1M0.5S"},
+	}
+
+	for index, test := range tests {
+		if result := test.Result.String(); result != test.Expected {
+			t.Errorf("Test %d expected %s but got %s", index+1, test.Expected, result)
+		}
+	}
+
+	if result := (RecurrenceInterval{Minutes: 90}).Normalize(); result != (RecurrenceInterval{Hours: 1, Minutes: 30}) {
+		t.Errorf("Expected normalized interval to have 1 hour and 30 minutes but got %+v", result)
+	}
+
+	if result := hour.Negate(); result.Hours != -1 || result.Add(hour) != (RecurrenceInterval{}) {
+		t.Errorf("Expected negated interval to cancel out but got %+v", result)
+	}
+
+	if !(RecurrenceInterval{}).IsZero() || hour.IsZero() {
+		t.Errorf("Expected only the empty interval to be zero")
+	}
+}
+
+func TestRecurrenceIntervalApprox(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		Duration RecurrenceInterval
+		Expected time.Duration
+	}{
+		{RecurrenceInterval{Minutes: 1}, time.Minute},
+		{RecurrenceInterval{Weeks: 1, Days: 1}, 8 * 24 * time.Hour},
+		{RecurrenceInterval{Years: 100}, 36524*24*time.Hour + 6*time.Hour},
+		{RecurrenceInterval{Months: 12}, 365*24*time.Hour + 5*time.Hour + 49*time.Minute + 12*time.Second},
+	}
+
+	for index, test := range tests {
+		if result := test.Duration.Approx(); result != test.Expected {
+			t.Errorf("Test %d expected %s but got %s", index+1, test.Expected, result)
+		}
+	}
+}
+
+func TestRecurrenceIntervalCompareAt(t *testing.T) {
+	t.Parallel()
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	month := RecurrenceInterval{Months: 1}
+	thirtyDays := RecurrenceInterval{Days: 30}
+
+	var tests = []struct {
+		Duration RecurrenceInterval
+		Other    RecurrenceInterval
+		Anchor   time.Time
+		Expected int
+	}{
+		{month, thirtyDays, time.Date(2017, 2, 1, 0, 0, 0, 0, time.UTC), -1},
+		{month, thirtyDays, time.Date(2017, 3, 1, 0, 0, 0, 0, time.UTC), 1},
+		{month, thirtyDays, time.Date(2017, 4, 1, 0, 0, 0, 0, time.UTC), 0},
+		{RecurrenceInterval{Minutes: 90}, RecurrenceInterval{Hours: 1, Minutes: 30}, now, 0},
+		{RecurrenceInterval{Seconds: 59}, RecurrenceInterval{Minutes: 1}, now, -1},
+		{RecurrenceInterval{Days: 1}, RecurrenceInterval{Hours: 24}, time.Date(2017, 3, 26, 0, 0, 0, 0, berlin), -1},
+	}
+
+	for index, test := range tests {
+		if result := test.Duration.CompareAt(test.Other, test.Anchor); result != test.Expected {
+			t.Errorf("Test %d expected %d but got %d", index+1, test.Expected, result)
+		}
+	}
+
+	if result := month.DurationAt(time.Date(2017, 1, 31, 0, 0, 0, 0, time.UTC)); result != 28*24*time.Hour {
+		t.Errorf("Expected a month from 31 January to be clamped to 28 days but got %s", result)
+	}
+}
+
+func TestRecurrenceSeek(t *testing.T) {
+	t.Parallel()
+
+	after := time.Date(2017, 6, 15, 12, 34, 56, 0, time.UTC)
+
+	var tests = []struct {
+		Rule      string
+		After     time.Time
+		Expected  string
+		Remaining int
+	}{
+		{"R/2000-01-01T00:00:00Z/PT1M", after, "2017-06-15T12:35:00Z", -1},
+		{"R10000000/2000-01-01T00:00:00Z/PT1M", after, "2017-06-15T12:35:00Z", 819245},
+		{"R100/2000-01-01T00:00:00Z/PT1M", after, "", 0},
+		{"R/2000-01-01T00:00:00Z/2000-01-01T00:01:00Z", after, "2017-06-15T12:35:00Z", -1},
+		{"R/2000-01-01T00:00:00Z/P1M", after, "2017-07-01T00:00:00Z", -1},
+		{"R300/2000-01-01T00:00:00Z/P1M", after, "2017-07-01T00:00:00Z", 90},
+		{"R/PT1M/2017-06-15T12:40:00Z", after, "2017-06-15T12:35:00Z", 6},
+		{"R/PT1S/2027-01-01T00:00:00Z", after, "2017-06-15T12:34:57Z", 301231504},
+		{"R3/PT1S/2027-01-01T00:00:00Z", after, "2026-12-31T23:59:58Z", 3},
+		{"R/PT1S/2017-06-15T12:34:56Z", after, "", 0},
+		{"R0/PT1S/2027-01-01T00:00:00Z", after, "", 0},
+		{"R/P1M/2030-01-31T00:00:00Z", after, "2017-06-30T00:00:00Z", 152},
+	}
+
+	for index, test := range tests {
+		recurrence, err := RecurrenceFromString(test.Rule)
+
+		if err != nil {
+			t.Errorf("Test %d expected correct rule but got error %s", index+1, err)
+			continue
+		}
+
+		it := recurrence.Iterator(test.After)
+		next, ok := it.Next()
+
+		if test.Expected == "" && ok {
+			t.Errorf("Test %d expected no occurrence but got %s", index+1, next)
+		} else if test.Expected != "" && (!ok || next.Format(time.RFC3339) != test.Expected) {
+			t.Errorf("Test %d expected %s but got %s", index+1, test.Expected, next)
+		} else if ok && it.Remaining() != test.Remaining {
+			t.Errorf("Test %d expected %d repetitions left but got %d", index+1, test.Remaining, it.Remaining())
+		}
+	}
+}
+
+func TestRecurrenceSeekCalendar(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		Rule     string
+		TimeZone string
+		MonthEnd MonthEndPolicy
+		DSTGap   GapPolicy
+		Overlap  OverlapPolicy
+	}{
+		{"R/2000-01-31T10:00:00/P1M", "", MonthEndClamp, GapShiftForward, OverlapOnce},
+		{"R/2000-01-31T10:00:00/P1M", "", MonthEndSkip, GapShiftForward, OverlapOnce},
+		{"R/2000-01-31T10:00:00/P1M", "", MonthEndOverflow, GapShiftForward, OverlapOnce},
+		{"R/2000-02-29T00:00:00/P1Y", "", MonthEndSkip, GapShiftForward, OverlapOnce},
+		{"R/2000-01-01T00:00:00/P1DT1H", "", MonthEndClamp, GapShiftForward, OverlapOnce},
+		{"R/2000-03-26T02:30:00/P1D", "Europe/Berlin", MonthEndClamp, GapSkip, OverlapOnce},
+		{"R/2000-10-29T02:30:00/P1W", "Europe/Berlin", MonthEndClamp, GapShiftForward, OverlapTwice},
+		{"R200/2000-01-31T00:00:00/P1M", "America/New_York", MonthEndClamp, GapShiftForward, OverlapOnce},
+	}
+
+	afters := []time.Time{
+		time.Date(1999, 12, 31, 0, 0, 0, 0, time.UTC),
+		time.Date(2017, 2, 28, 12, 0, 0, 0, time.UTC),
+		time.Date(2017, 3, 26, 0, 15, 0, 0, time.UTC),
+		time.Date(2017, 10, 29, 0, 45, 0, 0, time.UTC),
+		time.Date(2016, 2, 29, 0, 0, 0, 0, time.UTC),
+		time.Date(2016, 8, 31, 23, 59, 59, 0, time.UTC),
+	}
+
+	for index, test := range tests {
+		loc, _ := LocationFromString(test.TimeZone)
+		recurrence, err := RecurrenceFromStringIn(test.Rule, loc)
+
+		if err != nil {
+			t.Errorf("Test %d expected correct rule but got error %s", index+1, err)
+			continue
+		}
+
+		recurrence.MonthEnd, recurrence.DSTGap, recurrence.DSTOverlap = test.MonthEnd, test.DSTGap, test.Overlap
+
+		for _, after := range afters {
+			it := recurrence.Iterator(after)
+			next, ok := it.Next()
+			expected, remaining, expectedOk := walkNext(recurrence, after)
+
+			if ok != expectedOk || !next.Equal(expected) {
+				t.Errorf("Test %d expected %s after %s but got %s", index+1, expected, after, next)
+			} else if ok && it.Remaining() != remaining {
+				t.Errorf("Test %d expected %d repetitions left after %s but got %d", index+1, remaining, after, it.Remaining())
+			}
+		}
+	}
+}
+
+func TestRecurrenceSeekBackward(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		Rule     string
+		TimeZone string
+		MonthEnd MonthEndPolicy
+		DSTGap   GapPolicy
+		Overlap  OverlapPolicy
+	}{
+		{"R/P1M/2030-01-31T10:00:00", "", MonthEndClamp, GapShiftForward, OverlapOnce},
+		{"R/P1M/2030-01-31T10:00:00", "", MonthEndSkip, GapShiftForward, OverlapOnce},
+		{"R/P1Y/2030-02-28T00:00:00", "", MonthEndClamp, GapShiftForward, OverlapOnce},
+		{"R/P1DT1H/2030-01-01T00:00:00", "", MonthEndClamp, GapShiftForward, OverlapOnce},
+		{"R/PT1H/2030-01-01T00:00:00", "", MonthEndClamp, GapShiftForward, OverlapOnce},
+		{"R/P1D/2030-03-31T02:30:00", "Europe/Berlin", MonthEndClamp, GapSkip, OverlapOnce},
+		{"R/P1W/2030-10-27T02:30:00", "Europe/Berlin", MonthEndClamp, GapShiftForward, OverlapTwice},
+		{"R200/P1M/2020-01-31T00:00:00", "America/New_York", MonthEndClamp, GapShiftForward, OverlapOnce},
+	}
+
+	afters := []time.Time{
+		time.Date(1999, 12, 31, 0, 0, 0, 0, time.UTC),
+		time.Date(2017, 2, 28, 12, 0, 0, 0, time.UTC),
+		time.Date(2017, 3, 26, 0, 15, 0, 0, time.UTC),
+		time.Date(2017, 10, 29, 0, 45, 0, 0, time.UTC),
+		time.Date(2016, 2, 29, 0, 0, 0, 0, time.UTC),
+		time.Date(2029, 12, 31, 23, 59, 59, 0, time.UTC),
+		time.Date(2031, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	for index, test := range tests {
+		loc, _ := LocationFromString(test.TimeZone)
+		recurrence, err := RecurrenceFromStringIn(test.Rule, loc)
+
+		if err != nil {
+			t.Errorf("Test %d expected correct rule but got error %s", index+1, err)
+			continue
+		}
+
+		recurrence.MonthEnd, recurrence.DSTGap, recurrence.DSTOverlap = test.MonthEnd, test.DSTGap, test.Overlap
+
+		for _, after := range afters {
+			it := recurrence.Iterator(after)
+			next, ok := it.Next()
+			expected, remaining, expectedOk := walkBack(recurrence, after)
+
+			if ok != expectedOk || !next.Equal(expected) {
+				t.Errorf("Test %d expected %s after %s but got %s", index+1, expected, after, next)
+			} else if ok && it.Remaining() != remaining {
+				t.Errorf("Test %d expected %d repetitions left after %s but got %d", index+1, remaining, after, it.Remaining())
+			}
+		}
+	}
+}
+
+// walkBack finds the first occurrence after the given time of a series
+// running backwards slot by slot from the end, with the repetitions left.
+func walkBack(r Recurrence, after time.Time) (time.Time, int, bool) {
+	anchor := r.wall(*r.End)
+	first, remaining, found := time.Time{}, 0, false
+
+	for k := 0; r.Repetitions < 0 || k < r.Repetitions; k++ {
+		instants := r.slot(anchor, -k)
+
+		if len(instants) > 0 && !instants[len(instants)-1].After(after) {
+			break
+		}
+
+		for i := len(instants) - 1; i >= 0; i-- {
+			if instants[i].After(after) {
+				first, remaining, found = instants[i], k+1, true
+			}
+		}
+	}
+
+	return first, remaining, found
+}
+
+// walkNext finds the first occurrence after the given time slot by slot from
+// the start, with the repetitions left.
+func walkNext(r Recurrence, after time.Time) (time.Time, int, bool) {
+	anchor := r.wall(*r.Start)
+
+	for k := 0; r.Repetitions < 0 || k < r.Repetitions; k++ {
+		for _, instant := range r.slot(anchor, k) {
+			if !instant.After(after) {
+				continue
+			}
+
+			if r.Repetitions < 0 {
+				return instant, -1, true
+			}
+
+			return instant, r.Repetitions - k, true
+		}
+	}
+
+	return time.Time{}, 0, false
+}
+
+func BenchmarkRecurrenceSeekBackward(b *testing.B) {
+	recurrence, _ := RecurrenceFromString("R/PT1S/2027-01-01T00:00:00Z")
+	after := time.Date(2017, 6, 15, 12, 34, 56, 0, time.UTC)
+
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		recurrence.Next(after)
+	}
+}
+
+func BenchmarkRecurrenceSeek(b *testing.B) {
+	recurrence, _ := RecurrenceFromString("R/2000-01-01T00:00:00Z/P1D")
+	after := time.Date(2017, 6, 15, 12, 34, 56, 0, time.UTC)
+
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		recurrence.Next(after)
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+// maxRollDays bounds the search for a working day, an occurrence with none
+// within that many days is skipped.
+const maxRollDays = 31
+
+var (
+	//ErrBadRollConvention unknown roll convention
+	ErrBadRollConvention = errors.New("bad roll convention")
+)
+
+// RollConvention tells where an occurrence falling on a non-working day of a
+// calendar moves to.
+type RollConvention int
+
+const (
+	// RollFollowing moves the occurrence to the next working day.
+	RollFollowing RollConvention = iota
+	// RollModifiedFollowing moves the occurrence to the next working day
+	// unless that is in the next month, and to the previous one then.
+	RollModifiedFollowing
+	// RollPreceding moves the occurrence to the previous working day.
+	RollPreceding
+	// RollSkip drops the occurrence.
+	RollSkip
+)
+
+// RollConventionFromString parses "following", "modified-following",
+// "preceding" or "skip", an empty string means following.
+func RollConventionFromString(convention string) (RollConvention, error) {
+	switch convention {
+	case "", "following":
+		return RollFollowing, nil
+	case "modified-following":
+		return RollModifiedFollowing, nil
+	case "preceding":
+		return RollPreceding, nil
+	case "skip":
+		return RollSkip, nil
+	}
+
+	return RollFollowing, ErrBadRollConvention
+}
+
+// RolledSchedule moves the occurrences of another schedule off the
+// non-working days of a calendar, keeping their wall clock time in the
+// location. Occurrences rolled onto the same time fire once. Jitter is the
+// delay a JitteredSchedule around this one adds, occurrences are rolled by
+// the day they fire on once delayed.
+type RolledSchedule struct {
+	Base     Schedule
+	Calendar *Calendar
+	Roll     RollConvention
+	Location *time.Location
+	Jitter   time.Duration
+}
+
+// Next returns the earliest rolled occurrence strictly after the given time,
+// or false when the base schedule runs out or none is found within
+// horizonYears years.
+func (s RolledSchedule) Next(after time.Time) (time.Time, bool) {
+	next, _, ok := s.next(after)
+
+	return next, ok
+}
+
+// next returns the earliest rolled occurrence strictly after the given time
+// and the last base occurrence rolled onto it. Rolling can reorder
+// occurrences, so base occurrences are looked at until none of the following
+// ones can roll before the earliest found.
+func (s RolledSchedule) next(after time.Time) (next time.Time, base time.Time, ok bool) {
+	// occurrences are rolled and compared delayed by the jitter
+	after = after.Add(s.Jitter)
+	horizon := after.AddDate(horizonYears, 0, 0)
+	from := s.searchFrom(after).Add(-s.Jitter)
+
+	var bound time.Time
+
+	for {
+		occurrence, found := s.Base.Next(from)
+		delayed := occurrence.Add(s.Jitter)
+
+		if !found || delayed.After(horizon) || ok && delayed.After(bound) {
+			return next.Add(-s.Jitter), base, ok
+		}
+
+		if rolled, rolledOK := s.roll(delayed); rolledOK && rolled.After(after) && (!ok || !rolled.After(next)) {
+			next, base, ok = rolled, occurrence, true
+			bound = s.rollBound(rolled)
+		}
+
+		from = occurrence
+	}
+}
+
+// rollBound returns the time from which base occurrences roll no earlier
+// than t. Rolling forward never moves an occurrence back, while rolling
+// backward moves it at most to the working day before its own, so past the
+// first working day after t's day.
+func (s RolledSchedule) rollBound(t time.Time) time.Time {
+	if s.Roll != RollPreceding && s.Roll != RollModifiedFollowing {
+		return t
+	}
+
+	t = t.In(s.location())
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+
+	for i := 1; i <= maxRollDays; i++ {
+		if next := day.AddDate(0, 0, i); s.Calendar.IsWorkingDay(next) {
+			return next
+		}
+	}
+
+	return day.AddDate(0, 0, maxRollDays+1)
+}
+
+// searchFrom returns the time base occurrences are looked up after: the
+// start of the run of non-working days just before the day of after, as
+// those may roll past it.
+func (s RolledSchedule) searchFrom(after time.Time) time.Time {
+	t := after.In(s.location())
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+
+	for i := 0; i < maxRollDays && !s.Calendar.IsWorkingDay(day.AddDate(0, 0, -1)); i++ {
+		day = day.AddDate(0, 0, -1)
+	}
+
+	return day.Add(-time.Nanosecond)
+}
+
+// roll moves a base occurrence to a working day by the convention.
+func (s RolledSchedule) roll(t time.Time) (time.Time, bool) {
+	t = t.In(s.location())
+
+	if s.Calendar.IsWorkingDay(t) {
+		return t, true
+	}
+
+	switch s.Roll {
+	case RollSkip:
+		return time.Time{}, false
+	case RollPreceding:
+		return s.workingDay(t, -1)
+	case RollModifiedFollowing:
+		if next, ok := s.workingDay(t, 1); ok && next.Month() == t.Month() {
+			return next, true
+		}
+
+		return s.workingDay(t, -1)
+	}
+
+	return s.workingDay(t, 1)
+}
+
+// workingDay steps days from t in the given direction until a working day.
+func (s RolledSchedule) workingDay(t time.Time, step int) (time.Time, bool) {
+	for i := 1; i <= maxRollDays; i++ {
+		if day := t.AddDate(0, 0, i*step); s.Calendar.IsWorkingDay(day) {
+			return day, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// remaining returns the repetitions left of the base schedule counting the
+// last base occurrence rolled onto t, see scheduleRemaining.
+func (s RolledSchedule) remaining(t time.Time) int {
+	if _, base, ok := s.next(t.Add(-time.Nanosecond)); ok {
+		t = base
+	}
+
+	return scheduleRemaining(s.Base, t)
+}
+
+func (s RolledSchedule) location() *time.Location {
+	if s.Location == nil {
+		return time.UTC
+	}
+
+	return s.Location
+}
+
+// String returns the canonical base rule, the calendar and convention are
+// task settings.
+func (s RolledSchedule) String() string {
+	return s.Base.String()
+}
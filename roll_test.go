@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRolledScheduleNext(t *testing.T) {
+	t.Parallel()
+
+	calendar, err := NewCalendar("test-roll", nil, []string{"2017-01-02", "2017-03-31", "2017-04-14", "2017-04-17", "2017-12-25", "2017-12-26"})
+
+	if err != nil {
+		t.Fatalf("Expected correct calendar but got error %s", err)
+	}
+
+	RegisterCalendar(calendar)
+
+	var tests = []struct {
+		Rule     string
+		TimeZone string
+		Roll     string
+		After    time.Time
+		Expected []string
+	}{
+		// Sunday 1 and the holiday on Monday 2 January both move to Tuesday
+		{"0 9 * * *", "", "", now, []string{"2017-01-03T09:00:00Z", "2017-01-04T09:00:00Z", "2017-01-05T09:00:00Z"}},
+		// the Easter holidays move past after, which falls within them
+		{"R/2017-04-14T09:00:00/P1D", "Europe/Berlin", "following", time.Date(2017, 4, 17, 0, 0, 0, 0, time.UTC), []string{"2017-04-18T07:00:00Z", "2017-04-19T07:00:00Z", "2017-04-20T07:00:00Z"}},
+		// the holiday on 31 March and Sunday 30 April would leave their month
+		{"0 9 L * *", "", "modified-following", time.Date(2017, 3, 1, 0, 0, 0, 0, time.UTC), []string{"2017-03-30T09:00:00Z", "2017-04-28T09:00:00Z", "2017-05-31T09:00:00Z"}},
+		// Sunday 1 January moves before after and Saturday 1 April to
+		// Thursday, Friday being a holiday
+		{"0 9 1 * *", "", "preceding", now, []string{"2017-02-01T09:00:00Z", "2017-03-01T09:00:00Z", "2017-03-30T09:00:00Z"}},
+		{"FREQ=MONTHLY;BYMONTHDAY=25;BYHOUR=9", "", "skip", time.Date(2017, 11, 1, 0, 0, 0, 0, time.UTC), []string{"2018-01-25T09:00:00Z", "2018-04-25T09:00:00Z", "2018-05-25T09:00:00Z"}},
+		// two business days before 27 December skip the holidays and the
+		// weekend before them
+		{"OFFSET;DAYS=BUSINESS:-P2D 0 18 27 DEC *", "", "", now, []string{"2017-12-21T18:00:00Z"}},
+	}
+
+	for index, test := range tests {
+		schedule, err := Task{Rule: test.Rule, TimeZone: test.TimeZone, Calendar: "test-roll", Roll: test.Roll}.Schedule()
+
+		if err != nil {
+			t.Fatalf("Test %d expected correct rule but got error %s", index+1, err)
+		}
+
+		result := nextOccurrences(schedule, test.After, len(test.Expected))
+
+		if strings.Join(result, " ") != strings.Join(test.Expected, " ") {
+			t.Errorf("Test %d expected %v but got %v", index+1, test.Expected, result)
+		}
+	}
+}
+
+func TestRolledScheduleSubDay(t *testing.T) {
+	t.Parallel()
+
+	calendar, err := NewCalendar("test-roll-subday", nil, []string{"2017-06-05"})
+
+	if err != nil {
+		t.Fatalf("Expected correct calendar but got error %s", err)
+	}
+
+	RegisterCalendar(calendar)
+
+	var tests = []struct {
+		Roll     string
+		Expected []string
+	}{
+		// the weekend and the holiday on Monday 5 June move onto the
+		// occurrences of Tuesday 6 June, the one at 8:00 firing once
+		{"following", []string{"2017-06-02T08:00:00Z", "2017-06-06T00:00:00Z", "2017-06-06T08:00:00Z", "2017-06-06T16:00:00Z", "2017-06-07T00:00:00Z"}},
+		// and onto Friday 2 June, before its first occurrence
+		{"preceding", []string{"2017-06-02T00:00:00Z", "2017-06-02T08:00:00Z", "2017-06-02T16:00:00Z", "2017-06-06T08:00:00Z", "2017-06-07T00:00:00Z"}},
+	}
+
+	for index, test := range tests {
+		schedule, err := Task{Rule: "R/2017-06-02T08:00:00/PT16H", Calendar: "test-roll-subday", Roll: test.Roll}.Schedule()
+
+		if err != nil {
+			t.Fatalf("Test %d expected correct rule but got error %s", index+1, err)
+		}
+
+		result := nextOccurrences(schedule, time.Date(2017, 6, 1, 20, 0, 0, 0, time.UTC), len(test.Expected))
+
+		if strings.Join(result, " ") != strings.Join(test.Expected, " ") {
+			t.Errorf("Test %d expected %v but got %v", index+1, test.Expected, result)
+		}
+	}
+}
+
+func TestRolledScheduleErrors(t *testing.T) {
+	t.Parallel()
+
+	calendar, _ := NewCalendar("test-roll-errors", nil, nil)
+	RegisterCalendar(calendar)
+
+	if _, err := (Task{Rule: "0 9 * * *", Calendar: "missing"}).Schedule(); err != ErrUnknownCalendar {
+		t.Errorf("Expected %s but got %v", ErrUnknownCalendar, err)
+	}
+
+	if _, err := (Task{Rule: "0 9 * * *", Calendar: "test-roll-errors", Roll: "backward"}).Schedule(); err != ErrBadRollConvention {
+		t.Errorf("Expected %s but got %v", ErrBadRollConvention, err)
+	}
+
+	var parseErr *ParseError
+
+	if _, err := (Task{Rule: "R/P1D", Calendar: "test-roll-errors"}).Schedule(); !errors.As(err, &parseErr) || parseErr.Reason != ReasonUnanchoredRoll {
+		t.Errorf("Expected %s but got %v", ReasonUnanchoredRoll, err)
+	}
+}
@@ -0,0 +1,799 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// maxEmptyPeriods bounds the search for rules that almost never produce
+	// an occurrence.
+	maxEmptyPeriods = 1 << 20
+	// horizonYears bounds the search for rules that never produce an
+	// occurrence, such as FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30. The Gregorian
+	// calendar repeats itself every 400 years.
+	horizonYears = 400
+)
+
+var (
+	byDayRegexp = regexp.MustCompile(`^([+-]?\d{1,2})?(MO|TU|WE|TH|FR|SA|SU)$`)
+
+	basicDateTimeFormat = "20060102T150405"
+	basicUTCFormat      = "20060102T150405Z"
+
+	weekdayNames = [...]string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+	weekdays = map[string]time.Weekday{
+		"SU": time.Sunday,
+		"MO": time.Monday,
+		"TU": time.Tuesday,
+		"WE": time.Wednesday,
+		"TH": time.Thursday,
+		"FR": time.Friday,
+		"SA": time.Saturday,
+	}
+
+	frequencies = map[string]Frequency{
+		"YEARLY":   Yearly,
+		"MONTHLY":  Monthly,
+		"WEEKLY":   Weekly,
+		"DAILY":    Daily,
+		"HOURLY":   Hourly,
+		"MINUTELY": Minutely,
+	}
+)
+
+// Frequency is the FREQ part of an RRULE.
+type Frequency int
+
+const (
+	Yearly Frequency = iota
+	Monthly
+	Weekly
+	Daily
+	Hourly
+	Minutely
+)
+
+// WeekdayNum is a BYDAY entry such as MO or -1FR, N is 0 without ordinal.
+type WeekdayNum struct {
+	Weekday time.Weekday
+	N       int
+}
+
+// RRule is an RFC 5545 recurrence rule together with its DTSTART. Count is 0
+// for rules without COUNT.
+type RRule struct {
+	Freq       Frequency
+	Interval   int
+	Count      int
+	Until      *time.Time
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	ByMonth    []int
+	ByHour     []int
+	ByMinute   []int
+	BySetPos   []int
+	Wkst       time.Weekday
+	Start      time.Time
+	Location   *time.Location
+	DSTGap     GapPolicy
+	DSTOverlap OverlapPolicy
+}
+
+// isRRule tells whether the rule is written in RFC 5545 syntax rather than
+// as an ISO 8601 repeating interval.
+func isRRule(rule string) bool {
+	upper := strings.ToUpper(strings.TrimSpace(rule))
+
+	return strings.HasPrefix(upper, "RRULE:") || strings.HasPrefix(upper, "DTSTART") || strings.HasPrefix(upper, "FREQ=")
+}
+
+// RRuleFromString parses an RRULE optionally preceded by a DTSTART line, e.g.
+//
+//	DTSTART;TZID=Europe/Berlin:20170101T090000
+//	RRULE:FREQ=MONTHLY;BYDAY=-1FR
+//
+// Lines may also be separated by spaces. Floating dates are taken in the
+// given location, and without DTSTART the rule starts at midnight of
+// 1 January 1970 there, so COUNT needs a DTSTART not to be used up long ago.
+func RRuleFromString(rule string, loc *time.Location) (RRule, error) {
+	r := RRule{Interval: 1, Wkst: time.Monday, Location: loc}
+	hasStart := false
+	parts := ""
+
+	for _, line := range strings.Fields(rule) {
+		upper := strings.ToUpper(line)
+
+		switch {
+		case strings.HasPrefix(upper, "DTSTART") && !hasStart:
+			start, startLoc, err := rruleStartFromString(line, loc)
+
+			if err != nil {
+				return RRule{}, err
+			}
+
+			r.Start, r.Location, hasStart = start, startLoc, true
+		case strings.HasPrefix(upper, "RRULE:") && parts == "":
+			parts = upper[len("RRULE:"):]
+		case strings.HasPrefix(upper, "FREQ=") && parts == "":
+			parts = upper
+		default:
+			return RRule{}, ErrBadFormat
+		}
+	}
+
+	if !hasStart {
+		r.Start = time.Date(1970, 1, 1, 0, 0, 0, 0, r.Location)
+	}
+
+	if err := r.parseParts(parts); err != nil {
+		return RRule{}, err
+	}
+
+	if !hasStart && r.Count > 0 {
+		offset := strings.Index(strings.ToUpper(rule), "COUNT=")
+		token := rule[offset:]
+
+		if end := strings.IndexAny(token, "; \t\r\n"); end >= 0 {
+			token = token[:end]
+		}
+
+		return RRule{}, newParseError(offset, token, ReasonUnanchoredCount)
+	}
+
+	return r, nil
+}
+
+// rruleStartFromString parses DTSTART:20170101T090000[Z] or
+// DTSTART;TZID=Europe/Berlin:20170101T090000.
+func rruleStartFromString(line string, loc *time.Location) (time.Time, *time.Location, error) {
+	colon := strings.LastIndex(line, ":")
+
+	if colon < 0 {
+		return time.Time{}, nil, ErrBadFormat
+	}
+
+	params, value := strings.Split(line[:colon], ";"), line[colon+1:]
+
+	if strings.ToUpper(params[0]) != "DTSTART" {
+		return time.Time{}, nil, ErrBadFormat
+	}
+
+	for _, param := range params[1:] {
+		if !strings.HasPrefix(strings.ToUpper(param), "TZID=") {
+			return time.Time{}, nil, ErrBadFormat
+		}
+
+		tzid, err := LocationFromString(param[len("TZID="):])
+
+		if err != nil {
+			return time.Time{}, nil, err
+		}
+
+		loc = tzid
+	}
+
+	start, err := DateFromStringIn(value, loc)
+
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+
+	return start.In(loc), loc, nil
+}
+
+func (r *RRule) parseParts(parts string) error {
+	seen := map[string]bool{}
+
+	for _, part := range strings.Split(parts, ";") {
+		pair := strings.SplitN(part, "=", 2)
+
+		if len(pair) != 2 || seen[pair[0]] {
+			return ErrBadFormat
+		}
+
+		name, value := pair[0], pair[1]
+		seen[name] = true
+
+		var err error
+
+		switch name {
+		case "FREQ":
+			freq, ok := frequencies[value]
+
+			if !ok {
+				return ErrBadFormat
+			}
+
+			r.Freq = freq
+		case "INTERVAL":
+			r.Interval, err = rruleIntFromString(value, 1, 1<<20)
+		case "COUNT":
+			r.Count, err = rruleIntFromString(value, 1, 1<<30)
+		case "UNTIL":
+			var until time.Time
+
+			until, err = DateFromStringIn(value, r.Location)
+			r.Until = &until
+		case "BYDAY":
+			r.ByDay, err = byDayFromString(value)
+		case "BYMONTHDAY":
+			r.ByMonthDay, err = rruleIntsFromString(value, -31, 31)
+		case "BYMONTH":
+			r.ByMonth, err = rruleIntsFromString(value, 1, 12)
+		case "BYHOUR":
+			r.ByHour, err = rruleIntsFromString(value, 0, 23)
+		case "BYMINUTE":
+			r.ByMinute, err = rruleIntsFromString(value, 0, 59)
+		case "BYSETPOS":
+			r.BySetPos, err = rruleIntsFromString(value, -366, 366)
+		case "WKST":
+			wkst, ok := weekdays[value]
+
+			if !ok {
+				return ErrBadFormat
+			}
+
+			r.Wkst = wkst
+		default:
+			return ErrBadFormat
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if !seen["FREQ"] || (seen["COUNT"] && seen["UNTIL"]) {
+		return ErrBadFormat
+	}
+
+	for _, day := range r.ByDay {
+		if day.N != 0 && r.Freq != Monthly && r.Freq != Yearly {
+			return ErrBadFormat
+		}
+	}
+
+	return nil
+}
+
+func rruleIntFromString(value string, min, max int) (int, error) {
+	val, err := strconv.Atoi(value)
+
+	if err != nil || val < min || val > max {
+		return 0, ErrBadFormat
+	}
+
+	return val, nil
+}
+
+// rruleIntsFromString parses a comma separated list, zero is never allowed
+// unless it is the lower bound.
+func rruleIntsFromString(value string, min, max int) ([]int, error) {
+	var vals []int
+
+	for _, item := range strings.Split(value, ",") {
+		val, err := rruleIntFromString(item, min, max)
+
+		if err != nil || (val == 0 && min != 0) {
+			return nil, ErrBadFormat
+		}
+
+		vals = append(vals, val)
+	}
+
+	return vals, nil
+}
+
+func byDayFromString(value string) ([]WeekdayNum, error) {
+	var days []WeekdayNum
+
+	for _, item := range strings.Split(value, ",") {
+		match := byDayRegexp.FindStringSubmatch(item)
+
+		if match == nil {
+			return nil, ErrBadFormat
+		}
+
+		day := WeekdayNum{Weekday: weekdays[match[2]]}
+
+		if match[1] != "" {
+			n, err := strconv.Atoi(match[1])
+
+			if err != nil || n == 0 || n < -53 || n > 53 {
+				return nil, ErrBadFormat
+			}
+
+			day.N = n
+		}
+
+		days = append(days, day)
+	}
+
+	return days, nil
+}
+
+func (r RRule) location() *time.Location {
+	if r.Location == nil {
+		return time.UTC
+	}
+
+	return r.Location
+}
+
+// Next returns the earliest occurrence strictly after the given time, or
+// false when there is none. Around a DST transition a later wall time may
+// happen first, such as 2:15 of the first pass through a repeated hour after
+// 2:00 of the second, so the walls within the shift of the clocks are all
+// looked at.
+func (r RRule) Next(after time.Time) (time.Time, bool) {
+	loc := r.location()
+	start := floating(r.Start.In(loc))
+	k, count, empty := 0, 0, 0
+
+	var (
+		best  time.Time
+		found bool
+		bound time.Time
+	)
+
+	horizon := start
+
+	// earlier wall times may happen again after a clock set back or be
+	// shifted past after out of a gap
+	if wall := floating(after.In(loc)).Add(-dstShift(after, loc)); wall.After(start) {
+		horizon = wall
+
+		if r.Count == 0 {
+			k = r.units(start, wall)/r.Interval - 1
+		}
+	}
+
+	horizon = horizon.AddDate(horizonYears, 0, 0)
+
+	if k < 0 {
+		k = 0
+	}
+
+	var until time.Time
+
+	if r.Until != nil {
+		until = floating(r.Until.In(loc))
+	}
+
+	for ; empty < maxEmptyPeriods; k++ {
+		period := r.period(start, k)
+
+		if period.After(horizon) || (r.Until != nil && period.After(until)) || found && period.After(bound) {
+			return best, found
+		}
+
+		if r.Freq >= Hourly && !r.limits(period) {
+			// jump to the first period of the next day
+			midnight := time.Date(period.Year(), period.Month(), period.Day()+1, 0, 0, 0, 0, time.UTC)
+
+			if next := (r.units(start, midnight)+r.Interval-1)/r.Interval - 1; next > k {
+				k = next
+			}
+
+			empty++
+			continue
+		}
+
+		walls := r.expand(period, start)
+
+		if len(walls) == 0 {
+			empty++
+			continue
+		}
+
+		empty = 0
+
+		for _, wall := range walls {
+			if wall.Before(start) {
+				continue
+			}
+
+			if found && wall.After(bound) {
+				return best, true
+			}
+
+			if count++; r.Count > 0 && count > r.Count {
+				return best, found
+			}
+
+			for _, t := range resolveWallTime(wall, loc, r.DSTGap, r.DSTOverlap) {
+				if r.Until != nil && t.After(*r.Until) {
+					continue
+				}
+
+				if t.After(after) && (!found || t.Before(best)) {
+					best, found = t, true
+					// wall times further on happen after it
+					bound = floating(t.In(loc)).Add(dstShift(t, loc))
+				}
+			}
+		}
+	}
+
+	return best, found
+}
+
+// units returns the number of whole frequency units from the start of the
+// first period up to the floating time t.
+func (r RRule) units(start, t time.Time) int {
+	switch r.Freq {
+	case Yearly:
+		return t.Year() - start.Year()
+	case Monthly:
+		return (t.Year()-start.Year())*12 + int(t.Month()) - int(start.Month())
+	case Weekly:
+		return daysBetween(r.weekStart(start), t) / 7
+	case Daily:
+		return daysBetween(time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC), t)
+	case Hourly:
+		return int(t.Sub(start.Truncate(time.Hour)) / time.Hour)
+	default:
+		return int(t.Sub(start.Truncate(time.Minute)) / time.Minute)
+	}
+}
+
+// period returns the floating start of the k-th period.
+func (r RRule) period(start time.Time, k int) time.Time {
+	step := k * r.Interval
+
+	switch r.Freq {
+	case Yearly:
+		return time.Date(start.Year()+step, 1, 1, 0, 0, 0, 0, time.UTC)
+	case Monthly:
+		return time.Date(start.Year(), start.Month()+time.Month(step), 1, 0, 0, 0, 0, time.UTC)
+	case Weekly:
+		return r.weekStart(start).AddDate(0, 0, 7*step)
+	case Daily:
+		return time.Date(start.Year(), start.Month(), start.Day()+step, 0, 0, 0, 0, time.UTC)
+	case Hourly:
+		return time.Date(start.Year(), start.Month(), start.Day(), start.Hour()+step, 0, 0, 0, time.UTC)
+	default:
+		return time.Date(start.Year(), start.Month(), start.Day(), start.Hour(), start.Minute()+step, 0, 0, time.UTC)
+	}
+}
+
+// weekStart returns midnight of the WKST day of the week holding t.
+func (r RRule) weekStart(t time.Time) time.Time {
+	offset := (int(t.Weekday()) - int(r.Wkst) + 7) % 7
+
+	return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, time.UTC)
+}
+
+// expand returns the sorted floating wall times of the period after BYSETPOS.
+func (r RRule) expand(period, start time.Time) []time.Time {
+	days := r.days(period, start)
+
+	if len(days) == 0 {
+		return nil
+	}
+
+	hours, minutes := r.ByHour, r.ByMinute
+
+	switch r.Freq {
+	case Hourly:
+		if len(hours) > 0 && !containsInt(hours, period.Hour()) {
+			return nil
+		}
+
+		hours = []int{period.Hour()}
+	case Minutely:
+		if (len(hours) > 0 && !containsInt(hours, period.Hour())) || (len(minutes) > 0 && !containsInt(minutes, period.Minute())) {
+			return nil
+		}
+
+		hours, minutes = []int{period.Hour()}, []int{period.Minute()}
+	}
+
+	if len(hours) == 0 {
+		hours = []int{start.Hour()}
+	}
+
+	if len(minutes) == 0 {
+		minutes = []int{start.Minute()}
+	}
+
+	var walls []time.Time
+
+	for _, day := range days {
+		for _, hour := range hours {
+			for _, minute := range minutes {
+				walls = append(walls, time.Date(day.Year(), day.Month(), day.Day(), hour, minute, start.Second(), 0, time.UTC))
+			}
+		}
+	}
+
+	sort.Slice(walls, func(i, j int) bool { return walls[i].Before(walls[j]) })
+
+	if len(r.BySetPos) == 0 {
+		return walls
+	}
+
+	var selected []time.Time
+
+	for i, wall := range walls {
+		for _, pos := range r.BySetPos {
+			if pos == i+1 || pos == i-len(walls) {
+				selected = append(selected, wall)
+				break
+			}
+		}
+	}
+
+	return selected
+}
+
+// days returns the dates of the period matching the BYMONTH, BYMONTHDAY and
+// BYDAY parts, expanding or limiting as RFC 5545 prescribes for the frequency.
+func (r RRule) days(period, start time.Time) []time.Time {
+	switch r.Freq {
+	case Yearly:
+		if len(r.ByMonth) == 0 && len(r.ByMonthDay) == 0 && len(r.ByDay) == 0 {
+			day := time.Date(period.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+
+			if day.Month() != start.Month() {
+				return nil
+			}
+
+			return []time.Time{day}
+		}
+
+		if len(r.ByMonth) == 0 && len(r.ByMonthDay) == 0 {
+			first := time.Date(period.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+
+			return r.weekdaysIn(first, first.AddDate(1, 0, 0))
+		}
+
+		months := r.ByMonth
+
+		if len(months) == 0 {
+			months = []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+		}
+
+		var days []time.Time
+
+		for _, month := range months {
+			days = append(days, r.monthDays(time.Date(period.Year(), time.Month(month), 1, 0, 0, 0, 0, time.UTC), start)...)
+		}
+
+		sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+
+		return days
+	case Monthly:
+		if len(r.ByMonth) > 0 && !containsInt(r.ByMonth, int(period.Month())) {
+			return nil
+		}
+
+		return r.monthDays(period, start)
+	case Weekly:
+		var days []time.Time
+
+		for i := 0; i < 7; i++ {
+			day := period.AddDate(0, 0, i)
+
+			if len(r.ByDay) == 0 && day.Weekday() != start.Weekday() {
+				continue
+			}
+
+			if r.limits(day) {
+				days = append(days, day)
+			}
+		}
+
+		return days
+	default:
+		day := time.Date(period.Year(), period.Month(), period.Day(), 0, 0, 0, 0, time.UTC)
+
+		if !r.limits(day) {
+			return nil
+		}
+
+		return []time.Time{day}
+	}
+}
+
+// monthDays returns the dates of the month starting at first that match
+// BYMONTHDAY and BYDAY, or the DTSTART day of month without either.
+func (r RRule) monthDays(first, start time.Time) []time.Time {
+	next := first.AddDate(0, 1, 0)
+
+	if len(r.ByMonthDay) == 0 && len(r.ByDay) == 0 {
+		day := first.AddDate(0, 0, start.Day()-1)
+
+		if !day.Before(next) {
+			return nil
+		}
+
+		return []time.Time{day}
+	}
+
+	if len(r.ByMonthDay) == 0 {
+		return r.weekdaysIn(first, next)
+	}
+
+	var days []time.Time
+
+	for day := first; day.Before(next); day = day.AddDate(0, 0, 1) {
+		if r.matchesMonthDay(day) && (len(r.ByDay) == 0 || r.matchesWeekday(day, first, next)) {
+			days = append(days, day)
+		}
+	}
+
+	return days
+}
+
+// weekdaysIn returns the dates in [from, to) matching BYDAY with ordinals
+// counted within that range.
+func (r RRule) weekdaysIn(from, to time.Time) []time.Time {
+	var days []time.Time
+
+	for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+		if r.matchesWeekday(day, from, to) {
+			days = append(days, day)
+		}
+	}
+
+	return days
+}
+
+func (r RRule) matchesWeekday(day, from, to time.Time) bool {
+	for _, weekday := range r.ByDay {
+		if day.Weekday() != weekday.Weekday {
+			continue
+		}
+
+		switch {
+		case weekday.N > 0 && daysBetween(from, day)/7 != weekday.N-1:
+		case weekday.N < 0 && daysBetween(day, to.AddDate(0, 0, -1))/7 != -weekday.N-1:
+		default:
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r RRule) matchesMonthDay(day time.Time) bool {
+	last := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+
+	for _, monthDay := range r.ByMonthDay {
+		if monthDay == day.Day() || last+monthDay+1 == day.Day() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// limits tells whether the day passes BYMONTH, BYMONTHDAY and BYDAY used as
+// filters for weekly and shorter frequencies.
+func (r RRule) limits(day time.Time) bool {
+	if len(r.ByMonth) > 0 && !containsInt(r.ByMonth, int(day.Month())) {
+		return false
+	}
+
+	if len(r.ByMonthDay) > 0 && !r.matchesMonthDay(day) {
+		return false
+	}
+
+	return len(r.ByDay) == 0 || r.matchesWeekday(day, day, day)
+}
+
+func daysBetween(from, to time.Time) int {
+	return int(to.Sub(from).Hours()) / 24
+}
+
+func containsInt(vals []int, val int) bool {
+	for _, v := range vals {
+		if v == val {
+			return true
+		}
+	}
+
+	return false
+}
+
+// String formats the rule as DTSTART and RRULE lines with the parts in a fixed
+// order. DTSTART is left out when the rule has the default start.
+func (r RRule) String() string {
+	loc := r.location()
+	var lines []string
+
+	if !r.hasDefaultStart() {
+		lines = append(lines, rruleStartLine(r.Start, loc))
+	}
+
+	var names []string
+
+	for name, freq := range frequencies {
+		if freq == r.Freq {
+			names = append(names, "FREQ="+name)
+		}
+	}
+
+	if r.Interval > 1 {
+		names = append(names, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+
+	if r.Count > 0 {
+		names = append(names, "COUNT="+strconv.Itoa(r.Count))
+	}
+
+	if r.Until != nil {
+		names = append(names, "UNTIL="+r.Until.UTC().Format(basicUTCFormat))
+	}
+
+	for _, part := range []struct {
+		name   string
+		values []int
+	}{{"BYMONTH", r.ByMonth}, {"BYMONTHDAY", r.ByMonthDay}} {
+		if len(part.values) > 0 {
+			names = append(names, part.name+"="+joinInts(part.values))
+		}
+	}
+
+	if len(r.ByDay) > 0 {
+		var days []string
+
+		for _, day := range r.ByDay {
+			prefix := ""
+
+			if day.N != 0 {
+				prefix = strconv.Itoa(day.N)
+			}
+
+			days = append(days, prefix+weekdayNames[day.Weekday])
+		}
+
+		names = append(names, "BYDAY="+strings.Join(days, ","))
+	}
+
+	for _, part := range []struct {
+		name   string
+		values []int
+	}{{"BYHOUR", r.ByHour}, {"BYMINUTE", r.ByMinute}, {"BYSETPOS", r.BySetPos}} {
+		if len(part.values) > 0 {
+			names = append(names, part.name+"="+joinInts(part.values))
+		}
+	}
+
+	if r.Wkst != time.Monday {
+		names = append(names, "WKST="+weekdayNames[r.Wkst])
+	}
+
+	return strings.Join(append(lines, "RRULE:"+strings.Join(names, ";")), "\n")
+}
+
+// rruleStartLine writes the DTSTART line of a rule starting at the given
+// time in the location.
+func rruleStartLine(start time.Time, loc *time.Location) string {
+	if loc == time.UTC {
+		return "DTSTART:" + start.UTC().Format(basicUTCFormat)
+	}
+
+	return "DTSTART;TZID=" + loc.String() + ":" + start.In(loc).Format(basicDateTimeFormat)
+}
+
+// hasDefaultStart tells whether the rule was given without DTSTART.
+func (r RRule) hasDefaultStart() bool {
+	return r.Start.Equal(time.Date(1970, 1, 1, 0, 0, 0, 0, r.location()))
+}
+
+func joinInts(vals []int) string {
+	items := make([]string, len(vals))
+
+	for i, val := range vals {
+		items[i] = strconv.Itoa(val)
+	}
+
+	return strings.Join(items, ",")
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func nextOccurrences(schedule Schedule, after time.Time, count int) []string {
+	var result []string
+
+	for i := 0; i < count; i++ {
+		next, ok := schedule.Next(after)
+
+		if !ok {
+			break
+		}
+
+		result = append(result, next.UTC().Format(time.RFC3339))
+		after = next
+	}
+
+	return result
+}
+
+func TestRRuleFromString(t *testing.T) {
+	t.Parallel()
+
+	var formatTests = []struct {
+		Rule          string
+		FormatCorrect bool
+	}{
+		/* correct formats */
+		{"FREQ=DAILY", true},
+		{"RRULE:FREQ=MONTHLY;BYDAY=-1FR", true},
+		{"DTSTART:20170101T090000Z\nRRULE:FREQ=WEEKLY;INTERVAL=2;COUNT=10", true},
+		{"DTSTART;TZID=Europe/Berlin:20170101T090000 RRULE:FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR;BYHOUR=8;BYMINUTE=30", true},
+		{"RRULE:FREQ=YEARLY;BYMONTH=1,7;BYMONTHDAY=-1;UNTIL=20200101", true},
+		{"RRULE:FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1;WKST=SU", true},
+		/* incorrect formats */
+		{"RRULE:INTERVAL=2", false},                                     //no FREQ
+		{"RRULE:FREQ=SECONDLY", false},                                  //unsupported frequency
+		{"RRULE:FREQ=DAILY;COUNT=2;UNTIL=20200101", false},              //COUNT and UNTIL together
+		{"RRULE:FREQ=WEEKLY;BYDAY=1MO", false},                          //ordinal in weekly rule
+		{"RRULE:FREQ=MONTHLY;BYMONTHDAY=0", false},                      //zero month day
+		{"RRULE:FREQ=DAILY;BYHOUR=24", false},                           //hour out of range
+		{"RRULE:FREQ=DAILY;BYWEEKNO=1", false},                          //unsupported part
+		{"RRULE:FREQ=DAILY;FREQ=WEEKLY", false},                         //repeated part
+		{"DTSTART;TZID=Nowhere/City:20170101T090000 FREQ=DAILY", false}, //unknown zone
+		{"DTSTART:20171 FREQ=DAILY", false},                             //bad start
+		{"RRULE:FREQ=DAILY;COUNT=3", false},                             //COUNT without DTSTART
+	}
+
+	for index, test := range formatTests {
+		_, err := RRuleFromString(test.Rule, time.UTC)
+
+		if test.FormatCorrect && err != nil {
+			t.Errorf("Test %d expected correct format but got error %#v", index+1, err)
+		} else if !test.FormatCorrect && err == nil {
+			t.Errorf("Test %d expected incorrect format", index+1)
+		}
+	}
+
+	var parseErr *ParseError
+
+	// a count without start would be used up in 1970
+	if _, err := RRuleFromString("RRULE:FREQ=DAILY;COUNT=3", time.UTC); !errors.As(err, &parseErr) || parseErr.Reason != ReasonUnanchoredCount || parseErr.Offset != 17 || parseErr.Token != "COUNT=3" {
+		t.Errorf("Expected %s at offset 17 but got %v", ReasonUnanchoredCount, err)
+	}
+}
+
+func TestRRuleNext(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		Rule     string
+		After    time.Time
+		Expected []string
+	}{
+		// last Friday of every month
+		{"DTSTART:20170101T090000Z RRULE:FREQ=MONTHLY;BYDAY=-1FR", now, []string{"2017-01-27T09:00:00Z", "2017-02-24T09:00:00Z", "2017-03-31T09:00:00Z"}},
+		// every weekday at 08:30
+		{"FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR;BYHOUR=8;BYMINUTE=30", time.Date(2017, 1, 5, 12, 0, 0, 0, time.UTC), []string{"2017-01-06T08:30:00Z", "2017-01-09T08:30:00Z", "2017-01-10T08:30:00Z"}},
+		// every other week, three times
+		{"DTSTART:20170102T100000Z RRULE:FREQ=WEEKLY;INTERVAL=2;COUNT=3", now, []string{"2017-01-02T10:00:00Z", "2017-01-16T10:00:00Z", "2017-01-30T10:00:00Z"}},
+		{"DTSTART:20170102T100000Z RRULE:FREQ=WEEKLY;INTERVAL=2;COUNT=3", time.Date(2017, 1, 20, 0, 0, 0, 0, time.UTC), []string{"2017-01-30T10:00:00Z"}},
+		// monthly on the 31st skips short months
+		{"DTSTART:20170131T000000Z RRULE:FREQ=MONTHLY", now, []string{"2017-01-31T00:00:00Z", "2017-03-31T00:00:00Z", "2017-05-31T00:00:00Z"}},
+		// last working day of the month
+		{"DTSTART:20170101T180000Z RRULE:FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1", now, []string{"2017-01-31T18:00:00Z", "2017-02-28T18:00:00Z", "2017-03-31T18:00:00Z"}},
+		// first and fifteenth, twice a day
+		{"DTSTART:20170101T000000Z RRULE:FREQ=MONTHLY;BYMONTHDAY=1,15;BYHOUR=6,18", now, []string{"2017-01-01T06:00:00Z", "2017-01-01T18:00:00Z", "2017-01-15T06:00:00Z"}},
+		// yearly, second Sunday of May
+		{"DTSTART:20170101T120000Z RRULE:FREQ=YEARLY;BYMONTH=5;BYDAY=2SU", now, []string{"2017-05-14T12:00:00Z", "2018-05-13T12:00:00Z", "2019-05-12T12:00:00Z"}},
+		// yearly, 20th Monday of the year
+		{"DTSTART:20170101T000000Z RRULE:FREQ=YEARLY;BYDAY=20MO", now, []string{"2017-05-15T00:00:00Z", "2018-05-14T00:00:00Z", "2019-05-20T00:00:00Z"}},
+		// every 90 minutes until noon
+		{"DTSTART:20170101T090000Z RRULE:FREQ=MINUTELY;INTERVAL=90;UNTIL=20170101T120000Z", now, []string{"2017-01-01T09:00:00Z", "2017-01-01T10:30:00Z", "2017-01-01T12:00:00Z"}},
+		// hourly on weekends only
+		{"DTSTART:20170106T220000Z RRULE:FREQ=HOURLY;BYDAY=SA,SU", time.Date(2017, 1, 6, 21, 0, 0, 0, time.UTC), []string{"2017-01-07T00:00:00Z", "2017-01-07T01:00:00Z", "2017-01-07T02:00:00Z"}},
+		// seeking far from the start
+		{"FREQ=MINUTELY;INTERVAL=15", time.Date(2017, 1, 1, 10, 7, 0, 0, time.UTC), []string{"2017-01-01T10:15:00Z", "2017-01-01T10:30:00Z", "2017-01-01T10:45:00Z"}},
+		// never happens
+		{"FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30", now, nil},
+	}
+
+	for index, test := range tests {
+		rrule, err := RRuleFromString(test.Rule, time.UTC)
+
+		if err != nil {
+			t.Fatalf("Test %d expected correct rule but got error %#v", index+1, err)
+		}
+
+		result := nextOccurrences(rrule, test.After, 3)
+
+		if len(result) != len(test.Expected) {
+			t.Errorf("Test %d expected %v but got %v", index+1, test.Expected, result)
+			continue
+		}
+
+		for i := range result {
+			if result[i] != test.Expected[i] {
+				t.Errorf("Test %d expected %v but got %v", index+1, test.Expected, result)
+				break
+			}
+		}
+	}
+}
+
+func TestRRuleInLocation(t *testing.T) {
+	t.Parallel()
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+
+	if err != nil {
+		t.Skipf("No time zone database: %s", err)
+	}
+
+	schedule, err := ScheduleFromString("FREQ=DAILY;BYHOUR=9", ScheduleOptions{Location: berlin})
+
+	if err != nil {
+		t.Fatalf("Expected correct rule but got error %#v", err)
+	}
+
+	expected := []string{"2017-03-25T08:00:00Z", "2017-03-26T07:00:00Z", "2017-03-27T07:00:00Z"}
+	result := nextOccurrences(schedule, time.Date(2017, 3, 25, 0, 0, 0, 0, time.UTC), 3)
+
+	for i := range expected {
+		if i >= len(result) || result[i] != expected[i] {
+			t.Errorf("Expected %v but got %v", expected, result)
+			break
+		}
+	}
+
+	// both passes through the repeated hour of 29 October, every quarter
+	overlap := []string{"2017-10-29T00:00:00Z", "2017-10-29T00:15:00Z", "2017-10-29T00:30:00Z", "2017-10-29T00:45:00Z", "2017-10-29T01:00:00Z", "2017-10-29T01:15:00Z", "2017-10-29T01:30:00Z", "2017-10-29T01:45:00Z"}
+
+	var overlapTests = []struct {
+		Rule     string
+		Expected []string
+	}{
+		{"FREQ=DAILY;BYHOUR=2;BYMINUTE=0,15,30,45", append(overlap, "2017-10-30T01:00:00Z")},
+		{"FREQ=MINUTELY;INTERVAL=15;BYHOUR=2", append(overlap, "2017-10-30T01:00:00Z")},
+		// a wall time fired twice counts once
+		{"DTSTART;TZID=Europe/Berlin:20171029T020000 FREQ=MINUTELY;INTERVAL=15;BYHOUR=2;COUNT=4", overlap},
+	}
+
+	for index, test := range overlapTests {
+		schedule, err := ScheduleFromString(test.Rule, ScheduleOptions{Location: berlin, DSTOverlap: OverlapTwice})
+
+		if err != nil {
+			t.Fatalf("Test %d expected correct rule but got error %#v", index+1, err)
+		}
+
+		if result := nextOccurrences(schedule, time.Date(2017, 10, 28, 12, 0, 0, 0, time.UTC), 9); strings.Join(result, " ") != strings.Join(test.Expected, " ") {
+			t.Errorf("Test %d expected %v but got %v", index+1, test.Expected, result)
+		}
+	}
+}
+
+func TestRRuleString(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		Rule     string
+		Expected string
+	}{
+		{"FREQ=DAILY", "RRULE:FREQ=DAILY"},
+		{"RRULE:FREQ=MONTHLY;BYDAY=-1FR;INTERVAL=1", "RRULE:FREQ=MONTHLY;BYDAY=-1FR"},
+		{"DTSTART:20170101T090000Z RRULE:BYDAY=MO,FR;FREQ=WEEKLY;COUNT=10;WKST=SU", "DTSTART:20170101T090000Z\nRRULE:FREQ=WEEKLY;COUNT=10;BYDAY=MO,FR;WKST=SU"},
+		{"DTSTART;TZID=Europe/Berlin:20170101T090000\nRRULE:FREQ=YEARLY;BYMONTHDAY=1;BYMONTH=1,7;UNTIL=20200101", "DTSTART;TZID=Europe/Berlin:20170101T090000\nRRULE:FREQ=YEARLY;UNTIL=20191231T230000Z;BYMONTH=1,7;BYMONTHDAY=1"},
+	}
+
+	for index, test := range tests {
+		rrule, err := RRuleFromString(test.Rule, time.UTC)
+
+		if err != nil {
+			t.Fatalf("Test %d expected correct rule but got error %#v", index+1, err)
+		}
+
+		result := rrule.String()
+
+		if result != test.Expected {
+			t.Errorf("Test %d expected %q but got %q", index+1, test.Expected, result)
+		}
+
+		if reparsed, err := RRuleFromString(result, time.UTC); err != nil || reparsed.String() != result {
+			t.Errorf("Test %d expected canonical form to round-trip but got %q, %v", index+1, reparsed.String(), err)
+		}
+	}
+}
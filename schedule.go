@@ -0,0 +1,161 @@
+package main
+
+import "time"
+
+// Schedule yields the fire times of a task rule.
+type Schedule interface {
+	// Next returns the first occurrence strictly after the given time, or
+	// false when there is none.
+	Next(after time.Time) (time.Time, bool)
+	// String returns the rule in canonical form.
+	String() string
+}
+
+// ScheduleOptions are the task settings a rule is evaluated with.
+type ScheduleOptions struct {
+	Location   *time.Location
+	DSTGap     GapPolicy
+	DSTOverlap OverlapPolicy
+	// MonthEnd settles ISO 8601 occurrences on days missing from their month.
+	MonthEnd MonthEndPolicy
+	// LenientDurations accepts ISO 8601 durations combining weeks with other
+	// components, e.g. P1W2D.
+	LenientDurations bool
+	// Calendar, when set, gives the working days of business day offsets, and
+	// occurrences on its non-working days are moved by Roll.
+	Calendar *Calendar
+	Roll     RollConvention
+	// Created is when the rule is set up, English phrases running every few
+	// days, weeks or months count them from its day. Zero means now.
+	Created time.Time
+}
+
+// ScheduleFromString parses a rule written as an ISO 8601 repeating interval,
+// an RFC 5545 RRULE or a cron expression, optionally preceded by an OFFSET
+// line, an English phrase such as "every weekday at 9am", or several of those
+// joined by UNION, INTERSECT and EXCEPT. With a calendar the resulting
+// occurrences are rolled off its non-working days, which needs a rule with a
+// start or an end.
+func ScheduleFromString(rule string, options ScheduleOptions) (Schedule, error) {
+	if options.Location == nil {
+		options.Location = time.UTC
+	}
+
+	schedule, err := scheduleFromString(rule, options)
+
+	if err != nil || options.Calendar == nil {
+		return schedule, err
+	}
+
+	if recurrence, ok := schedule.(Recurrence); ok && recurrence.Start == nil && recurrence.End == nil {
+		return nil, newParseError(0, rule, ReasonUnanchoredRoll)
+	}
+
+	return RolledSchedule{Base: schedule, Calendar: options.Calendar, Roll: options.Roll, Location: options.Location}, nil
+}
+
+// scheduleFromString parses a rule without rolling it.
+func scheduleFromString(rule string, options ScheduleOptions) (Schedule, error) {
+	if isComposite(rule) {
+		return CompositeFromString(rule, options)
+	}
+
+	if isOffset(rule) {
+		return OffsetFromString(rule, options)
+	}
+
+	if isNatural(rule) {
+		created := options.Created
+
+		if created.IsZero() {
+			created = time.Now()
+		}
+
+		compiled, err := NaturalFromString(rule, created.In(options.Location))
+
+		if err != nil {
+			return nil, err
+		}
+
+		return scheduleFromString(compiled, options)
+	}
+
+	if isRRule(rule) {
+		rrule, err := RRuleFromString(rule, options.Location)
+
+		if err != nil {
+			return nil, err
+		}
+
+		rrule.DSTGap, rrule.DSTOverlap = options.DSTGap, options.DSTOverlap
+
+		return rrule, nil
+	}
+
+	if isCron(rule) {
+		cron, err := CronFromString(rule, options.Location)
+
+		if err != nil {
+			return nil, err
+		}
+
+		cron.DSTGap, cron.DSTOverlap = options.DSTGap, options.DSTOverlap
+
+		return cron, nil
+	}
+
+	recurrence, err := RecurrenceParser{Location: options.Location, Lenient: options.LenientDurations}.Recurrence(rule)
+
+	if err != nil {
+		return nil, err
+	}
+
+	recurrence.DSTGap, recurrence.DSTOverlap = options.DSTGap, options.DSTOverlap
+	recurrence.MonthEnd = options.MonthEnd
+
+	return recurrence, nil
+}
+
+// scheduleRemaining returns how many repetitions of the recurrence a schedule
+// wraps are left counting its occurrence at t, -1 for a schedule without a
+// repetition count.
+func scheduleRemaining(schedule Schedule, t time.Time) int {
+	if counted, ok := schedule.(interface{ remaining(time.Time) int }); ok {
+		return counted.remaining(t)
+	}
+
+	return -1
+}
+
+// fireTimeIterator steps through the fire times of a schedule.
+type fireTimeIterator interface {
+	Next() (time.Time, bool)
+}
+
+// scheduleIterator steps through a schedule without an iterator of its own,
+// each fire time found from the previous one.
+type scheduleIterator struct {
+	schedule Schedule
+	after    time.Time
+}
+
+func (it *scheduleIterator) Next() (time.Time, bool) {
+	next, ok := it.schedule.Next(it.after)
+
+	if ok {
+		it.after = next
+	}
+
+	return next, ok
+}
+
+// iterate returns an iterator over the fire times of the schedule strictly
+// after the given time. A recurrence seeks that time once and then steps
+// slot by slot.
+func iterate(schedule Schedule, after time.Time) fireTimeIterator {
+	if recurrence, ok := schedule.(Recurrence); ok {
+		return recurrence.Iterator(after)
+	}
+
+	return &scheduleIterator{schedule: schedule, after: after}
+}
@@ -0,0 +1,90 @@
+package main
+
+import "sync"
+
+// CompiledSchedule is a stored task rule parsed once, together with the
+// options it was parsed with, among them the loaded time zone and calendar.
+// It is shared until the task changes.
+type CompiledSchedule struct {
+	Schedule Schedule
+	Options  ScheduleOptions
+	// Version is the task version the rule was compiled from.
+	Version int
+}
+
+// compiledSchedules caches schedules by task id. Calendar generations count
+// how many times the calendar of each name was replaced or removed.
+var compiledSchedules = struct {
+	sync.RWMutex
+	byTask              map[string]*CompiledSchedule
+	calendarGenerations map[string]uint64
+}{byTask: map[string]*CompiledSchedule{}, calendarGenerations: map[string]uint64{}}
+
+// Compile returns the compiled schedule of a stored task, parsing its rule
+// only when the task is new to the cache or its version changed. Tasks
+// without a version, not stored yet, are compiled every time.
+func (task DbTask) Compile() (*CompiledSchedule, error) {
+	compiledSchedules.RLock()
+	cached, ok := compiledSchedules.byTask[task.Id]
+	generation := compiledSchedules.calendarGenerations[task.Calendar]
+	compiledSchedules.RUnlock()
+
+	if task.Version > 0 && ok && cached.Version == task.Version {
+		return cached, nil
+	}
+
+	options, err := task.ScheduleOptions()
+
+	if err != nil {
+		return nil, err
+	}
+
+	schedule, err := task.Schedule()
+
+	if err != nil {
+		return nil, err
+	}
+
+	compiled := &CompiledSchedule{Schedule: schedule, Options: options, Version: task.Version}
+
+	if task.Version > 0 {
+		storeSchedule(task.Id, task.Calendar, compiled, generation)
+	}
+
+	return compiled, nil
+}
+
+// storeSchedule caches the compiled schedule of the task unless its calendar
+// changed since the given generation, the schedule then possibly being
+// rolled with the calendar it replaced.
+func storeSchedule(taskId string, calendar string, compiled *CompiledSchedule, generation uint64) {
+	compiledSchedules.Lock()
+	defer compiledSchedules.Unlock()
+
+	if compiledSchedules.calendarGenerations[calendar] == generation {
+		compiledSchedules.byTask[taskId] = compiled
+	}
+}
+
+// forgetSchedule drops the compiled schedule of the task.
+func forgetSchedule(taskId string) {
+	compiledSchedules.Lock()
+	defer compiledSchedules.Unlock()
+
+	delete(compiledSchedules.byTask, taskId)
+}
+
+// forgetCalendarSchedules drops the compiled schedules rolled with the
+// calendar of the given name, which was replaced or removed.
+func forgetCalendarSchedules(name string) {
+	compiledSchedules.Lock()
+	defer compiledSchedules.Unlock()
+
+	compiledSchedules.calendarGenerations[name]++
+
+	for taskId, compiled := range compiledSchedules.byTask {
+		if compiled.Options.Calendar != nil && compiled.Options.Calendar.Name == name {
+			delete(compiledSchedules.byTask, taskId)
+		}
+	}
+}
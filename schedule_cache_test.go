@@ -0,0 +1,102 @@
+package main
+
+import "testing"
+
+func TestDbTaskCompile(t *testing.T) {
+	t.Parallel()
+
+	task := DbTask{Task: &Task{Id: "test-compile", Rule: "0 9 * * *", TimeZone: "Europe/Berlin"}, Version: 1}
+	compiled, err := task.Compile()
+
+	if err != nil {
+		t.Fatalf("Expected correct rule but got error %s", err)
+	}
+
+	if compiled.Options.Location.String() != "Europe/Berlin" {
+		t.Errorf("Expected location Europe/Berlin but got %s", compiled.Options.Location)
+	}
+
+	// the rule of the same version is not parsed again
+	task.Task = &Task{Id: "test-compile", Rule: "0 10 * * *"}
+
+	if again, err := task.Compile(); err != nil || again != compiled {
+		t.Errorf("Expected the cached schedule but got %v %v", again, err)
+	}
+
+	task.Version++
+
+	if again, err := task.Compile(); err != nil || again.Schedule.String() != "0 10 * * *" {
+		t.Errorf("Expected the schedule of version 2 but got %v %v", again, err)
+	}
+
+	forgetSchedule(task.Id)
+
+	if again, _ := task.Compile(); again == compiled {
+		t.Errorf("Expected a new schedule after forgetting the task")
+	}
+
+	// a task not stored yet is compiled every time
+	unstored := DbTask{Task: &Task{Id: "test-compile-unstored", Rule: "0 9 * * *"}}
+	first, _ := unstored.Compile()
+
+	if second, _ := unstored.Compile(); first == second {
+		t.Errorf("Expected a task without version not to be cached")
+	}
+
+	if _, err := (DbTask{Task: &Task{Id: "test-compile-bad", Rule: "0 9 * *"}, Version: 1}).Compile(); err == nil {
+		t.Errorf("Expected an error for a bad rule")
+	}
+}
+
+func TestCompileCalendarChange(t *testing.T) {
+	t.Parallel()
+
+	calendar, _ := NewCalendar("test-compile", nil, nil)
+	RegisterCalendar(calendar)
+
+	task := DbTask{Task: &Task{Id: "test-compile-calendar", Rule: "0 9 * * *", Calendar: "test-compile"}, Version: 1}
+	compiled, err := task.Compile()
+
+	if err != nil {
+		t.Fatalf("Expected correct rule but got error %s", err)
+	}
+
+	replacement, _ := NewCalendar("test-compile", nil, []string{"2017-12-25"})
+	RegisterCalendar(replacement)
+
+	again, err := task.Compile()
+
+	if err != nil || again == compiled || again.Options.Calendar != replacement {
+		t.Errorf("Expected a schedule rolled with the replaced calendar but got %v %v", again, err)
+	}
+}
+
+func TestCompileDuringCalendarChange(t *testing.T) {
+	t.Parallel()
+
+	calendar, _ := NewCalendar("test-compile-during", nil, nil)
+	RegisterCalendar(calendar)
+
+	task := DbTask{Task: &Task{Id: "test-compile-during", Rule: "0 9 * * *", Calendar: "test-compile-during"}, Version: 1}
+	compiled, err := task.Compile()
+
+	if err != nil {
+		t.Fatalf("Expected correct rule but got error %s", err)
+	}
+
+	// a schedule compiled with the calendar before it was replaced is not
+	// cached
+	forgetSchedule(task.Id)
+
+	compiledSchedules.RLock()
+	generation := compiledSchedules.calendarGenerations["test-compile-during"]
+	compiledSchedules.RUnlock()
+
+	replacement, _ := NewCalendar("test-compile-during", nil, []string{"2017-12-25"})
+	RegisterCalendar(replacement)
+	storeSchedule(task.Id, "test-compile-during", compiled, generation)
+
+	if again, _ := task.Compile(); again == compiled || again.Options.Calendar != replacement {
+		t.Errorf("Expected a schedule rolled with the replaced calendar but got %v", again)
+	}
+}
@@ -22,9 +22,7 @@ func startScheduler(db sql.DB, tasksPerLoop int) {
 			}
 		} else if len(tasks) > 0 {
 			for _, task := range tasks {
-				runTask(task)
-
-				if err := updateTaskNextFireAt(db, task.Id, now.Add(30*time.Second), false); err != nil {
+				if err := scheduleTask(db, task, now); err != nil {
 					log.Print("Update task failed ", task.Id, " ", task.Rule, " ", err)
 				}
 			}
@@ -36,7 +34,125 @@ func startScheduler(db sql.DB, tasksPerLoop int) {
 	}
 }
 
+// scheduleTask runs the task for its occurrences due since nextFireAt, as
+// far as its epsilon and misfire policy allow, records them in the run
+// history and moves nextFireAt to the following occurrence of its rule. A task
+// seen for the first time is only scheduled, not run, and neither is one
+// whose due time was excluded or removed from its extra dates since it was
+// scheduled.
+func scheduleTask(db sql.DB, task DbTask, now time.Time) error {
+	compiled, err := task.Compile()
+
+	if err != nil {
+		log.Print("Bad task rule ", task.Id, " ", task.Rule, " ", err)
+		return updateTaskNextFireAt(db, task.Id, now, 0, true)
+	}
+
+	schedule := compiled.Schedule
+	recurrence, isRecurrence := schedule.(Recurrence)
+	bare := isRecurrence && recurrence.Start == nil && recurrence.End == nil
+
+	if bare && task.NextFireAt != nil && task.Remaining != nil {
+		// a bare duration counts on with the repetitions left when its last
+		// fire time was scheduled
+		recurrence.Repetitions = *task.Remaining
+	}
+
+	if bare && recurrence.Repetitions == 0 {
+		return updateTaskNextFireAt(db, task.Id, now, 0, true)
+	}
+
+	if bare && task.NextFireAt == nil {
+		// a bare duration starts counting from the moment it is picked up
+		return updateTaskNextFireAt(db, task.Id, now, recurrence.Repetitions, false)
+	}
+
+	after := now
+
+	if task.NextFireAt != nil {
+		if bare {
+			// a bare duration counts on from its last fire time
+			recurrence = recurrence.AnchoredAt(*task.NextFireAt)
+			schedule = recurrence
+		}
+
+		due, skipped := dueOccurrences(schedule, *task.NextFireAt, now)
+		run, missed := task.misfire(due, now)
+
+		if skipped > 0 {
+			log.Print("Missed task ", task.Id, " ", task.Rule, " ", skipped, " times before ", due[0])
+		}
+
+		for _, at := range missed {
+			log.Print("Missed task ", task.Id, " ", task.Rule, " due at ", at)
+
+			if err := insertTaskRun(db, task.Id, RunMissed, at); err != nil {
+				log.Print("Recording task run failed ", task.Id, " ", err)
+			}
+		}
+
+		for _, at := range run {
+			runTask(task)
+
+			if err := insertTaskRun(db, task.Id, RunFired, at); err != nil {
+				log.Print("Recording task run failed ", task.Id, " ", err)
+			}
+		}
+
+		if task.NextFireAt.After(after) {
+			after = *task.NextFireAt
+		}
+	}
+
+	nextFireAt, remaining, ok := nextFire(schedule, after)
+
+	if !ok {
+		return updateTaskNextFireAt(db, task.Id, after, remaining, true)
+	}
+
+	return updateTaskNextFireAt(db, task.Id, nextFireAt, remaining, false)
+}
+
+// nextFire returns the first fire time of the schedule strictly after the
+// given time and the repetitions left counting it, -1 for a schedule without
+// a repetition count. It returns false once the schedule is exhausted, a
+// recurrence, bare or wrapped for jitter, dates or a calendar, then having no
+// repetitions left.
+func nextFire(schedule Schedule, after time.Time) (time.Time, int, bool) {
+	next, ok := schedule.Next(after)
+
+	if ok {
+		// occurrences passed over use up repetitions as well
+		return next, scheduleRemaining(schedule, next), true
+	}
+
+	if scheduleRemaining(schedule, after) < 0 {
+		return time.Time{}, -1, false
+	}
+
+	return time.Time{}, 0, false
+}
+
 func runTask(task DbTask) *error {
 	log.Print("Running task ", task.Id, " ", task.Rule)
 	return nil
 }
+
+// RunStatus is the outcome of an occurrence in the tasksRuns history.
+type RunStatus int
+
+const (
+	// RunFired is an occurrence the task was run for.
+	RunFired RunStatus = iota + 1
+	// RunMissed is an occurrence the task was not run for, having come due
+	// longer than its epsilon ago.
+	RunMissed
+)
+
+// insertTaskRun records the outcome of the occurrence of the task due at the
+// given time.
+func insertTaskRun(db sql.DB, taskId string, status RunStatus, runAt time.Time) error {
+	_, err := db.Exec("insert into tasksRuns(taskId, status, runAt) values(?, ?, ?)", taskId, int(status), runAt.UTC().Unix())
+
+	return err
+}
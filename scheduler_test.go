@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextFire(t *testing.T) {
+	t.Parallel()
+
+	bare, _ := RecurrenceFromString("R2/PT1H")
+	unboundedBare, _ := RecurrenceFromString("R/PT1H")
+	calendar, _ := NewCalendar("test-next-fire", nil, nil)
+
+	var tests = []struct {
+		Schedule  Schedule
+		After     time.Time
+		Expected  string
+		Remaining int
+		Ok        bool
+	}{
+		// bounded
+		{scheduleOf("R3/2017-01-01T00:00:00Z/PT1H"), now.Add(-time.Second), "2017-01-01T00:00:00Z", 3, true},
+		{scheduleOf("R3/2017-01-01T00:00:00Z/PT1H"), now, "2017-01-01T01:00:00Z", 2, true},
+		{scheduleOf("R3/2017-01-01T00:00:00Z/PT1H"), now.Add(90 * time.Minute), "2017-01-01T02:00:00Z", 1, true},
+		{scheduleOf("R3/PT1H/2017-01-01T02:00:00Z"), now.Add(-time.Second), "2017-01-01T00:00:00Z", 3, true},
+		{bare.AnchoredAt(now), now, "2017-01-01T01:00:00Z", 1, true},
+		// wrapped
+		{JitteredSchedule{Base: scheduleOf("R5/2017-01-01T00:00:00Z/PT1H"), Offset: 10 * time.Minute}, now.Add(90 * time.Minute), "2017-01-01T02:10:00Z", 3, true},
+		{RecurrenceSet{Schedule: scheduleOf("R5/2017-01-01T00:00:00Z/PT1H"), ExDates: []time.Time{now.Add(time.Hour)}}, now, "2017-01-01T02:00:00Z", 3, true},
+		// the weekend of 7 and 8 January rolls onto Monday 9
+		{RolledSchedule{Base: scheduleOf("R5/2017-01-06T09:00:00Z/P1D"), Calendar: calendar}, now.AddDate(0, 0, 6), "2017-01-09T09:00:00Z", 2, true},
+		// unbounded
+		{scheduleOf("R/2017-01-01T00:00:00Z/PT1H"), now.Add(90 * time.Minute), "2017-01-01T02:00:00Z", -1, true},
+		{unboundedBare.AnchoredAt(now), now, "2017-01-01T01:00:00Z", -1, true},
+		{scheduleOf("0 9 * * *"), now, "2017-01-01T09:00:00Z", -1, true},
+		// exhausted
+		{scheduleOf("R3/2017-01-01T00:00:00Z/PT1H"), now.Add(2 * time.Hour), "", 0, false},
+		{scheduleOf("R3/PT1H/2017-01-01T02:00:00Z"), now.Add(2 * time.Hour), "", 0, false},
+		{scheduleOf("R0/2017-01-01T00:00:00Z/PT1H"), now.Add(-time.Second), "", 0, false},
+		{bare.AnchoredAt(now.Add(-time.Hour)), now, "", 0, false},
+	}
+
+	for index, test := range tests {
+		next, remaining, ok := nextFire(test.Schedule, test.After)
+
+		if ok != test.Ok || (ok && next.Format(time.RFC3339) != test.Expected) {
+			t.Errorf("Test %d expected %s, %t but got %s, %t", index+1, test.Expected, test.Ok, next, ok)
+		} else if remaining != test.Remaining {
+			t.Errorf("Test %d expected %d repetitions left but got %d", index+1, test.Remaining, remaining)
+		}
+	}
+}
+
+func scheduleOf(rule string) Schedule {
+	schedule, _ := ScheduleFromString(rule, ScheduleOptions{})
+
+	return schedule
+}
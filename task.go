@@ -2,27 +2,170 @@ package main
 
 import (
 	"database/sql"
+	"fmt"
 	"time"
 )
 
+// Task is a rule with its settings. Epsilon is how many seconds late an
+// occurrence may run and still count, Misfire what happens to later ones and
+// MaxCatchUp caps their runs under the catch-up policy. LenientDurations
+// accepts durations combining weeks with other components, e.g. P1W2D; the
+// rule is stored in a canonical form that no longer needs it.
 type Task struct {
-	Id         string `json:"id"`
-	Rule       string `json:"rule"`
-	TimeZone   string `json:"timeZone"`
-	Epsilon    int    `json:"epsilon"`
-	MaxRetries int    `json:"maxRetries"`
+	Id         string      `json:"id"`
+	Rule       string      `json:"rule"`
+	TimeZone   string      `json:"timeZone"`
+	DSTGap     string      `json:"dstGap"`
+	DSTOverlap string      `json:"dstOverlap"`
+	MonthEnd   string      `json:"monthEnd"`
+	Calendar   string      `json:"calendar,omitempty"`
+	Roll       string      `json:"roll,omitempty"`
+	Jitter     string      `json:"jitter,omitempty"`
+	Epsilon    int         `json:"epsilon"`
+	Misfire    string      `json:"misfire,omitempty"`
+	MaxCatchUp int         `json:"maxCatchUp,omitempty"`
+	MaxRetries int         `json:"maxRetries"`
+	ExDates    []time.Time `json:"exDates,omitempty"`
+	RDates     []time.Time `json:"rDates,omitempty"`
+
+	LenientDurations bool `json:"lenientDurations,omitempty"`
 }
 
 type DbTask struct {
 	*Task
 	Completed   bool       `json:"completed"`
+	Remaining   *int       `json:"remaining,omitempty"`
+	Description string     `json:"description,omitempty"`
 	NextFireAt  *time.Time `json:"nextFireAt,omitempty"`
-	NextRetryAt *time.Time `json:"nextFireAt,omitempty"`
+	NextRetryAt *time.Time `json:"nextRetryAt,omitempty"`
+	// Version counts the changes to the stored task, starting at 1.
+	Version int `json:"version"`
+}
+
+// Tables of the excluded and extra fire times of tasks.
+const (
+	exDatesTable = "tasksExDates"
+	rDatesTable  = "tasksRDates"
+)
+
+const taskColumns = "id, rule, timeZone, dstGap, dstOverlap, monthEnd, calendar, roll, jitter, epsilon, misfire, maxCatchUp, maxRetries, completed, nextFireAt, remaining, version"
+
+// ScheduleOptions returns the time zone and DST policies of the task.
+func (task Task) ScheduleOptions() (ScheduleOptions, error) {
+	var (
+		options ScheduleOptions
+		err     error
+	)
+
+	if options.Location, err = LocationFromString(task.TimeZone); err != nil {
+		return ScheduleOptions{}, err
+	}
+
+	if options.DSTGap, err = GapPolicyFromString(task.DSTGap); err != nil {
+		return ScheduleOptions{}, err
+	}
+
+	if options.DSTOverlap, err = OverlapPolicyFromString(task.DSTOverlap); err != nil {
+		return ScheduleOptions{}, err
+	}
+
+	if options.MonthEnd, err = MonthEndPolicyFromString(task.MonthEnd); err != nil {
+		return ScheduleOptions{}, err
+	}
+
+	if options.Roll, err = RollConventionFromString(task.Roll); err != nil {
+		return ScheduleOptions{}, err
+	}
+
+	options.LenientDurations = task.LenientDurations
+
+	if task.Calendar != "" {
+		if options.Calendar, err = CalendarByName(task.Calendar); err != nil {
+			return ScheduleOptions{}, err
+		}
+	}
+
+	return options, nil
+}
+
+// JitterOffset returns the delay within the jitter window of the task given
+// by its id, zero without a window.
+func (task Task) JitterOffset() (time.Duration, error) {
+	if task.Jitter == "" {
+		return 0, nil
+	}
+
+	window, err := DurationFromString(task.Jitter)
+
+	if err != nil || window.IsZero() || window.negative() || window.Years != 0 || window.Months != 0 {
+		return 0, ErrBadJitter
+	}
+
+	return jitterOffset(task.Id, window.Approx()), nil
+}
+
+// Schedule parses the task rule in the task time zone with its DST policies,
+// together with its excluded and extra dates and its jitter. Those need a
+// rule with a start or an end. With a calendar, occurrences are rolled by the
+// day they fire on once jittered.
+func (task Task) Schedule() (Schedule, error) {
+	options, err := task.ScheduleOptions()
+
+	if err != nil {
+		return nil, err
+	}
+
+	offset, err := task.JitterOffset()
+
+	if err != nil {
+		return nil, err
+	}
+
+	schedule, err := ScheduleFromString(task.Rule, options)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if rolled, ok := schedule.(RolledSchedule); ok {
+		// delayed occurrences must not land on non-working days either
+		rolled.Jitter = offset
+		schedule = rolled
+	}
+
+	recurrence, isRecurrence := schedule.(Recurrence)
+	unanchored := isRecurrence && recurrence.Start == nil && recurrence.End == nil
+
+	if len(task.ExDates) > 0 || len(task.RDates) > 0 {
+		if unanchored {
+			return nil, ErrBadTaskDates
+		}
+
+		schedule = RecurrenceSet{Schedule: schedule, ExDates: task.ExDates, RDates: task.RDates}
+	}
+
+	if task.Jitter != "" {
+		if unanchored {
+			return nil, ErrBadJitter
+		}
+
+		schedule = JitteredSchedule{Base: schedule, Offset: offset}
+	}
+
+	return schedule, nil
+}
+
+// Describe sets the description of the task rule in the locale with the given
+// tag, it is left empty when the rule does not parse.
+func (task *DbTask) Describe(locale string) {
+	if compiled, err := task.Compile(); err == nil {
+		task.Description = Describe(compiled.Schedule, locale)
+	}
 }
 
 func getRunnableTasks(db sql.DB, firingAtTo time.Time, limit int) ([]DbTask, error) {
 	stmt, err := db.Prepare(`
-		select id, rule, timeZone, epsilon, maxRetries, completed from tasks
+		select ` + taskColumns + ` from tasks
 		where completed == 0 and (nextFireAt <= ? or nextFireAt is null)
 		order by nextFireAt asc
 		limit ?
@@ -42,32 +185,29 @@ func getRunnableTasks(db sql.DB, firingAtTo time.Time, limit int) ([]DbTask, err
 
 	defer rows.Close()
 
-	return initTasksFrom(rows)
+	tasks, err := initTasksFrom(rows)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err = loadTasksDates(db, tasks); err != nil {
+		return nil, err
+	}
+
+	return tasks, nil
 }
 
 func initTasksFrom(rows *sql.Rows) ([]DbTask, error) {
 	var tasks = []DbTask{}
 
 	for rows.Next() {
-		var id string
-		var rule string
-		var timeZone string
-		var epsilon int
-		var maxRetries int
-		var completed bool
-
-		err := rows.Scan(&id, &rule, &timeZone, &epsilon, &maxRetries, &completed)
+		task, err := scanTask(rows)
 
 		if err != nil {
 			return nil, err
 		}
 
-		var task = &DbTask{
-			Task:        &Task{Id: id, Rule: rule, TimeZone: timeZone, Epsilon: epsilon},
-			Completed:   completed,
-			NextFireAt:  nil,
-			NextRetryAt: nil}
-
 		tasks = append(tasks, *task)
 	}
 
@@ -80,8 +220,78 @@ func initTasksFrom(rows *sql.Rows) ([]DbTask, error) {
 	return tasks, nil
 }
 
+type taskScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// unixTime scans a time stored as Unix seconds, which SQLite drivers may
+// return as a time for DATETIME columns.
+type unixTime struct {
+	Time  time.Time
+	Valid bool
+}
+
+func (t *unixTime) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		t.Time, t.Valid = time.Time{}, false
+	case int64:
+		t.Time, t.Valid = time.Unix(v, 0).UTC(), true
+	case time.Time:
+		t.Time, t.Valid = v.UTC(), true
+	default:
+		return fmt.Errorf("bad stored time %v", value)
+	}
+
+	return nil
+}
+
+func scanTask(row taskScanner) (*DbTask, error) {
+	var id string
+	var rule string
+	var timeZone string
+	var dstGap string
+	var dstOverlap string
+	var monthEnd string
+	var calendar string
+	var roll string
+	var jitter string
+	var epsilon int
+	var misfire string
+	var maxCatchUp int
+	var maxRetries int
+	var completed bool
+	var nextFireAt unixTime
+	var remaining sql.NullInt64
+	var version int
+
+	err := row.Scan(&id, &rule, &timeZone, &dstGap, &dstOverlap, &monthEnd, &calendar, &roll, &jitter, &epsilon, &misfire, &maxCatchUp, &maxRetries, &completed, &nextFireAt, &remaining, &version)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var task = &DbTask{
+		Task:        &Task{Id: id, Rule: rule, TimeZone: timeZone, DSTGap: dstGap, DSTOverlap: dstOverlap, MonthEnd: monthEnd, Calendar: calendar, Roll: roll, Jitter: jitter, Epsilon: epsilon, Misfire: misfire, MaxCatchUp: maxCatchUp, MaxRetries: maxRetries},
+		Completed:   completed,
+		NextFireAt:  nil,
+		NextRetryAt: nil,
+		Version:     version}
+
+	if nextFireAt.Valid {
+		task.NextFireAt = &nextFireAt.Time
+	}
+
+	if remaining.Valid {
+		left := int(remaining.Int64)
+		task.Remaining = &left
+	}
+
+	return task, nil
+}
+
 func getTasks(db sql.DB) ([]DbTask, error) {
-	rows, err := db.Query("select id, rule, timeZone, epsilon, maxRetries, completed from tasks")
+	rows, err := db.Query("select " + taskColumns + " from tasks")
 
 	if err != nil {
 		return nil, err
@@ -89,12 +299,22 @@ func getTasks(db sql.DB) ([]DbTask, error) {
 
 	defer rows.Close()
 
-	return initTasksFrom(rows)
+	tasks, err := initTasksFrom(rows)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err = loadTasksDates(db, tasks); err != nil {
+		return nil, err
+	}
+
+	return tasks, nil
 }
 
 func getTask(db sql.DB, taskId string) (*DbTask, error) {
 	stmt, err := db.Prepare(`
-		select id, rule, timeZone, epsilon, maxRetries, completed from tasks
+		select ` + taskColumns + ` from tasks
 	    where id = ? limit 1
  	`)
 
@@ -104,16 +324,7 @@ func getTask(db sql.DB, taskId string) (*DbTask, error) {
 
 	defer stmt.Close()
 
-	var id string
-	var rule string
-	var timeZone string
-	var epsilon int
-	var maxRetries int
-	var completed bool
-
-	row := stmt.QueryRow(taskId)
-
-	err = row.Scan(&id, &rule, &timeZone, &epsilon, &maxRetries, &completed)
+	task, err := scanTask(stmt.QueryRow(taskId))
 
 	if err != nil && err == sql.ErrNoRows {
 		return nil, nil
@@ -121,15 +332,235 @@ func getTask(db sql.DB, taskId string) (*DbTask, error) {
 		return nil, err
 	}
 
-	var task = &DbTask{Task: &Task{Id: id, Rule: rule, TimeZone: timeZone, Epsilon: epsilon}, Completed: completed, NextFireAt: nil, NextRetryAt: nil}
+	if err = loadTaskDates(db, task); err != nil {
+		return nil, err
+	}
 
 	return task, nil
 }
 
-func updateTaskNextFireAt(db sql.DB, taskId string, nextFireAt time.Time, completed bool) error {
+func loadTasksDates(db sql.DB, tasks []DbTask) error {
+	for i := range tasks {
+		if err := loadTaskDates(db, &tasks[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// loadTaskDates reads the excluded and extra fire times of the task.
+func loadTaskDates(db sql.DB, task *DbTask) error {
+	var err error
+
+	if task.ExDates, err = getTaskDates(db, exDatesTable, task.Id); err != nil {
+		return err
+	}
+
+	task.RDates, err = getTaskDates(db, rDatesTable, task.Id)
+
+	return err
+}
+
+func getTaskDates(db sql.DB, table string, taskId string) ([]time.Time, error) {
+	rows, err := db.Query("select fireAt from "+table+" where taskId = ? order by fireAt", taskId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var dates []time.Time
+
+	for rows.Next() {
+		var fireAt unixTime
+
+		if err := rows.Scan(&fireAt); err != nil {
+			return nil, err
+		}
+
+		dates = append(dates, fireAt.Time)
+	}
+
+	return dates, rows.Err()
+}
+
+func insertTaskDates(tx *sql.Tx, table string, taskId string, dates []time.Time) error {
+	stmt, err := tx.Prepare("insert or ignore into " + table + "(taskId, fireAt) values(?, ?)")
+
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	for _, date := range dates {
+		if _, err := stmt.Exec(taskId, date.UTC().Unix()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addTaskDates stores more excluded or extra fire times of the task. An extra
+// one before the next fire time becomes the next fire time. It returns nil
+// when there is no such task.
+func addTaskDates(db sql.DB, table string, taskId string, dates []time.Time, now time.Time) (*DbTask, error) {
+	task, err := getTask(db, taskId)
+
+	if err != nil || task == nil {
+		return nil, err
+	}
+
+	if table == exDatesTable {
+		task.ExDates = append(task.ExDates, dates...)
+	} else {
+		task.RDates = append(task.RDates, dates...)
+	}
+
+	if _, err := task.Schedule(); err != nil {
+		return nil, err
+	}
+
+	tx, err := db.Begin()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err = insertTaskDates(tx, table, taskId, dates); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	nextFireAt := task.NextFireAt
+
+	for i, date := range dates {
+		if table == rDatesTable && !task.Completed && nextFireAt != nil && date.After(now) && date.Before(*nextFireAt) {
+			nextFireAt = &dates[i]
+		}
+	}
+
+	if nextFireAt != task.NextFireAt {
+		if _, err = tx.Exec("update tasks set nextFireAt = ? where id = ?", nextFireAt.UTC().Unix(), taskId); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	if _, err = tx.Exec("update tasks set version = version + 1 where id = ?", taskId); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	forgetSchedule(taskId)
+
+	return getTask(db, taskId)
+}
+
+// removeTaskDates deletes excluded or extra fire times of the task. A task
+// not yet due is then scheduled again from now, an occurrence no longer
+// excluded possibly coming first. It returns nil when there is no such task.
+func removeTaskDates(db sql.DB, table string, taskId string, dates []time.Time, now time.Time) (*DbTask, error) {
+	task, err := getTask(db, taskId)
+
+	if err != nil || task == nil {
+		return nil, err
+	}
+
+	if table == exDatesTable {
+		task.ExDates = withoutDates(task.ExDates, dates)
+	} else {
+		task.RDates = withoutDates(task.RDates, dates)
+	}
+
+	tx, err := db.Begin()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err = deleteTaskDates(tx, table, taskId, dates); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if schedule, err := task.Schedule(); err == nil && !task.Completed && task.NextFireAt != nil && task.NextFireAt.After(now) {
+		if nextFireAt, remaining, ok := nextFire(schedule, now); ok {
+			if _, err = tx.Exec("update tasks set nextFireAt = ?, remaining = ? where id = ?", nextFireAt.UTC().Unix(), remaining, taskId); err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+		}
+	}
+
+	if _, err = tx.Exec("update tasks set version = version + 1 where id = ?", taskId); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	forgetSchedule(taskId)
+
+	return getTask(db, taskId)
+}
+
+func deleteTaskDates(tx *sql.Tx, table string, taskId string, dates []time.Time) error {
+	stmt, err := tx.Prepare("delete from " + table + " where taskId = ? and fireAt = ?")
+
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	for _, date := range dates {
+		if _, err := stmt.Exec(taskId, date.UTC().Unix()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// withoutDates returns the dates not removed, compared to the second as they
+// are stored.
+func withoutDates(dates []time.Time, removed []time.Time) []time.Time {
+	var kept []time.Time
+
+	for _, date := range dates {
+		found := false
+
+		for _, r := range removed {
+			if r.Unix() == date.Unix() {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			kept = append(kept, date)
+		}
+	}
+
+	return kept
+}
+
+// updateTaskNextFireAt stores the next fire time of the task, a completed
+// task no longer needs its compiled schedule.
+func updateTaskNextFireAt(db sql.DB, taskId string, nextFireAt time.Time, remaining int, completed bool) error {
 	stmt, err := db.Prepare(`
 		update tasks
-		set nextFireAt = ?, completed = ?
+		set nextFireAt = ?, remaining = ?, completed = ?
 		where id = ?
 	`)
 
@@ -139,17 +570,33 @@ func updateTaskNextFireAt(db sql.DB, taskId string, nextFireAt time.Time, comple
 
 	defer stmt.Close()
 
-	_, err = stmt.Exec(nextFireAt.UTC().Unix(), completed, taskId)
+	_, err = stmt.Exec(nextFireAt.UTC().Unix(), remaining, completed, taskId)
 
 	if err != nil {
 		return err
 	}
 
+	if completed {
+		forgetSchedule(taskId)
+	}
+
 	return nil
 }
 
 func addTask(db sql.DB, task Task) (*DbTask, error) {
-	dbTask := &DbTask{Task: &task, Completed: false, NextFireAt: nil, NextRetryAt: nil}
+	schedule, err := task.Schedule()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err = task.validateMisfire(); err != nil {
+		return nil, err
+	}
+
+	task.Rule = schedule.String()
+
+	dbTask := &DbTask{Task: &task, Completed: false, NextFireAt: nil, NextRetryAt: nil, Version: 1}
 
 	tx, err := db.Begin()
 
@@ -157,17 +604,29 @@ func addTask(db sql.DB, task Task) (*DbTask, error) {
 		return nil, err
 	}
 
-	stmt, err := tx.Prepare(`insert into tasks(id, rule, timeZone, epsilon, maxRetries, completed) values(?, ?, ?, ?, ?, ?)`)
+	stmt, err := tx.Prepare(`insert into tasks(id, rule, timeZone, dstGap, dstOverlap, monthEnd, calendar, roll, jitter, epsilon, misfire, maxCatchUp, maxRetries, completed) values(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
 
 	if err != nil {
+		tx.Rollback()
 		return nil, err
 	}
 
 	defer stmt.Close()
 
-	_, err = stmt.Exec(&dbTask.Id, &dbTask.Rule, &dbTask.TimeZone, &dbTask.Epsilon, &dbTask.MaxRetries, &dbTask.Completed)
+	_, err = stmt.Exec(&dbTask.Id, &dbTask.Rule, &dbTask.TimeZone, &dbTask.DSTGap, &dbTask.DSTOverlap, &dbTask.MonthEnd, &dbTask.Calendar, &dbTask.Roll, &dbTask.Jitter, &dbTask.Epsilon, &dbTask.Misfire, &dbTask.MaxCatchUp, &dbTask.MaxRetries, &dbTask.Completed)
 
 	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err = insertTaskDates(tx, exDatesTable, dbTask.Id, dbTask.ExDates); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err = insertTaskDates(tx, rDatesTable, dbTask.Id, dbTask.RDates); err != nil {
+		tx.Rollback()
 		return nil, err
 	}
 
@@ -177,5 +636,7 @@ func addTask(db sql.DB, task Task) (*DbTask, error) {
 		return nil, err
 	}
 
+	forgetSchedule(dbTask.Id)
+
 	return dbTask, nil
 }
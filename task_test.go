@@ -0,0 +1,116 @@
+package main
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testDb returns a database created from db.sql in a directory removed
+// together with it by the returned function. The functions under test take
+// the database by value, so its copies must not share idle connections.
+func testDb(t *testing.T) (*sql.DB, func()) {
+	dir, err := ioutil.TempDir("", "tempo")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := initDb(filepath.Join(dir, "tasks.db"))
+
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("Expected database but got error %s", err)
+	}
+
+	db.SetMaxIdleConns(0)
+
+	return db, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestGetTask(t *testing.T) {
+	db, done := testDb(t)
+	defer done()
+
+	if _, err := db.Exec("insert into tasks(id, rule, timeZone, nextFireAt, remaining) values(?, ?, ?, ?, ?)", "stored", "R3/2017-01-01T00:00:00Z/P1D", "", now.Unix(), 3); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.Exec("insert into tasksExDates(taskId, fireAt) values(?, ?)", "stored", now.AddDate(0, 0, 1).Unix()); err != nil {
+		t.Fatal(err)
+	}
+
+	task, err := getTask(*db, "stored")
+
+	if err != nil || task == nil {
+		t.Fatalf("Expected task but got %v, %v", task, err)
+	}
+
+	if task.NextFireAt == nil || !task.NextFireAt.Equal(now) || task.Remaining == nil || *task.Remaining != 3 {
+		t.Errorf("Expected next fire time %s with 3 repetitions left but got %v, %v", now, task.NextFireAt, task.Remaining)
+	}
+
+	if len(task.ExDates) != 1 || !task.ExDates[0].Equal(now.AddDate(0, 0, 1)) {
+		t.Errorf("Expected excluded date %s but got %v", now.AddDate(0, 0, 1), task.ExDates)
+	}
+
+	if task, err = getTask(*db, "missing"); task != nil || err != nil {
+		t.Errorf("Expected no task but got %v, %v", task, err)
+	}
+}
+
+func TestRemoveTaskDates(t *testing.T) {
+	db, done := testDb(t)
+	defer done()
+
+	exDate := now.AddDate(0, 0, 1)
+
+	if _, err := db.Exec("insert into tasks(id, rule, timeZone, nextFireAt) values(?, ?, ?, ?)", "removed-dates", "R/2017-01-01T00:00:00Z/P1D", "", now.AddDate(0, 0, 2).Unix()); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.Exec("insert into tasksExDates(taskId, fireAt) values(?, ?)", "removed-dates", exDate.Unix()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the occurrence no longer excluded comes before the scheduled one
+	task, err := removeTaskDates(*db, exDatesTable, "removed-dates", []time.Time{exDate}, now.Add(12*time.Hour))
+
+	if err != nil || task == nil {
+		t.Fatalf("Expected task but got %v, %v", task, err)
+	}
+
+	if len(task.ExDates) != 0 || task.NextFireAt == nil || !task.NextFireAt.Equal(exDate) || task.Version != 2 {
+		t.Errorf("Expected no excluded dates, next fire time %s and version 2 but got %v, %v and %d", exDate, task.ExDates, task.NextFireAt, task.Version)
+	}
+
+	if task, err = removeTaskDates(*db, exDatesTable, "missing", []time.Time{exDate}, now); task != nil || err != nil {
+		t.Errorf("Expected no task but got %v, %v", task, err)
+	}
+}
+
+func TestAddTaskFailure(t *testing.T) {
+	db, done := testDb(t)
+	defer done()
+
+	task := Task{Id: "added", Rule: "R/2017-01-01T00:00:00Z/P1D"}
+
+	if _, err := addTask(*db, task); err != nil {
+		t.Fatalf("Expected task but got error %s", err)
+	}
+
+	if _, err := addTask(*db, task); err == nil {
+		t.Fatalf("Expected error adding the same task twice")
+	}
+
+	// the failed insert must not keep the database locked
+	if _, err := addTask(*db, Task{Id: "added-later", Rule: task.Rule}); err != nil {
+		t.Errorf("Expected task but got error %s", err)
+	}
+}
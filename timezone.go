@@ -0,0 +1,152 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+var (
+	//ErrBadTimeZone unknown IANA time zone
+	ErrBadTimeZone = errors.New("bad time zone")
+	//ErrBadDSTPolicy unknown DST gap or overlap policy
+	ErrBadDSTPolicy = errors.New("bad DST policy")
+)
+
+// GapPolicy tells what happens to an occurrence whose wall time falls into a
+// DST gap, e.g. 02:30 on the night clocks jump from 02:00 to 03:00.
+type GapPolicy int
+
+const (
+	// GapShiftForward fires the occurrence shifted forward by the gap length.
+	GapShiftForward GapPolicy = iota
+	// GapSkip drops the occurrence.
+	GapSkip
+)
+
+// OverlapPolicy tells what happens to an occurrence whose wall time happens
+// twice, e.g. 02:30 on the night clocks fall back from 03:00 to 02:00.
+type OverlapPolicy int
+
+const (
+	// OverlapOnce fires the occurrence at the first of the two instants.
+	OverlapOnce OverlapPolicy = iota
+	// OverlapTwice fires the occurrence at both instants.
+	OverlapTwice
+)
+
+// LocationFromString loads IANA time zone, an empty name means UTC.
+func LocationFromString(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+
+	loc, err := time.LoadLocation(name)
+
+	if err != nil {
+		return nil, ErrBadTimeZone
+	}
+
+	return loc, nil
+}
+
+// GapPolicyFromString parses "shift" or "skip", an empty string means shift.
+func GapPolicyFromString(policy string) (GapPolicy, error) {
+	switch policy {
+	case "", "shift":
+		return GapShiftForward, nil
+	case "skip":
+		return GapSkip, nil
+	default:
+		return GapShiftForward, ErrBadDSTPolicy
+	}
+}
+
+// OverlapPolicyFromString parses "once" or "twice", an empty string means once.
+func OverlapPolicyFromString(policy string) (OverlapPolicy, error) {
+	switch policy {
+	case "", "once":
+		return OverlapOnce, nil
+	case "twice":
+		return OverlapTwice, nil
+	default:
+		return OverlapOnce, ErrBadDSTPolicy
+	}
+}
+
+// dstSearchWindow bounds how far DST transitions move wall clocks.
+const dstSearchWindow = 3 * time.Hour
+
+// dstShift returns by how much the UTC offset of the location changes within
+// dstSearchWindow of t, zero away from DST transitions. Wall times further
+// apart than that happen in the same order as their instants.
+func dstShift(t time.Time, loc *time.Location) time.Duration {
+	_, offset := t.In(loc).Zone()
+	shift := time.Duration(0)
+
+	for _, probe := range []time.Duration{-dstSearchWindow, dstSearchWindow} {
+		_, probeOffset := t.Add(probe).In(loc).Zone()
+		change := time.Duration(probeOffset-offset) * time.Second
+
+		if change < 0 {
+			change = -change
+		}
+
+		if change > shift {
+			shift = change
+		}
+	}
+
+	return shift
+}
+
+// floating returns the wall clock of t as a UTC time, so calendar arithmetic
+// on it is not affected by DST transitions.
+func floating(t time.Time) time.Time {
+	year, month, day := t.Date()
+	hour, minute, sec := t.Clock()
+
+	return time.Date(year, month, day, hour, minute, sec, t.Nanosecond(), time.UTC)
+}
+
+// resolveWallTime returns the instants at which the floating wall time
+// happens in the location, applying the gap and overlap policies.
+func resolveWallTime(wall time.Time, loc *time.Location, gap GapPolicy, overlap OverlapPolicy) []time.Time {
+	year, month, day := wall.Date()
+	hour, minute, sec := wall.Clock()
+
+	t := time.Date(year, month, day, hour, minute, sec, wall.Nanosecond(), loc)
+
+	if !floating(t).Equal(wall) {
+		if gap == GapSkip {
+			return nil
+		}
+
+		return []time.Time{t}
+	}
+
+	instants := []time.Time{t}
+	_, offset := t.Zone()
+
+	for _, probe := range []time.Duration{-12 * time.Hour, 12 * time.Hour} {
+		_, probeOffset := t.Add(probe).Zone()
+
+		if probeOffset == offset {
+			continue
+		}
+
+		other := t.Add(time.Duration(offset-probeOffset) * time.Second)
+
+		if floating(other).Equal(wall) {
+			instants = append(instants, other)
+		}
+	}
+
+	sort.Slice(instants, func(i, j int) bool { return instants[i].Before(instants[j]) })
+
+	if overlap == OverlapOnce {
+		return instants[:1]
+	}
+
+	return instants
+}